@@ -1,18 +1,30 @@
-//go:build windows
-// +build windows
-
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"math"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
+	"proxywatch/internal/baseline"
 	"proxywatch/internal/classifier"
+	"proxywatch/internal/config"
+	"proxywatch/internal/fingerprint"
+	"proxywatch/internal/ioc"
+	"proxywatch/internal/scenario"
 	"proxywatch/internal/shared"
-	"proxywatch/internal/telemetry"
+	"proxywatch/internal/sink"
+	"proxywatch/internal/source"
+	"proxywatch/internal/suppress"
+	_ "proxywatch/internal/telemetry"
 	"proxywatch/internal/ui"
 )
 
@@ -32,6 +44,339 @@ func parseRoleFilter(s string) map[string]bool {
 	return out
 }
 
+func printCandidates(prefix string, cands []shared.Candidate, zones *shared.NetworkZones) {
+	suppressed := 0
+	for _, c := range cands {
+		if c.Suppressed != nil {
+			suppressed++
+			continue
+		}
+		udpInt, udpExt, udpLo := zones.UDPScopeCounts(c.UDPListeners)
+		iocs := ""
+		seen := make(map[shared.IOCHit]bool)
+		for _, h := range c.IOC {
+			key := shared.IOCHit{List: h.List, Indicator: h.Indicator}
+			if !seen[key] {
+				seen[key] = true
+				iocs += fmt.Sprintf(" ioc=%s:%s", h.List, h.Indicator)
+			}
+		}
+		fmt.Printf(
+			"%spid=%d role=%s active=%v out_int=%d out_ext=%d out_lo=%d%s\n",
+			prefix,
+			c.Proc.Pid,
+			c.Role,
+			c.ActiveProxying,
+			c.OutInternal+udpInt,
+			c.OutExternal+udpExt,
+			c.OutLoopback+udpLo,
+			iocs,
+		)
+	}
+	if suppressed > 0 {
+		fmt.Printf("%ssuppressed=%d\n", prefix, suppressed)
+	}
+}
+
+// sinkFlags collects the forwarding flags.
+type sinkFlags struct {
+	syslog, syslogFormat, syslogCA string
+	hec, hecToken, hecIndex        string
+	elastic, elasticKey, elasticIx string
+	connections                    bool
+	queue, ca                      string
+}
+
+// openSinks opens the configured forwarders. They are fed every classified
+// snapshot and closed on exit.
+func openSinks(f sinkFlags) (sinks, error) {
+	var out sinks
+	if f.syslog != "" {
+		s, err := sink.NewSyslog(sink.SyslogOptions{URL: f.syslog, Format: f.syslogFormat, CAFile: f.syslogCA})
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	if f.hec != "" {
+		s, err := sink.NewSplunkHEC(sink.HTTPOptions{
+			URL:         f.hec,
+			Token:       f.hecToken,
+			Index:       f.hecIndex,
+			Connections: f.connections,
+			QueueDir:    f.queue,
+			CAFile:      f.ca,
+		})
+		if err != nil {
+			out.close()
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	if f.elastic != "" {
+		s, err := sink.NewElastic(sink.HTTPOptions{
+			URL:         f.elastic,
+			Token:       f.elasticKey,
+			Index:       f.elasticIx,
+			Connections: f.connections,
+			QueueDir:    f.queue,
+			CAFile:      f.ca,
+		})
+		if err != nil {
+			out.close()
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+type sinks []shared.Observer
+
+// observe feeds the sinks outside the TUI, where errors go to stderr.
+func (s sinks) observe(snap *shared.Snapshot, cands []shared.Candidate) {
+	for _, o := range s {
+		if err := o.Observe(snap, cands); err != nil {
+			fmt.Fprintln(os.Stderr, "warning:", err)
+		}
+	}
+}
+
+func (s sinks) close() {
+	for _, o := range s {
+		if c, ok := o.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				fmt.Fprintln(os.Stderr, "warning:", err)
+			}
+		}
+	}
+}
+
+// runReplayPrint classifies every recorded snapshot and prints the results
+// instead of starting the TUI.
+func runReplayPrint(src shared.Source, engine *classifier.Engine, opts shared.ClassifyOptions, out sinks) error {
+	for {
+		snap, err := src.Collect(context.Background())
+		if errors.Is(err, shared.ErrSourceExhausted) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		cands := engine.Classify(snap, opts)
+		printCandidates(fmt.Sprintf("ts=%s ", snap.Timestamp.UTC().Format(time.RFC3339)), cands, engine.NetworkZones())
+		out.observe(snap, cands)
+	}
+}
+
+// runLearn classifies snapshots for d, measured in snapshot time so replays
+// learn at their own pace, and writes the resulting profiles to path. An
+// interrupt ends learning early and still saves.
+func runLearn(src shared.Source, engine *classifier.Engine, interval, d time.Duration, path string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	learner := baseline.NewLearner(engine.NetworkZones())
+	var start time.Time
+	for ctx.Err() == nil {
+		snap, err := src.Collect(ctx)
+		if errors.Is(err, shared.ErrSourceExhausted) || ctx.Err() != nil {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		now := snap.Timestamp
+		if now.IsZero() {
+			now = time.Now()
+		}
+		// every process counts towards its profile, not just the suspicious
+		cands := engine.Classify(snap, shared.ClassifyOptions{MinScore: math.MinInt})
+		learner.Observe(cands, now)
+
+		if start.IsZero() {
+			start = now
+		}
+		if now.Sub(start) >= d {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(interval):
+		}
+	}
+
+	b := learner.Baseline()
+	if b.Ticks == 0 {
+		return errors.New("learn: no snapshots collected")
+	}
+	if err := b.Save(path); err != nil {
+		return err
+	}
+	fmt.Printf("baseline: %d executables over %d ticks (%s) written to %s\n",
+		len(b.Profiles), b.Ticks, b.Ended.Sub(b.Started).Round(time.Second), path)
+	return nil
+}
+
+// runScenarios runs the scenarios named by spec and prints one PASS/FAIL line
+// each. It reports whether all of them passed.
+func runScenarios(spec string, newEngine func() (*classifier.Engine, error)) (bool, error) {
+	var list []*scenario.Scenario
+	switch {
+	case spec == "all" || spec == "list":
+		list = scenario.Builtin()
+	case strings.HasSuffix(spec, ".scn"):
+		var err error
+		if list, err = scenario.Load(spec); err != nil {
+			return false, err
+		}
+	default:
+		builtin := scenario.Builtin()
+		for _, name := range strings.Split(spec, ",") {
+			s := scenario.Find(builtin, strings.TrimSpace(name))
+			if s == nil {
+				return false, fmt.Errorf("unknown scenario %q (try -scenario list)", name)
+			}
+			list = append(list, s)
+		}
+	}
+
+	if spec == "list" {
+		for _, s := range list {
+			fmt.Printf("%-24s %s\n", s.Name, s.Description)
+		}
+		return true, nil
+	}
+
+	failed := 0
+	for _, s := range list {
+		engine, err := newEngine()
+		if err != nil {
+			return false, err
+		}
+		res := scenario.Run(s, engine)
+		status := "PASS"
+		if !res.Passed() {
+			status = "FAIL"
+			failed++
+		}
+		fmt.Printf("%s  %-24s %s\n", status, s.Name, s.Description)
+		for _, f := range res.Failures {
+			fmt.Printf("      %s\n", f)
+		}
+	}
+	fmt.Printf("%d scenarios, %d failed\n", len(list), failed)
+	return failed == 0, nil
+}
+
+// loadConfig reads the config file, or returns the bare preset when none is
+// given.
+func loadConfig(path, preset string) (*config.Config, error) {
+	if path == "" {
+		return config.Preset(preset)
+	}
+	return config.Load(path, preset)
+}
+
+// fileOverrides holds the file flags that win over the config file's keys.
+type fileOverrides struct {
+	rules        string
+	suppress     string
+	fingerprints string
+	ioc          string
+	baseline     string
+}
+
+// applyConfig installs cfg into the engine. Classifier history is kept.
+func applyConfig(cfg *config.Config, engine *classifier.Engine, over fileOverrides) error {
+	zones, err := cfg.NetworkZones()
+	if err != nil {
+		return err
+	}
+
+	rulesPath := cfg.Rules
+	if over.rules != "" {
+		rulesPath = over.rules
+	}
+	var rs *classifier.RuleSet
+	if rulesPath != "" {
+		if rs, err = classifier.LoadRules(rulesPath); err != nil {
+			return err
+		}
+	}
+
+	suppressPath := cfg.Suppressions
+	if over.suppress != "" {
+		suppressPath = over.suppress
+	}
+	var sup shared.Suppressor
+	if suppressPath != "" {
+		list, err := suppress.Load(suppressPath)
+		if err != nil {
+			return err
+		}
+		sup = list
+	}
+
+	fpPath := cfg.Fingerprints
+	if over.fingerprints != "" {
+		fpPath = over.fingerprints
+	}
+	var pack *fingerprint.Pack
+	switch fpPath {
+	case "":
+	case config.BuiltinFingerprints:
+		pack = fingerprint.Builtin()
+	default:
+		if pack, err = fingerprint.Load(fpPath); err != nil {
+			return err
+		}
+	}
+
+	iocDir := cfg.IOC
+	if over.ioc != "" {
+		iocDir = over.ioc
+	}
+	var iocSet shared.IOCMatcher
+	if iocDir != "" {
+		set, err := ioc.Load(iocDir)
+		if err != nil {
+			return err
+		}
+		iocSet = set
+	}
+
+	baselinePath := cfg.Baseline
+	if over.baseline != "" {
+		baselinePath = over.baseline
+	}
+	var base *baseline.Baseline
+	if baselinePath != "" {
+		if base, err = baseline.Load(baselinePath); err != nil {
+			return err
+		}
+	}
+
+	engine.SetNetworkZones(zones)
+	engine.SetThresholds(cfg.Thresholds())
+	engine.SetRules(rs)
+	engine.SetSuppressor(sup)
+	engine.SetFingerprints(pack)
+	engine.SetIOC(iocSet)
+	engine.SetBaseline(base)
+	return nil
+}
+
+// applyBurst hands cfg's TCP resampling settings to sources that take them.
+func applyBurst(cfg *config.Config, src shared.Source) {
+	if t, ok := src.(shared.BurstTuner); ok {
+		t.SetBurstSettings(cfg.BurstSettings())
+	}
+}
+
 /* ---------------- main ---------------- */
 
 func main() {
@@ -39,30 +384,174 @@ func main() {
 	roles := flag.String("roles", "", "Comma-separated list of roles to display")
 	interval := flag.Duration("interval", 1*time.Second, "Refresh interval (e.g. 250ms, 1s)")
 	incremental := flag.Bool("incremental", false, "Reuse classification for unchanged PIDs (faster, slightly less accurate)")
-	jsonOut := flag.String("json", "", "Write pretty JSON snapshots to a file (use '-' for stdout)")
+	jsonOut := flag.String("json", "", "Write JSON snapshots to a file (use '-' for stdout)")
+	logFormat := flag.String("format", shared.LogFormatJSON, "Format for -json: 'json' (one pretty array, complete on exit), 'ndjson' (one snapshot per line, flushed as written) or 'delta' (ndjson with periodic keyframes and only changes in between)")
+	keyframeEvery := flag.Duration("keyframe-every", shared.DefaultKeyframeInterval, "With -format delta, write a full snapshot this often (0 = only at the start of each file)")
+	rotateSize := flag.Int64("rotate-size", 0, "Rotate the -json file once it reaches this many MB (0 = no size limit)")
+	rotateEvery := flag.Duration("rotate-every", 0, "Rotate the -json file after this long (e.g. 1h, 24h; 0 = never)")
+	rotateKeep := flag.Int("rotate-keep", 0, "Rotated -json files to keep, oldest removed first (0 = keep all)")
+	rotateGzip := flag.Bool("rotate-gzip", true, "Gzip rotated -json files")
+	syslogURL := flag.String("syslog", "", "Send an event to a syslog collector when a candidate appears, changes role or score band, or disappears (udp://, tcp:// or tls://host:port)")
+	syslogFormat := flag.String("syslog-format", sink.SyslogRFC5424, "Syslog message format: 'rfc5424' (structured data) or 'cef'")
+	syslogCA := flag.String("syslog-ca", "", "PEM CA bundle to verify a tls:// syslog collector (default: system roots)")
+	hecURL := flag.String("hec", "", "Ship findings to a Splunk HTTP Event Collector (e.g. https://splunk:8088)")
+	hecToken := flag.String("hec-token", os.Getenv("PROXYWATCH_HEC_TOKEN"), "Splunk HEC token (default $PROXYWATCH_HEC_TOKEN)")
+	hecIndex := flag.String("hec-index", "", "Splunk index (default: the token's)")
+	elasticURL := flag.String("elastic", "", "Ship findings as ECS documents to an Elasticsearch _bulk endpoint (e.g. https://es:9200)")
+	elasticKey := flag.String("elastic-api-key", os.Getenv("PROXYWATCH_ELASTIC_API_KEY"), "Elasticsearch API key (default $PROXYWATCH_ELASTIC_API_KEY; user:pass@ in the URL also works)")
+	elasticIndex := flag.String("elastic-index", sink.DefaultElasticIndex, "Elasticsearch index or data stream")
+	sinkConns := flag.Bool("sink-connections", false, "Also ship every new connection to -hec/-elastic, not just findings")
+	sinkQueue := flag.String("sink-queue", "", "Directory keeping -hec/-elastic batches the collector did not take, sent when it is back")
+	sinkCA := flag.String("sink-ca", "", "PEM CA bundle to verify https -hec/-elastic collectors (default: system roots)")
+	sourceSpec := flag.String("source", "live", "Snapshot source as name[:arg] (available: "+strings.Join(shared.SourceNames(), ", ")+")")
+	replay := flag.String("replay", "", "Replay a JSON capture written with -json instead of collecting live")
+	replaySpeed := flag.Float64("replay-speed", 1, "Replay pacing multiplier (1 = original timing, 0 = as fast as possible)")
+	printOut := flag.Bool("print", false, "With -replay, print results to stdout instead of starting the TUI")
+	rulesFile := flag.String("rules", "", "Load scoring rules from a JSON file instead of the built-in set")
+	configFile := flag.String("config", "", "Load thresholds, zones and port lists from a JSON config file (reloaded on SIGHUP or 'r')")
+	preset := flag.String("preset", config.DefaultPreset, "Config preset when no file is given or the file names none ("+strings.Join(config.PresetNames(), ", ")+")")
+	suppressFile := flag.String("suppress", "", "Load suppression (allowlist) rules from a JSON file")
+	iocDir := flag.String("ioc", "", "Match remote addresses, listeners and processes against the IP/CIDR, SHA-256 and name lists in a directory")
+	fingerprintFile := flag.String("fingerprints", "", "Match known tunnel tools: 'builtin' for the embedded pack, or a JSON file extending it")
+	learn := flag.Duration("learn", 0, "Learn per-executable network profiles for this long (e.g. 24h) and write them to -baseline")
+	baselineFile := flag.String("baseline", "", "Baseline file: written by -learn, otherwise deviations from it are scored")
+	scenarioSpec := flag.String("scenario", "", "Run synthetic scenarios through the classifier and check their expected outcomes: 'all', 'list', comma-separated built-in names, or a .scn file")
+	dumpConfig := flag.Bool("dump-config", false, "Print the effective configuration as JSON and exit")
 
 	flag.Parse()
 
 	roleFilter := parseRoleFilter(*roles)
-	minScore := 15
+
+	cfg, err := loadConfig(*configFile, *preset)
+	if err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+	if *dumpConfig {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(cfg)
+		return
+	}
+
+	overrides := fileOverrides{
+		rules:        *rulesFile,
+		suppress:     *suppressFile,
+		fingerprints: *fingerprintFile,
+		ioc:          *iocDir,
+		baseline:     *baselineFile,
+	}
+
+	// a learning run writes the baseline instead of scoring against it
+	learnPath := ""
+	if *learn > 0 {
+		learnPath = overrides.baseline
+		if learnPath == "" {
+			learnPath = cfg.Baseline
+		}
+		if learnPath == "" {
+			fmt.Println("error: -learn needs -baseline (or the baseline config key) to write to")
+			os.Exit(1)
+		}
+		overrides.baseline = ""
+		cfg.Baseline = ""
+	}
+
+	engine := classifier.NewEngine(shared.DefaultThresholds())
+	if err := applyConfig(cfg, engine, overrides); err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+	minScore := cfg.MinScore
+
+	if *scenarioSpec != "" {
+		// every scenario gets a fresh engine configured like this one
+		newEngine := func() (*classifier.Engine, error) {
+			e := classifier.NewEngine(shared.DefaultThresholds())
+			return e, applyConfig(cfg, e, overrides)
+		}
+		ok, err := runScenarios(*scenarioSpec, newEngine)
+		if err != nil {
+			fmt.Println("error:", err)
+			os.Exit(1)
+		}
+		if !ok {
+			os.Exit(1)
+		}
+		return
+	}
+
+	var src shared.Source
+	if *replay != "" {
+		src, err = source.OpenReplay(*replay, *replaySpeed)
+	} else {
+		src, err = shared.OpenSource(*sourceSpec)
+	}
+	if err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+	applyBurst(cfg, src)
+
+	if *learn > 0 {
+		if err := runLearn(src, engine, *interval, *learn, learnPath); err != nil {
+			fmt.Println("error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	forwarders, err := openSinks(sinkFlags{
+		syslog:       *syslogURL,
+		syslogFormat: *syslogFormat,
+		syslogCA:     *syslogCA,
+		hec:          *hecURL,
+		hecToken:     *hecToken,
+		hecIndex:     *hecIndex,
+		elastic:      *elasticURL,
+		elasticKey:   *elasticKey,
+		elasticIx:    *elasticIndex,
+		connections:  *sinkConns,
+		queue:        *sinkQueue,
+		ca:           *sinkCA,
+	})
+	if err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+	defer forwarders.close()
+
+	if *replay != "" && *printOut {
+		err := runReplayPrint(src, engine, shared.ClassifyOptions{
+			MinScore:   minScore,
+			RoleFilter: roleFilter,
+		}, forwarders)
+		if err != nil {
+			forwarders.close()
+			fmt.Println("error:", err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	// -------- one-shot mode --------
 	if *once {
-		snap, err := telemetry.Collect()
+		snap, err := src.Collect(context.Background())
 		if err != nil {
 			fmt.Println("error:", err)
 			os.Exit(1)
 		}
 
-		cands := classifier.Classify(snap, shared.ClassifyOptions{
+		cands := engine.Classify(snap, shared.ClassifyOptions{
 			MinScore:    minScore,
 			RoleFilter:  roleFilter,
 			Incremental: false,
-		}, nil)
+		})
+		forwarders.observe(snap, cands)
 
 		// intentionally minimal, machine-friendly output
 		if *jsonOut != "" {
-			logger, err := shared.NewJSONLogger(*jsonOut, true)
+			logger, err := shared.OpenJSONLogger(*jsonOut, *logFormat, shared.LogRotation{})
 			if err != nil {
 				fmt.Println("error:", err)
 				os.Exit(1)
@@ -72,34 +561,32 @@ func main() {
 			return
 		}
 
-		for _, c := range cands {
-			udpInt, udpExt, udpLo := shared.UDPScopeCounts(c.UDPListeners)
-			fmt.Printf(
-				"pid=%d role=%s active=%v out_int=%d out_ext=%d out_lo=%d\n",
-				c.Proc.Pid,
-				c.Role,
-				c.ActiveProxying,
-				c.OutInternal+udpInt,
-				c.OutExternal+udpExt,
-				c.OutLoopback+udpLo,
-			)
-		}
-
+		printCandidates("", cands, engine.NetworkZones())
 		return
 	}
 
 	// -------- interactive TUI --------
+	// a replay releases snapshots at -replay-speed, not on the refresh tick
+	_, paced := src.(*source.ReplaySource)
 	app := &shared.AppState{
 		RefreshInt:         *interval,
 		ConfirmKill:        true,
 		ConfirmKillTimeout: 3 * time.Second,
+		Zones:              engine.NetworkZones(),
+		SourcePaced:        paced,
 	}
 
-	logger, err := shared.NewJSONLogger(*jsonOut, true)
+	logger, err := shared.OpenJSONLogger(*jsonOut, *logFormat, shared.LogRotation{
+		MaxBytes: *rotateSize << 20,
+		MaxAge:   *rotateEvery,
+		MaxFiles: *rotateKeep,
+		Compress: *rotateGzip,
+	})
 	if err != nil {
 		fmt.Println("error:", err)
 		os.Exit(1)
 	}
+	logger.SetKeyframeInterval(*keyframeEvery)
 
 	sc := &shared.ScannerAdapter{
 		Options: shared.ClassifyOptions{
@@ -107,16 +594,49 @@ func main() {
 			RoleFilter:  roleFilter,
 			Incremental: *incremental,
 		},
-		Collect:  telemetry.Collect,
-		Classify: classifier.Classify,
-		Logger:   logger,
+		Source:    src,
+		Classify:  engine.Classify,
+		Logger:    logger,
+		Observers: forwarders,
 	}
 
+	app.Reloader = shared.ReloaderFunc(func() error {
+		cfg, err := loadConfig(*configFile, *preset)
+		if err != nil {
+			return err
+		}
+		if err := applyConfig(cfg, engine, overrides); err != nil {
+			return err
+		}
+		applyBurst(cfg, src)
+		app.Zones = engine.NetworkZones()
+		sc.SetOptions(shared.ClassifyOptions{
+			MinScore:    cfg.MinScore,
+			RoleFilter:  roleFilter,
+			Incremental: *incremental,
+		})
+		return nil
+	})
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	reloadRequests := make(chan struct{}, 1)
+	go func() {
+		for range hup {
+			select {
+			case reloadRequests <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	app.ReloadRequests = reloadRequests
+
 	if err := ui.Run(app, sc); err != nil {
 		fmt.Println("error:", err)
 		if logger != nil {
 			_ = logger.Close()
 		}
+		forwarders.close()
 		os.Exit(1)
 	}
 
@@ -4,11 +4,13 @@ go 1.24.0
 
 toolchain go1.24.12
 
-require golang.org/x/sys v0.38.0
+require (
+	github.com/gdamore/tcell/v2 v2.13.8
+	golang.org/x/sys v0.38.0
+)
 
 require (
 	github.com/gdamore/encoding v1.0.1 // indirect
-	github.com/gdamore/tcell/v2 v2.13.8 // indirect
 	github.com/lucasb-eyer/go-colorful v1.3.0 // indirect
 	github.com/rivo/uniseg v0.4.7 // indirect
 	golang.org/x/term v0.37.0 // indirect
@@ -0,0 +1,353 @@
+// Package baseline learns per-executable network profiles and reports how a
+// candidate deviates from what its executable usually does.
+package baseline
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"time"
+
+	"proxywatch/internal/shared"
+)
+
+const fileVersion = 1
+
+// maxRemotePorts bounds a profile's remote port set; executables that talk to
+// more ports than this (browsers, updaters) are marked AnyRemotePort instead.
+const maxRemotePorts = 256
+
+// Profile is what one executable was seen doing during learning. Counts are
+// per tick; the internal/external totals give its usual internal ratio.
+type Profile struct {
+	Samples          int     `json:"samples"`
+	ListenerPorts    []int   `json:"listener_ports,omitempty"`
+	UDPListenerPorts []int   `json:"udp_listener_ports,omitempty"`
+	RemotePorts      []int   `json:"remote_ports,omitempty"`
+	AnyRemotePort    bool    `json:"any_remote_port,omitempty"`
+	LateralPorts     []int   `json:"lateral_ports,omitempty"`
+	InternalConns    int     `json:"internal_conns"`
+	ExternalConns    int     `json:"external_conns"`
+	MaxOutbound      int     `json:"max_outbound"`
+	MeanOutbound     float64 `json:"mean_outbound"`
+	MaxTargets       int     `json:"max_targets"`
+}
+
+// InternalRatio is the share of outbound connections that went to internal
+// hosts, or -1 when the executable made none.
+func (p *Profile) InternalRatio() float64 {
+	total := p.InternalConns + p.ExternalConns
+	if total == 0 {
+		return -1
+	}
+	return float64(p.InternalConns) / float64(total)
+}
+
+// Baseline is the on-disk result of a learning run, keyed by executable path
+// ("name:<process>" when the path is unknown).
+type Baseline struct {
+	Version  int                 `json:"version"`
+	Started  time.Time           `json:"started"`
+	Ended    time.Time           `json:"ended"`
+	Ticks    int                 `json:"ticks"`
+	Profiles map[string]*Profile `json:"profiles"`
+}
+
+func Load(path string) (*Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var b Baseline
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("baseline %s: %w", path, err)
+	}
+	if b.Version != fileVersion {
+		return nil, fmt.Errorf("baseline %s: unsupported version %d", path, b.Version)
+	}
+	if b.Profiles == nil {
+		b.Profiles = make(map[string]*Profile)
+	}
+	return &b, nil
+}
+
+// Save writes b atomically, so an interrupted write never leaves a
+// truncated baseline behind.
+func (b *Baseline) Save(path string) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Key identifies the executable behind c.
+func Key(c *shared.Candidate) string {
+	if c.Proc == nil {
+		return ""
+	}
+	if c.Proc.ExePath != "" {
+		return c.Proc.ExePath
+	}
+	return "name:" + c.Proc.Name
+}
+
+// sample is one tick's view of a candidate in profile terms.
+type sample struct {
+	listenerPorts    []int
+	udpListenerPorts []int
+	remotePorts      []int
+	lateralPorts     []int
+	internal         int
+	external         int
+	targets          int
+}
+
+func summarize(c *shared.Candidate, zones *shared.NetworkZones) sample {
+	var s sample
+	listening := make(map[int]bool, len(c.Listeners))
+	for _, l := range c.Listeners {
+		listening[l.LocalPort] = true
+		s.listenerPorts = addPort(s.listenerPorts, l.LocalPort)
+	}
+	for _, u := range c.UDPListeners {
+		s.udpListenerPorts = addPort(s.udpListenerPorts, u.LocalPort)
+	}
+
+	targets := make(map[string]bool)
+	for _, cn := range c.Conns {
+		if listening[cn.LocalPort] || cn.State == "TIME_WAIT" || cn.RemoteAddress == "" ||
+			shared.IsWildcardIP(cn.RemoteAddress) || shared.IsLoopbackIP(cn.RemoteAddress) {
+			continue
+		}
+		targets[cn.RemoteAddress] = true
+		s.remotePorts = addPort(s.remotePorts, cn.RemotePort)
+		if zones.IsInternal(cn.RemoteAddress) {
+			s.internal++
+			if zones.IsLateral(cn.RemotePort) {
+				s.lateralPorts = addPort(s.lateralPorts, cn.RemotePort)
+			}
+		} else {
+			s.external++
+		}
+	}
+	s.targets = len(targets)
+	return s
+}
+
+// addPort inserts port into the sorted set ports.
+func addPort(ports []int, port int) []int {
+	i, found := slices.BinarySearch(ports, port)
+	if found {
+		return ports
+	}
+	return slices.Insert(ports, i, port)
+}
+
+func hasPort(ports []int, port int) bool {
+	_, found := slices.BinarySearch(ports, port)
+	return found
+}
+
+/* ---------------- learning ---------------- */
+
+// Learner accumulates profiles from classified candidates, telling internal
+// from external targets by zones.
+type Learner struct {
+	b     *Baseline
+	zones *shared.NetworkZones
+}
+
+func NewLearner(zones *shared.NetworkZones) *Learner {
+	return &Learner{
+		b:     &Baseline{Version: fileVersion, Profiles: make(map[string]*Profile)},
+		zones: zones,
+	}
+}
+
+// Observe records one tick: every candidate seen at now.
+func (l *Learner) Observe(cands []shared.Candidate, now time.Time) {
+	if l.b.Started.IsZero() {
+		l.b.Started = now
+	}
+	l.b.Ended = now
+	l.b.Ticks++
+
+	// several processes can share an executable; merge them per tick
+	perKey := make(map[string]sample)
+	for i := range cands {
+		key := Key(&cands[i])
+		if key == "" {
+			continue
+		}
+		s := summarize(&cands[i], l.zones)
+		if prev, ok := perKey[key]; ok {
+			s = merge(prev, s)
+		}
+		perKey[key] = s
+	}
+
+	for key, s := range perKey {
+		p := l.b.Profiles[key]
+		if p == nil {
+			p = &Profile{}
+			l.b.Profiles[key] = p
+		}
+		p.observe(s)
+	}
+}
+
+func merge(a, b sample) sample {
+	for _, p := range b.listenerPorts {
+		a.listenerPorts = addPort(a.listenerPorts, p)
+	}
+	for _, p := range b.udpListenerPorts {
+		a.udpListenerPorts = addPort(a.udpListenerPorts, p)
+	}
+	for _, p := range b.remotePorts {
+		a.remotePorts = addPort(a.remotePorts, p)
+	}
+	for _, p := range b.lateralPorts {
+		a.lateralPorts = addPort(a.lateralPorts, p)
+	}
+	a.internal += b.internal
+	a.external += b.external
+	a.targets += b.targets
+	return a
+}
+
+func (p *Profile) observe(s sample) {
+	for _, port := range s.listenerPorts {
+		p.ListenerPorts = addPort(p.ListenerPorts, port)
+	}
+	for _, port := range s.udpListenerPorts {
+		p.UDPListenerPorts = addPort(p.UDPListenerPorts, port)
+	}
+	if !p.AnyRemotePort {
+		for _, port := range s.remotePorts {
+			p.RemotePorts = addPort(p.RemotePorts, port)
+		}
+		if len(p.RemotePorts) > maxRemotePorts {
+			p.RemotePorts = nil
+			p.AnyRemotePort = true
+		}
+	}
+	for _, port := range s.lateralPorts {
+		p.LateralPorts = addPort(p.LateralPorts, port)
+	}
+
+	out := s.internal + s.external
+	p.InternalConns += s.internal
+	p.ExternalConns += s.external
+	p.MeanOutbound = (p.MeanOutbound*float64(p.Samples) + float64(out)) / float64(p.Samples+1)
+	p.Samples++
+	if out > p.MaxOutbound {
+		p.MaxOutbound = out
+	}
+	if s.targets > p.MaxTargets {
+		p.MaxTargets = s.targets
+	}
+}
+
+func (l *Learner) Baseline() *Baseline {
+	return l.b
+}
+
+/* ---------------- deviations ---------------- */
+
+// Deviation kinds.
+const (
+	NewListener    = "new-listener"
+	NewLateralPort = "new-lateral-port"
+	NewRemotePort  = "new-remote-port"
+	MoreTargets    = "more-targets"
+	InternalShift  = "internal-shift"
+)
+
+// Deviation is one way a candidate differs from its executable's profile.
+type Deviation struct {
+	Kind   string
+	Detail string
+}
+
+// Check compares c with its executable's profile, reading its targets through
+// zones. known is false when the executable was never seen while learning; it
+// then has nothing to deviate from.
+func (b *Baseline) Check(c *shared.Candidate, zones *shared.NetworkZones) (devs []Deviation, known bool) {
+	p := b.Profiles[Key(c)]
+	if p == nil {
+		return nil, false
+	}
+	s := summarize(c, zones)
+
+	for _, port := range s.listenerPorts {
+		if !hasPort(p.ListenerPorts, port) {
+			devs = append(devs, Deviation{NewListener, fmt.Sprintf("New TCP listener on port %d (baseline: %s)", port, portList(p.ListenerPorts))})
+		}
+	}
+	for _, port := range s.udpListenerPorts {
+		if !hasPort(p.UDPListenerPorts, port) {
+			devs = append(devs, Deviation{NewListener, fmt.Sprintf("New UDP listener on port %d (baseline: %s)", port, portList(p.UDPListenerPorts))})
+		}
+	}
+	for _, port := range s.lateralPorts {
+		if !hasPort(p.LateralPorts, port) {
+			devs = append(devs, Deviation{NewLateralPort, fmt.Sprintf("First internal connection to lateral port %d", port)})
+		}
+	}
+	if !p.AnyRemotePort {
+		var fresh []int
+		for _, port := range s.remotePorts {
+			if !hasPort(p.RemotePorts, port) && !hasPort(s.lateralPorts, port) {
+				fresh = append(fresh, port)
+			}
+		}
+		if len(fresh) > 0 {
+			devs = append(devs, Deviation{NewRemotePort, fmt.Sprintf("Connects to remote port(s) %s not seen while learning", portList(fresh))})
+		}
+	}
+	// "many more": at least double the learned maximum and three more hosts
+	if s.targets >= 2*p.MaxTargets && s.targets-p.MaxTargets >= 3 {
+		devs = append(devs, Deviation{MoreTargets, fmt.Sprintf("%d remote targets (baseline max %d)", s.targets, p.MaxTargets)})
+	}
+	if out := s.internal + s.external; out > 0 && s.internal > 0 {
+		now := float64(s.internal) / float64(out)
+		if was := p.InternalRatio(); now-max(was, 0) >= 0.5 {
+			devs = append(devs, Deviation{InternalShift, fmt.Sprintf("%.0f%% of connections internal (baseline %s)", now*100, ratioLabel(was))})
+		}
+	}
+	return devs, true
+}
+
+func portList(ports []int) string {
+	if len(ports) == 0 {
+		return "none"
+	}
+	sorted := append([]int(nil), ports...)
+	sort.Ints(sorted)
+	out := ""
+	for i, p := range sorted {
+		if i == 8 {
+			out += fmt.Sprintf(", +%d more", len(sorted)-i)
+			break
+		}
+		if i > 0 {
+			out += ", "
+		}
+		out += fmt.Sprint(p)
+	}
+	return out
+}
+
+func ratioLabel(r float64) string {
+	if r < 0 {
+		return "no outbound"
+	}
+	return fmt.Sprintf("%.0f%%", r*100)
+}
@@ -0,0 +1,147 @@
+package classifier
+
+import (
+	"math"
+	"time"
+
+	"proxywatch/internal/shared"
+)
+
+// beaconMaxOpens bounds the connection open times kept per destination.
+const beaconMaxOpens = 32
+
+type beaconDest struct {
+	Addr string
+	Port int
+}
+
+// beaconTrack records when a process opened connections to one destination
+// after a quiet period. Connections replacing one another back to back are
+// churn, not check-ins, so an open only counts when the destination had no
+// live connection on the previous tick.
+type beaconTrack struct {
+	opens    []time.Time
+	live     bool
+	lastSeen time.Time
+}
+
+// updateBeacons records new outbound connections of pid and returns the most
+// regular periodic destination, or nil when none qualifies.
+func (e *Engine) updateBeacons(pid int, conns []shared.ConnectionInfo, ports map[int]struct{}, now time.Time) *shared.BeaconInfo {
+	current := make(map[beaconDest]struct{})
+	for _, c := range conns {
+		// closing sockets linger (TIME_WAIT) long after a check-in ended
+		if c.State != "ESTABLISHED" && c.State != "SYN_SENT" {
+			continue
+		}
+		if c.RemoteAddress == "" ||
+			shared.IsWildcardIP(c.RemoteAddress) ||
+			shared.IsLoopbackIP(c.RemoteAddress) {
+			continue
+		}
+		if _, ok := ports[c.LocalPort]; ok {
+			continue
+		}
+		current[beaconDest{Addr: c.RemoteAddress, Port: c.RemotePort}] = struct{}{}
+	}
+
+	tracks := e.beacons[pid]
+	if tracks == nil {
+		if len(current) == 0 {
+			return nil
+		}
+		tracks = make(map[beaconDest]*beaconTrack)
+		e.beacons[pid] = tracks
+	}
+
+	for d := range current {
+		t := tracks[d]
+		if t == nil {
+			t = &beaconTrack{}
+			tracks[d] = t
+		}
+		if !t.live {
+			t.opens = append(t.opens, now)
+			if len(t.opens) > beaconMaxOpens {
+				t.opens = t.opens[len(t.opens)-beaconMaxOpens:]
+			}
+		}
+		t.live = true
+		t.lastSeen = now
+	}
+
+	var best *shared.BeaconInfo
+	for d, t := range tracks {
+		if _, ok := current[d]; !ok {
+			t.live = false
+			if now.Sub(t.lastSeen) > 2*e.thresholds.BeaconMaxPeriod {
+				delete(tracks, d)
+				continue
+			}
+		}
+
+		info := e.beaconStats(d, t.opens, now)
+		if info != nil && (best == nil || info.Count > best.Count ||
+			(info.Count == best.Count && info.JitterSeconds < best.JitterSeconds)) {
+			best = info
+		}
+	}
+	if len(tracks) == 0 {
+		delete(e.beacons, pid)
+	}
+	return best
+}
+
+// quietBeacons marks every destination of processes missing from present as
+// no longer live.
+func (e *Engine) quietBeacons(present map[int]bool) {
+	for pid, tracks := range e.beacons {
+		if present[pid] {
+			continue
+		}
+		for _, t := range tracks {
+			t.live = false
+		}
+	}
+}
+
+// beaconStats measures the intervals between opens and reports them when
+// they are regular enough and the destination is still being contacted.
+func (e *Engine) beaconStats(d beaconDest, opens []time.Time, now time.Time) *shared.BeaconInfo {
+	n := len(opens) - 1
+	if n < e.thresholds.BeaconMinIntervals {
+		return nil
+	}
+
+	var sum float64
+	for i := 1; i < len(opens); i++ {
+		sum += opens[i].Sub(opens[i-1]).Seconds()
+	}
+	mean := sum / float64(n)
+	if mean < e.thresholds.BeaconMinPeriod.Seconds() || mean > e.thresholds.BeaconMaxPeriod.Seconds() {
+		return nil
+	}
+
+	var sq float64
+	for i := 1; i < len(opens); i++ {
+		dev := opens[i].Sub(opens[i-1]).Seconds() - mean
+		sq += dev * dev
+	}
+	jitter := math.Sqrt(sq / float64(n))
+	if jitter/mean > e.thresholds.BeaconMaxJitter {
+		return nil
+	}
+
+	// a beacon that missed two check-ins has stopped
+	if now.Sub(opens[len(opens)-1]).Seconds() > 2*mean {
+		return nil
+	}
+
+	return &shared.BeaconInfo{
+		RemoteAddr:    d.Addr,
+		RemotePort:    d.Port,
+		Count:         n,
+		MeanSeconds:   mean,
+		JitterSeconds: jitter,
+	}
+}
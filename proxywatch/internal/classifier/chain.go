@@ -0,0 +1,222 @@
+package classifier
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"proxywatch/internal/shared"
+)
+
+const (
+	maxChainDepth  = 8
+	maxChainEgress = 5
+	// maxChains bounds the distinct chains reported per snapshot; processes
+	// beyond it go without one
+	maxChains = 256
+)
+
+// buildLoopbackChains links processes that connect to 127.0.0.1:port with the
+// process listening on that port, and returns the longest chain each PID
+// belongs to (e.g. browser -> local SOCKS client -> tunnel agent -> remote).
+func buildLoopbackChains(snap *shared.Snapshot) map[int]*shared.ProcessChain {
+	listenersByPort := make(map[int][]int)
+	for _, l := range snap.Listeners {
+		if l.Pid == 0 {
+			continue
+		}
+		if !shared.IsLoopbackIP(l.LocalAddress) && !shared.IsWildcardIP(l.LocalAddress) {
+			continue
+		}
+		listenersByPort[l.LocalPort] = appendUnique(listenersByPort[l.LocalPort], l.Pid)
+	}
+
+	next := make(map[int][]int)
+	prev := make(map[int][]int)
+	ports := make(map[[2]int]int)
+	for _, cn := range snap.Connections {
+		if cn.Pid == 0 || !isActiveConnState(cn.State) {
+			continue
+		}
+		if !shared.IsLoopbackIP(cn.LocalAddress) || !shared.IsLoopbackIP(cn.RemoteAddress) {
+			continue
+		}
+		for _, to := range listenersByPort[cn.RemotePort] {
+			edge := [2]int{cn.Pid, to}
+			if to == cn.Pid {
+				continue
+			}
+			if _, dup := ports[edge]; dup {
+				continue
+			}
+			ports[edge] = cn.RemotePort
+			next[cn.Pid] = append(next[cn.Pid], to)
+			prev[to] = append(prev[to], cn.Pid)
+		}
+	}
+	if len(ports) == 0 {
+		return nil
+	}
+
+	// the longest path leaving and reaching each process, memoised so the
+	// cost stays linear in the edges however wide the fan-in is
+	down := longestPaths(next)
+	up := longestPaths(prev)
+
+	pids := make([]int, 0, len(next)+len(prev))
+	for pid := range next {
+		pids = append(pids, pid)
+	}
+	for pid := range prev {
+		if _, ok := next[pid]; !ok {
+			pids = append(pids, pid)
+		}
+	}
+	sort.Ints(pids)
+
+	out := make(map[int]*shared.ProcessChain)
+	chains := make(map[string]*shared.ProcessChain)
+	for _, pid := range pids {
+		path, pos := throughPath(up(pid), down(pid))
+		if len(path) < 2 {
+			continue
+		}
+		hops := make([]shared.ChainHop, len(path))
+		ids := make([]string, len(path))
+		for i, p := range path {
+			hops[i].Pid = p
+			if i > 0 {
+				hops[i].Port = ports[[2]int{path[i-1], p}]
+			}
+			ids[i] = strconv.Itoa(p)
+		}
+		id := strings.Join(ids, ">")
+		chain, ok := chains[id]
+		if !ok {
+			if len(chains) >= maxChains {
+				continue
+			}
+			chain = newChain(snap, hops)
+			chains[id] = chain
+		}
+		member := *chain
+		member.Position = pos
+		out[pid] = &member
+	}
+	return out
+}
+
+// longestPaths returns a memoised lookup of the longest simple path starting
+// at a PID along adj, at most maxChainDepth long. A cycle is cut where it
+// closes.
+func longestPaths(adj map[int][]int) func(int) []int {
+	memo := make(map[int][]int)
+	onStack := make(map[int]bool)
+	var longest func(pid int) []int
+	longest = func(pid int) []int {
+		if p, ok := memo[pid]; ok {
+			return p
+		}
+		onStack[pid] = true
+		best := []int{pid}
+		for _, to := range adj[pid] {
+			if onStack[to] {
+				continue
+			}
+			tail := longest(to)
+			if 1+len(tail) > len(best) && !containsPid(tail, pid) {
+				best = append([]int{pid}, tail...)
+			}
+		}
+		if len(best) > maxChainDepth {
+			best = best[:maxChainDepth]
+		}
+		delete(onStack, pid)
+		memo[pid] = best
+		return best
+	}
+	return longest
+}
+
+// throughPath joins the longest path reaching a PID (up, listed from the PID
+// backwards) with the longest leaving it, and returns it with the PID's
+// position. When the two share a process the longer one is kept alone.
+func throughPath(up, down []int) ([]int, int) {
+	path := make([]int, 0, len(up)+len(down)-1)
+	for i := len(up) - 1; i > 0; i-- {
+		if containsPid(down, up[i]) {
+			if len(down) >= len(up) {
+				return down, 0
+			}
+			rev := make([]int, len(up))
+			for j, p := range up {
+				rev[len(up)-1-j] = p
+			}
+			return rev, len(up) - 1
+		}
+		path = append(path, up[i])
+	}
+	pos := len(path)
+	path = append(path, down...)
+	if over := len(path) - maxChainDepth; over > 0 {
+		path = path[over:]
+		pos -= over
+	}
+	return path, pos
+}
+
+func containsPid(list []int, pid int) bool {
+	for _, p := range list {
+		if p == pid {
+			return true
+		}
+	}
+	return false
+}
+
+func newChain(snap *shared.Snapshot, hops []shared.ChainHop) *shared.ProcessChain {
+	ids := make([]string, len(hops))
+	for i := range hops {
+		if p := snap.Processes[hops[i].Pid]; p != nil {
+			hops[i].Name = p.Name
+		}
+		ids[i] = strconv.Itoa(hops[i].Pid)
+	}
+
+	last := hops[len(hops)-1].Pid
+	var egress []string
+	seen := make(map[string]bool)
+	for _, cn := range snap.Connections {
+		if cn.Pid != last || !isActiveConnState(cn.State) {
+			continue
+		}
+		if cn.RemoteAddress == "" ||
+			shared.IsWildcardIP(cn.RemoteAddress) ||
+			shared.IsLoopbackIP(cn.RemoteAddress) {
+			continue
+		}
+		ep := fmt.Sprintf("%s:%d", cn.RemoteAddress, cn.RemotePort)
+		if seen[ep] || len(egress) >= maxChainEgress {
+			continue
+		}
+		seen[ep] = true
+		egress = append(egress, ep)
+	}
+	sort.Strings(egress)
+
+	return &shared.ProcessChain{
+		ID:     strings.Join(ids, ">"),
+		Hops:   hops,
+		Egress: egress,
+	}
+}
+
+func appendUnique(list []int, v int) []int {
+	for _, x := range list {
+		if x == v {
+			return list
+		}
+	}
+	return append(list, v)
+}
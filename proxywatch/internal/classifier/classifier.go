@@ -1,6 +1,7 @@
 package classifier
 
 import (
+	"fmt"
 	"sort"
 	"time"
 
@@ -8,20 +9,21 @@ import (
 )
 
 // Classify converts a telemetry snapshot into classified candidates.
-func Classify(
-	snap *shared.Snapshot,
-	opts shared.ClassifyOptions,
-	cache *shared.ClassifierCache,
-) []shared.Candidate {
-
+func (e *Engine) Classify(snap *shared.Snapshot, opts shared.ClassifyOptions) []shared.Candidate {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := classifyTime(snap, opts)
+	// derived here so every caller (TUI, -once, replays, learning,
+	// scenarios) scores the same transfer rates
+	shared.ApplyConnRates(snap.Connections, now, &e.lastConn)
 	candidates := buildCandidates(snap)
-	now := time.Now()
 
 	var (
 		nextCandidates map[int]shared.Candidate
 		nextSignatures map[int]shared.CandidateSignature
 	)
-	if opts.Incremental && cache != nil {
+	if opts.Incremental {
 		nextCandidates = make(map[int]shared.Candidate, len(candidates))
 		nextSignatures = make(map[int]shared.CandidateSignature, len(candidates))
 	}
@@ -29,29 +31,37 @@ func Classify(
 	var interesting []shared.Candidate
 	for i := range candidates {
 		c := &candidates[i]
-		if opts.Incremental && cache != nil {
+		if opts.Incremental {
 			sig := candidateSignature(*c)
-			prevCands := cache.Candidates
-			prevSigs := cache.Signatures
+			prevCands := e.cache.Candidates
+			prevSigs := e.cache.Signatures
 			if prevCands != nil && prevSigs != nil {
 				if prev, ok := prevCands[c.Proc.Pid]; ok {
 					if prevSig, ok := prevSigs[c.Proc.Pid]; ok && prevSig == sig {
 						reuseCandidate(c, &prev)
-						touchHistoryFromCandidate(c, now)
+						e.touchHistoryFromCandidate(c, now)
 					} else {
-						ScoreCandidate(c)
+						e.scoreCandidate(c, now)
 					}
 				} else {
-					ScoreCandidate(c)
+					e.scoreCandidate(c, now)
 				}
 			} else {
-				ScoreCandidate(c)
+				e.scoreCandidate(c, now)
 			}
 
 			nextSignatures[c.Proc.Pid] = sig
 			nextCandidates[c.Proc.Pid] = *c
 		} else {
-			ScoreCandidate(c)
+			e.scoreCandidate(c, now)
+		}
+
+		e.recordTransition(c, now)
+
+		// matched after caching so reused results never carry stale hits
+		c.IOC = nil
+		if e.ioc != nil {
+			e.applyIOC(c)
 		}
 
 		if len(opts.RoleFilter) > 0 {
@@ -60,16 +70,28 @@ func Classify(
 			}
 		}
 
-		if c.Score >= opts.MinScore || c.Role == "reverse-control" || c.Role == "reverse-transport" {
+		if c.Score >= opts.MinScore || c.Role == "reverse-control" || c.Role == "reverse-transport" || len(c.IOC) > 0 {
+			c.Suppressed = nil
+			if e.suppressor != nil {
+				c.Suppressed = e.suppressor.Match(c, now)
+			}
 			interesting = append(interesting, *c)
 		}
 	}
 
-	if opts.Incremental && cache != nil {
-		cache.Candidates = nextCandidates
-		cache.Signatures = nextSignatures
+	if opts.Incremental {
+		e.cache.Candidates = nextCandidates
+		e.cache.Signatures = nextSignatures
 	}
 
+	// processes without sockets this tick are not scored, so close their
+	// beacon destinations here; the next connection is then a new check-in
+	present := make(map[int]bool, len(candidates))
+	for i := range candidates {
+		present[candidates[i].Proc.Pid] = true
+	}
+	e.quietBeacons(present)
+
 	sort.Slice(interesting, func(i, j int) bool {
 		pri := rolePriority(interesting[i].Role)
 		prj := rolePriority(interesting[j].Role)
@@ -94,24 +116,56 @@ func Classify(
 	return interesting
 }
 
+// applyIOC records threat intel hits on c with an ioc-match signal and one
+// reason per hit.
+func (e *Engine) applyIOC(c *shared.Candidate) {
+	c.IOC = e.ioc.Match(c)
+	if len(c.IOC) == 0 {
+		return
+	}
+	// copy so appends never reach a cached candidate's backing arrays
+	c.Signals = append(append([]string(nil), c.Signals...), "ioc-match")
+	reasons := append([]string(nil), c.Reasons...)
+	for _, h := range c.IOC {
+		reasons = append(reasons, fmt.Sprintf("IOC match in %s: %s (%s %s)", h.List, h.Indicator, h.Where, h.Observed))
+	}
+	c.Reasons = reasons
+}
+
+func classifyTime(snap *shared.Snapshot, opts shared.ClassifyOptions) time.Time {
+	if opts.Clock != nil {
+		return opts.Clock.Now()
+	}
+	if snap != nil && !snap.Timestamp.IsZero() {
+		return snap.Timestamp
+	}
+	return time.Now()
+}
+
 func rolePriority(role string) int {
 	switch role {
 	case "reverse-transport":
 		return 90
 	case "reverse-proxy":
 		return 80
+	case "scanner":
+		return 75
 	case "proxy-listener":
 		return 70
+	case "udp-relay":
+		return 67
 	case "tunnel-likely":
 		return 65
+	case "udp-tunnel":
+		return 62
 	case "listener-with-clients":
 		return 60
 	case "listener-with-outbound":
 		return 50
+	case "beacon":
+		return 45
 	case "reverse-control":
 		return 40
-	case "reverse-tunnel":
-		return 35
 	case "listener-only":
 		return 30
 	case "outbound-only":
@@ -139,6 +193,11 @@ func buildCandidates(snap *shared.Snapshot) []shared.Candidate {
 		umap[u.Pid] = append(umap[u.Pid], u)
 	}
 
+	ucmap := make(map[int][]shared.UDPConnInfo)
+	for _, u := range snap.UDPConns {
+		ucmap[u.Pid] = append(ucmap[u.Pid], u)
+	}
+
 	seen := make(map[int]bool)
 	for pid := range lmap {
 		seen[pid] = true
@@ -149,6 +208,11 @@ func buildCandidates(snap *shared.Snapshot) []shared.Candidate {
 	for pid := range umap {
 		seen[pid] = true
 	}
+	for pid := range ucmap {
+		seen[pid] = true
+	}
+
+	chains := buildLoopbackChains(snap)
 
 	var out []shared.Candidate
 	for pid := range seen {
@@ -162,6 +226,8 @@ func buildCandidates(snap *shared.Snapshot) []shared.Candidate {
 			Listeners:    lmap[pid],
 			Conns:        cmap[pid],
 			UDPListeners: umap[pid],
+			UDPConns:     ucmap[pid],
+			Chain:        chains[pid],
 		})
 	}
 
@@ -0,0 +1,144 @@
+package classifier
+
+import (
+	"sync"
+	"time"
+
+	"proxywatch/internal/baseline"
+	"proxywatch/internal/fingerprint"
+	"proxywatch/internal/shared"
+)
+
+// Engine is an independent classifier instance. It owns its connection and
+// process history, tunables and incremental cache, so several engines (live
+// and replay, or one per host) can run side by side without sharing state.
+type Engine struct {
+	mu sync.Mutex
+
+	thresholds   shared.Thresholds
+	zones        *shared.NetworkZones
+	rules        *RuleSet
+	suppressor   shared.Suppressor
+	ioc          shared.IOCMatcher
+	fingerprints *fingerprint.Pack
+	baseline     *baseline.Baseline
+
+	connFirstSeen      map[shared.ConnKey]time.Time
+	udpFirstSeen       map[shared.ConnKey]time.Time
+	procHistory        map[int]*shared.ProcHistory
+	recentClientSeen   map[int]time.Time
+	recentOutboundSeen map[int]time.Time
+	beacons            map[int]map[beaconDest]*beaconTrack
+	scanAttempts       map[int]map[scanTarget]time.Time
+	lastConn           map[shared.ConnKey]shared.ConnSample
+	lastCleanup        time.Time
+
+	cache shared.ClassifierCache
+}
+
+func NewEngine(t shared.Thresholds) *Engine {
+	return &Engine{
+		thresholds:         t,
+		zones:              shared.DefaultNetworkZones(),
+		rules:              DefaultRules(),
+		connFirstSeen:      make(map[shared.ConnKey]time.Time),
+		udpFirstSeen:       make(map[shared.ConnKey]time.Time),
+		procHistory:        make(map[int]*shared.ProcHistory),
+		recentClientSeen:   make(map[int]time.Time),
+		recentOutboundSeen: make(map[int]time.Time),
+		beacons:            make(map[int]map[beaconDest]*beaconTrack),
+		scanAttempts:       make(map[int]map[scanTarget]time.Time),
+	}
+}
+
+func (e *Engine) Thresholds() shared.Thresholds {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.thresholds
+}
+
+// SetThresholds swaps the tunables while keeping accumulated history.
+func (e *Engine) SetThresholds(t shared.Thresholds) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.thresholds = t
+	// cached results were scored under the old thresholds
+	e.cache = shared.ClassifierCache{}
+}
+
+func (e *Engine) NetworkZones() *shared.NetworkZones {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.zones
+}
+
+// SetNetworkZones replaces the internal networks and lateral ports. A nil
+// value restores the defaults.
+func (e *Engine) SetNetworkZones(z *shared.NetworkZones) {
+	if z == nil {
+		z = shared.DefaultNetworkZones()
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.zones = z
+	e.cache = shared.ClassifierCache{}
+}
+
+func (e *Engine) Rules() *RuleSet {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.rules
+}
+
+// SetRules replaces the scoring rules. A nil set restores the built-in rules.
+func (e *Engine) SetRules(rs *RuleSet) {
+	if rs == nil {
+		rs = DefaultRules()
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = rs
+	e.cache = shared.ClassifierCache{}
+}
+
+// SetSuppressor installs the allowlist consulted for every reported
+// candidate; nil disables suppression.
+func (e *Engine) SetSuppressor(s shared.Suppressor) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.suppressor = s
+}
+
+// SetIOC installs the threat intel lists checked on every tick; nil disables
+// IOC matching.
+func (e *Engine) SetIOC(m shared.IOCMatcher) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.ioc = m
+}
+
+// SetFingerprints installs the tool fingerprint pack matched against every
+// candidate; nil disables fingerprinting.
+func (e *Engine) SetFingerprints(p *fingerprint.Pack) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.fingerprints = p
+	e.cache = shared.ClassifierCache{}
+}
+
+// SetBaseline installs the learned per-executable profiles that deviations
+// are measured against; nil disables deviation scoring.
+func (e *Engine) SetBaseline(b *baseline.Baseline) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.baseline = b
+	e.cache = shared.ClassifierCache{}
+}
+
+// ScoreCandidate scores c and derives its role. now drives every age and
+// history window, so the same snapshots and times always yield the same result.
+func (e *Engine) ScoreCandidate(c *shared.Candidate, now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.scoreCandidate(c, now)
+}
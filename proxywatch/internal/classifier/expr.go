@@ -0,0 +1,424 @@
+package classifier
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Rule conditions and score expressions use a small expression language:
+//
+//	outTotal >= 3 && (internalLateral || distinctTargets >= 5)
+//	role == "outbound-only" && !hasListener
+//	60 + min(outLongLived * 5, 25)
+//
+// Identifiers name candidate features; min, max and floor are built in.
+
+type valueKind int
+
+const (
+	kindNumber valueKind = iota
+	kindBool
+	kindString
+)
+
+// value is a feature or expression result.
+type value struct {
+	kind valueKind
+	num  float64
+	str  string
+}
+
+func numVal(v float64) value { return value{kind: kindNumber, num: v} }
+func strVal(v string) value  { return value{kind: kindString, str: v} }
+func boolVal(v bool) value {
+	if v {
+		return value{kind: kindBool, num: 1}
+	}
+	return value{kind: kindBool}
+}
+
+func (v value) truthy() bool {
+	switch v.kind {
+	case kindString:
+		return v.str != ""
+	default:
+		return v.num != 0
+	}
+}
+
+// float returns the numeric form of v; bools are 0 or 1, strings 0.
+func (v value) float() float64 {
+	if v.kind == kindString {
+		return 0
+	}
+	return v.num
+}
+
+func (v value) String() string {
+	switch v.kind {
+	case kindString:
+		return v.str
+	case kindBool:
+		return strconv.FormatBool(v.num != 0)
+	default:
+		return strconv.FormatFloat(v.num, 'f', -1, 64)
+	}
+}
+
+// native converts v to a plain Go value for JSON output.
+func (v value) native() any {
+	switch v.kind {
+	case kindString:
+		return v.str
+	case kindBool:
+		return v.num != 0
+	default:
+		return v.num
+	}
+}
+
+type featureSet map[string]value
+
+type expr interface {
+	eval(f featureSet) value
+}
+
+type (
+	litExpr   struct{ v value }
+	identExpr struct{ name string }
+	notExpr   struct{ x expr }
+	negExpr   struct{ x expr }
+	binExpr   struct {
+		op   string
+		l, r expr
+	}
+	callExpr struct {
+		fn   string
+		args []expr
+	}
+)
+
+func (e litExpr) eval(featureSet) value     { return e.v }
+func (e identExpr) eval(f featureSet) value { return f[e.name] }
+func (e notExpr) eval(f featureSet) value   { return boolVal(!e.x.eval(f).truthy()) }
+func (e negExpr) eval(f featureSet) value   { return numVal(-e.x.eval(f).float()) }
+
+func (e binExpr) eval(f featureSet) value {
+	switch e.op {
+	case "&&":
+		return boolVal(e.l.eval(f).truthy() && e.r.eval(f).truthy())
+	case "||":
+		return boolVal(e.l.eval(f).truthy() || e.r.eval(f).truthy())
+	}
+
+	l, r := e.l.eval(f), e.r.eval(f)
+	if l.kind == kindString || r.kind == kindString {
+		switch e.op {
+		case "==":
+			return boolVal(l.String() == r.String())
+		case "!=":
+			return boolVal(l.String() != r.String())
+		}
+		return boolVal(false)
+	}
+
+	a, b := l.float(), r.float()
+	switch e.op {
+	case "==":
+		return boolVal(a == b)
+	case "!=":
+		return boolVal(a != b)
+	case "<":
+		return boolVal(a < b)
+	case "<=":
+		return boolVal(a <= b)
+	case ">":
+		return boolVal(a > b)
+	case ">=":
+		return boolVal(a >= b)
+	case "+":
+		return numVal(a + b)
+	case "-":
+		return numVal(a - b)
+	case "*":
+		return numVal(a * b)
+	case "/":
+		if b == 0 {
+			return numVal(0)
+		}
+		return numVal(a / b)
+	}
+	return numVal(0)
+}
+
+func (e callExpr) eval(f featureSet) value {
+	switch e.fn {
+	case "min":
+		return numVal(math.Min(e.args[0].eval(f).float(), e.args[1].eval(f).float()))
+	case "max":
+		return numVal(math.Max(e.args[0].eval(f).float(), e.args[1].eval(f).float()))
+	case "floor":
+		return numVal(math.Floor(e.args[0].eval(f).float()))
+	}
+	return numVal(0)
+}
+
+// firstIdent returns the first feature referenced by e, or "".
+func firstIdent(e expr) string {
+	switch x := e.(type) {
+	case identExpr:
+		return x.name
+	case notExpr:
+		return firstIdent(x.x)
+	case negExpr:
+		return firstIdent(x.x)
+	case binExpr:
+		if n := firstIdent(x.l); n != "" {
+			return n
+		}
+		return firstIdent(x.r)
+	case callExpr:
+		for _, a := range x.args {
+			if n := firstIdent(a); n != "" {
+				return n
+			}
+		}
+	}
+	return ""
+}
+
+var exprFuncs = map[string]int{
+	"min":   2,
+	"max":   2,
+	"floor": 1,
+}
+
+/* ---------------- parser ---------------- */
+
+type token struct {
+	kind string // "num", "str", "ident", "op", "eof"
+	text string
+	pos  int
+}
+
+func tokenize(src string) ([]token, error) {
+	var toks []token
+	i := 0
+	for i < len(src) {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsDigit(c) || (c == '.' && i+1 < len(src) && unicode.IsDigit(rune(src[i+1]))):
+			start := i
+			for i < len(src) && (unicode.IsDigit(rune(src[i])) || src[i] == '.') {
+				i++
+			}
+			toks = append(toks, token{"num", src[start:i], start})
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(src) && (unicode.IsLetter(rune(src[i])) || unicode.IsDigit(rune(src[i])) || src[i] == '_') {
+				i++
+			}
+			toks = append(toks, token{"ident", src[start:i], start})
+		case c == '"':
+			start := i
+			i++
+			for i < len(src) && src[i] != '"' {
+				i++
+			}
+			if i >= len(src) {
+				return nil, fmt.Errorf("unterminated string at %d", start)
+			}
+			i++
+			toks = append(toks, token{"str", src[start+1 : i-1], start})
+		default:
+			two := ""
+			if i+1 < len(src) {
+				two = src[i : i+2]
+			}
+			switch two {
+			case "&&", "||", "==", "!=", "<=", ">=":
+				toks = append(toks, token{"op", two, i})
+				i += 2
+				continue
+			}
+			if strings.ContainsRune("<>!+-*/(),", c) {
+				toks = append(toks, token{"op", string(c), i})
+				i++
+				continue
+			}
+			return nil, fmt.Errorf("unexpected %q at %d", c, i)
+		}
+	}
+	return append(toks, token{"eof", "", len(src)}), nil
+}
+
+type parser struct {
+	toks  []token
+	pos   int
+	known func(string) bool
+}
+
+// compileExpr parses src; known reports whether an identifier names a
+// feature, so typos fail at load time instead of silently evaluating to 0.
+func compileExpr(src string, known func(string) bool) (expr, error) {
+	toks, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks, known: known}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != "eof" {
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+	return e, nil
+}
+
+func (p *parser) peek() token { return p.toks[p.pos] }
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != "eof" {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) acceptOp(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != "op" {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) parseOr() (expr, error) {
+	return p.parseBinary(p.parseAnd, "||")
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	return p.parseBinary(p.parseCompare, "&&")
+}
+
+func (p *parser) parseCompare() (expr, error) {
+	return p.parseBinary(p.parseAdd, "==", "!=", "<=", ">=", "<", ">")
+}
+
+func (p *parser) parseAdd() (expr, error) {
+	return p.parseBinary(p.parseMul, "+", "-")
+}
+
+func (p *parser) parseMul() (expr, error) {
+	return p.parseBinary(p.parseUnary, "*", "/")
+}
+
+func (p *parser) parseBinary(sub func() (expr, error), ops ...string) (expr, error) {
+	l, err := sub()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.acceptOp(ops...)
+		if !ok {
+			return l, nil
+		}
+		r, err := sub()
+		if err != nil {
+			return nil, err
+		}
+		l = binExpr{op: op, l: l, r: r}
+	}
+}
+
+func (p *parser) parseUnary() (expr, error) {
+	if op, ok := p.acceptOp("!", "-"); ok {
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if op == "!" {
+			return notExpr{x}, nil
+		}
+		return negExpr{x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (expr, error) {
+	t := p.next()
+	switch t.kind {
+	case "num":
+		v, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad number %q at %d", t.text, t.pos)
+		}
+		return litExpr{numVal(v)}, nil
+	case "str":
+		return litExpr{strVal(t.text)}, nil
+	case "ident":
+		switch t.text {
+		case "true":
+			return litExpr{boolVal(true)}, nil
+		case "false":
+			return litExpr{boolVal(false)}, nil
+		}
+		if arity, ok := exprFuncs[t.text]; ok {
+			return p.parseCall(t, arity)
+		}
+		if p.known != nil && !p.known(t.text) {
+			return nil, fmt.Errorf("unknown feature %q", t.text)
+		}
+		return identExpr{t.text}, nil
+	case "op":
+		if t.text == "(" {
+			e, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := p.acceptOp(")"); !ok {
+				return nil, fmt.Errorf("missing ')' at %d", p.peek().pos)
+			}
+			return e, nil
+		}
+	case "eof":
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+}
+
+func (p *parser) parseCall(name token, arity int) (expr, error) {
+	if _, ok := p.acceptOp("("); !ok {
+		return nil, fmt.Errorf("%s: expected '(' at %d", name.text, p.peek().pos)
+	}
+	var args []expr
+	for {
+		a, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, a)
+		if _, ok := p.acceptOp(","); !ok {
+			break
+		}
+	}
+	if _, ok := p.acceptOp(")"); !ok {
+		return nil, fmt.Errorf("%s: missing ')' at %d", name.text, p.peek().pos)
+	}
+	if len(args) != arity {
+		return nil, fmt.Errorf("%s takes %d argument(s), got %d", name.text, arity, len(args))
+	}
+	return callExpr{fn: name.text, args: args}, nil
+}
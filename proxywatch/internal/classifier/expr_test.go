@@ -0,0 +1,135 @@
+package classifier
+
+import (
+	"strings"
+	"testing"
+)
+
+func testFeatures() featureSet {
+	return featureSet{
+		"outTotal":        numVal(4),
+		"distinctTargets": numVal(2),
+		"controlSecs":     numVal(125),
+		"hasListener":     boolVal(true),
+		"internalLateral": boolVal(false),
+		"role":            strVal("outbound-only"),
+		"baseRole":        strVal(""),
+	}
+}
+
+func TestExprEval(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"1 + 2 * 3", "7"},
+		{"(1 + 2) * 3", "9"},
+		{"10 - 4 - 3", "3"},
+		{"12 / 4 / 3", "1"},
+		{"7 / 0", "0"},
+		{"-outTotal + 1", "-3"},
+		{"--2", "2"},
+		{"outTotal >= 3 && distinctTargets < 5", "true"},
+		{"outTotal > 4 || internalLateral", "false"},
+		{"!internalLateral && hasListener", "true"},
+		{"1 || 0 && 0", "true"},
+		{"outTotal == 4 == true", "true"},
+		{"hasListener + hasListener", "2"},
+		{`role == "outbound-only"`, "true"},
+		{`role != "outbound-only"`, "false"},
+		{`role < "z"`, "false"},
+		{`baseRole`, ""},
+		{"60 + min(floor(controlSecs / 10) * 5, 40)", "100"},
+		{"max(outTotal, distinctTargets * 3)", "6"},
+		{"floor(2.75)", "2"},
+		{"missing", "0"},
+		{"true", "true"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			e, err := compileExpr(tt.src, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := e.eval(testFeatures()).String(); got != tt.want {
+				t.Errorf("%s = %s, want %s", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExprTruthy(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"0", false},
+		{"0.5", true},
+		{`""`, false},
+		{`"x"`, true},
+		{"role", true},
+		{"baseRole", false},
+		{"internalLateral", false},
+	}
+	for _, tt := range tests {
+		e, err := compileExpr(tt.src, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := e.eval(testFeatures()).truthy(); got != tt.want {
+			t.Errorf("%s truthy = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestExprParseErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"", "unexpected end"},
+		{"outTotal >=", "unexpected end"},
+		{`role == "outbound`, "unterminated string"},
+		{"outTotal # 3", `unexpected '#'`},
+		{"(outTotal + 1", "missing ')'"},
+		{"outTotal + 1)", `unexpected ")"`},
+		{"outTotal 3", `unexpected "3"`},
+		{"min(1)", "min takes 2 argument(s), got 1"},
+		{"floor(1, 2)", "floor takes 1 argument(s), got 2"},
+		{"max 1", "max: expected '('"},
+		{"min(1, 2", "min: missing ')'"},
+		{"outTotl > 0", `unknown feature "outTotl"`},
+		{"1..2", `bad number "1..2"`},
+		{"&& outTotal", `unexpected "&&"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := compileExpr(tt.src, isKnownFeature)
+			if err == nil {
+				t.Fatalf("compileExpr(%q) succeeded", tt.src)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("compileExpr(%q) = %v, want %q", tt.src, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFirstIdent(t *testing.T) {
+	tests := map[string]string{
+		"reverseTransport && localCount > 0": "reverseTransport",
+		"60 + min(outLongLived * 5, 25)":     "outLongLived",
+		"!-controlSecs":                      "controlSecs",
+		`"x" == role`:                        "role",
+		"1 + 2":                              "",
+	}
+	for src, want := range tests {
+		e, err := compileExpr(src, isKnownFeature)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := firstIdent(e); got != want {
+			t.Errorf("firstIdent(%s) = %q, want %q", src, got, want)
+		}
+	}
+}
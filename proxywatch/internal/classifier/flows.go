@@ -0,0 +1,118 @@
+package classifier
+
+import (
+	"sort"
+	"time"
+
+	"proxywatch/internal/shared"
+)
+
+type flowEnd struct {
+	conn  shared.ConnectionInfo
+	first time.Time
+}
+
+// pairFlows matches inbound sessions of a relay with the outbound connections
+// they feed. Listener relays pair each outbound connection with the inbound
+// session that appeared closest before it; reverse proxies route everything
+// through the control channel.
+func (e *Engine) pairFlows(c *shared.Candidate, ports map[int]struct{}, now time.Time) []shared.FlowPair {
+	var inbound, outbound []flowEnd
+
+	var controlKey *shared.ConnKey
+	if c.Role == "reverse-proxy" && c.ControlChannel != nil {
+		k := connKeyFromConn(c.Proc.Pid, *c.ControlChannel)
+		controlKey = &k
+	}
+
+	for _, cn := range c.Conns {
+		if !isEstablishedState(cn.State) {
+			continue
+		}
+		if cn.RemoteAddress == "" || shared.IsWildcardIP(cn.RemoteAddress) {
+			continue
+		}
+
+		key := connKeyFromConn(c.Proc.Pid, cn)
+		first, ok := e.connFirstSeen[key]
+		if !ok {
+			first = now
+		}
+		end := flowEnd{conn: cn, first: first}
+
+		if _, ok := ports[cn.LocalPort]; ok {
+			inbound = append(inbound, end)
+			continue
+		}
+		if controlKey != nil && key == *controlKey {
+			continue
+		}
+		if shared.IsLoopbackIP(cn.RemoteAddress) {
+			continue
+		}
+		outbound = append(outbound, end)
+	}
+
+	sort.Slice(outbound, func(i, j int) bool {
+		return outbound[i].first.Before(outbound[j].first)
+	})
+
+	var pairs []shared.FlowPair
+	switch {
+	case c.Role == "reverse-proxy" && controlKey != nil:
+		ctrl := c.ControlChannel
+		first := e.connFirstSeen[*controlKey]
+		for _, out := range outbound {
+			pairs = append(pairs, shared.FlowPair{
+				ClientAddr: ctrl.RemoteAddress,
+				ClientPort: ctrl.RemotePort,
+				ProxyAddr:  ctrl.LocalAddress,
+				ProxyPort:  ctrl.LocalPort,
+				TargetAddr: out.conn.RemoteAddress,
+				TargetPort: out.conn.RemotePort,
+				GapSeconds: out.first.Sub(first).Seconds(),
+				Via:        "control-channel",
+			})
+		}
+
+	case c.Role == "proxy-listener" && len(inbound) > 0:
+		window := e.thresholds.FlowPairWindow
+		used := make([]int, len(inbound))
+		for _, out := range outbound {
+			best := -1
+			var bestGap time.Duration
+			for i, in := range inbound {
+				gap := out.first.Sub(in.first)
+				// the outbound leg cannot open before the client connects,
+				// but both may land in the same sample
+				if gap < -window/4 || gap > window {
+					continue
+				}
+				if gap < 0 {
+					gap = -gap
+				}
+				if best == -1 || gap < bestGap || (gap == bestGap && used[i] < used[best]) {
+					best = i
+					bestGap = gap
+				}
+			}
+			if best == -1 {
+				continue
+			}
+			used[best]++
+			in := inbound[best]
+			pairs = append(pairs, shared.FlowPair{
+				ClientAddr: in.conn.RemoteAddress,
+				ClientPort: in.conn.RemotePort,
+				ProxyAddr:  in.conn.LocalAddress,
+				ProxyPort:  in.conn.LocalPort,
+				TargetAddr: out.conn.RemoteAddress,
+				TargetPort: out.conn.RemotePort,
+				GapSeconds: out.first.Sub(in.first).Seconds(),
+				Via:        "listener",
+			})
+		}
+	}
+
+	return pairs
+}
@@ -0,0 +1,143 @@
+package classifier_test
+
+import (
+	"testing"
+
+	"proxywatch/internal/classifier"
+	"proxywatch/internal/scenario"
+	"proxywatch/internal/shared"
+)
+
+// goldenScenarios exercise rules the built-in scenarios do not reach.
+const goldenScenarios = `
+scenario golden-fanout
+  describe SOCKS server with three clients fanning out to nine external targets
+  duration 60s
+  process 300 microsocks
+  at 0s  listen 300 0.0.0.0:1080
+  at 5s  accept 300 1080 from 10.0.0.50
+  at 5s  accept 300 1080 from 10.0.0.51
+  at 5s  accept 300 1080 from 10.0.0.52
+  at 5s  connect 300 93.184.216.34:443
+  at 5s  connect 300 93.184.216.35:8443
+  at 5s  connect 300 93.184.216.36:80
+  at 5s  connect 300 151.101.1.69:443
+  at 5s  connect 300 151.101.1.70:22
+  at 5s  connect 300 151.101.1.71:25
+  at 5s  connect 300 198.51.100.1:993
+  at 5s  connect 300 198.51.100.2:995
+  at 5s  connect 300 198.51.100.3:5222
+
+scenario golden-chain
+  describe browser through a local SOCKS client into a tunnel agent
+  duration 90s
+  process 310 browser
+  process 311 socks-client
+  process 312 tunnel-agent
+  at 0s listen 311 127.0.0.1:1080
+  at 0s listen 312 127.0.0.1:9050
+  at 0s connect 312 198.51.100.50:443
+  at 5s connect 311 127.0.0.1:9050
+  at 5s connect 310 127.0.0.1:1080
+  at 5s connect 310 127.0.0.1:1080
+
+scenario golden-internal-control
+  describe implant on a benign port that also reaches internal SMB
+  duration 120s
+  process 320 svchost-fake
+  at 0s  connect 320 203.0.113.60:443
+  at 30s connect 320 10.0.5.10:445
+
+scenario golden-wide-outbound
+  describe process with six external connections to five hosts
+  duration 60s
+  process 330 updater
+  at 0s connect 330 93.184.216.34:443
+  at 0s connect 330 93.184.216.34:443
+  at 0s connect 330 151.101.1.69:443
+  at 0s connect 330 151.101.1.70:443
+  at 0s connect 330 198.51.100.1:443
+  at 0s connect 330 198.51.100.2:443
+`
+
+// goldenScores were recorded from the hard-coded scorer the rule set
+// replaced. Scenarios for detections added since (scanner, beacon) and the
+// mDNS resolver, which lost a false udp-dns-offport on purpose, are not
+// listed.
+var goldenScores = []struct {
+	scenario string
+	pid      int
+	role     string
+	score    int
+}{
+	{"reverse-control", 100, "reverse-control", 60},
+	{"reverse-transport", 110, "reverse-transport", 110},
+	{"reverse-proxy", 120, "reverse-proxy", 170},
+	{"reverse-proxy-fanout", 130, "reverse-proxy", 135},
+	{"proxy-listener", 150, "proxy-listener", 50},
+	{"listener-with-clients", 160, "listener-with-clients", 30},
+	{"listener-with-outbound", 170, "listener-with-outbound", 25},
+	{"listener-only", 180, "listener-only", 0},
+	{"outbound-only", 190, "outbound-only", 30},
+	{"no-network-activity", 200, "no-network-activity", 0},
+	{"udp-tunnel", 210, "udp-tunnel", 60},
+	{"udp-relay", 220, "udp-relay", 50},
+	{"tunnel-likely", 230, "tunnel-likely", 65},
+	{"dns-offport", 250, "no-network-activity", 30},
+	{"golden-fanout", 300, "proxy-listener", 295},
+	{"golden-chain", 310, "no-network-activity", 20},
+	{"golden-chain", 311, "listener-only", 15},
+	{"golden-chain", 312, "listener-with-outbound", 55},
+	{"golden-internal-control", 320, "reverse-proxy", 90},
+	{"golden-wide-outbound", 330, "outbound-only", 30},
+}
+
+func TestDefaultRulesMatchHardCodedScores(t *testing.T) {
+	extra, err := scenario.Parse([]byte(goldenScenarios), "golden.scn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := append(scenario.Builtin(), extra...)
+
+	results := make(map[string]*scenario.Result)
+	for _, g := range goldenScores {
+		res, ok := results[g.scenario]
+		if !ok {
+			s := scenario.Find(list, g.scenario)
+			if s == nil {
+				t.Fatalf("no scenario %s", g.scenario)
+			}
+			res = scenario.Run(s, classifier.NewEngine(shared.DefaultThresholds()))
+			results[g.scenario] = res
+		}
+		c, ok := res.Final[g.pid]
+		if !ok {
+			t.Errorf("%s: pid %d not reported", g.scenario, g.pid)
+			continue
+		}
+		if c.Role != g.role || c.Score != g.score {
+			t.Errorf("%s: pid %d is %s/%d, want %s/%d", g.scenario, g.pid, c.Role, c.Score, g.role, g.score)
+		}
+	}
+}
+
+// the breakdown shown in the inspector adds up to the score for every
+// candidate, whichever floor, cap, set and stop rules fired
+func TestContributionsSumToScore(t *testing.T) {
+	extra, err := scenario.Parse([]byte(goldenScenarios), "golden.scn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range append(scenario.Builtin(), extra...) {
+		res := scenario.Run(s, classifier.NewEngine(shared.DefaultThresholds()))
+		for pid, c := range res.Final {
+			sum := 0
+			for _, contrib := range c.Contributions {
+				sum += contrib.Delta
+			}
+			if sum != c.Score {
+				t.Errorf("%s: pid %d contributions sum to %d, score is %d", s.Name, pid, sum, c.Score)
+			}
+		}
+	}
+}
@@ -33,6 +33,15 @@ func candidateSignature(c shared.Candidate) shared.CandidateSignature {
 		h = fnvAddString(h, cn.State)
 		connHash ^= h
 	}
+	for _, u := range c.UDPConns {
+		h := fnvOffset64
+		h = fnvAddString(h, "udp")
+		h = fnvAddString(h, u.LocalAddress)
+		h = fnvAddUint64(h, uint64(u.LocalPort))
+		h = fnvAddString(h, u.RemoteAddress)
+		h = fnvAddUint64(h, uint64(u.RemotePort))
+		connHash ^= h
+	}
 
 	procHash := fnvOffset64
 	if c.Proc != nil {
@@ -52,6 +61,8 @@ func candidateSignature(c shared.Candidate) shared.CandidateSignature {
 func reuseCandidate(dst, src *shared.Candidate) {
 	dst.Score = src.Score
 	dst.Confidence = src.Confidence
+	dst.Contributions = append(dst.Contributions[:0], src.Contributions...)
+	dst.Features = src.Features
 	dst.Reasons = append(dst.Reasons[:0], src.Reasons...)
 	dst.Signals = append(dst.Signals[:0], src.Signals...)
 	dst.Role = src.Role
@@ -64,6 +75,37 @@ func reuseCandidate(dst, src *shared.Candidate) {
 	dst.OutLongLived = src.OutLongLived
 	dst.OutShortLived = src.OutShortLived
 	dst.InboundTotal = src.InboundTotal
+	dst.Flows = append(dst.Flows[:0], src.Flows...)
+	dst.TransferBps = src.TransferBps
+	if src.BusiestConn != nil {
+		tmp := *src.BusiestConn
+		dst.BusiestConn = &tmp
+	} else {
+		dst.BusiestConn = nil
+	}
+	dst.UDPInbound = src.UDPInbound
+	dst.UDPOutbound = src.UDPOutbound
+	dst.UDPPeerSeconds = src.UDPPeerSeconds
+	dst.UDPDNSOffPort = src.UDPDNSOffPort
+	dst.UDPRelay = src.UDPRelay
+	if src.UDPPeer != nil {
+		tmp := *src.UDPPeer
+		dst.UDPPeer = &tmp
+	} else {
+		dst.UDPPeer = nil
+	}
+	if src.Beacon != nil {
+		tmp := *src.Beacon
+		dst.Beacon = &tmp
+	} else {
+		dst.Beacon = nil
+	}
+	if src.Scan != nil {
+		tmp := *src.Scan
+		dst.Scan = &tmp
+	} else {
+		dst.Scan = nil
+	}
 	dst.ControlDurationSeconds = src.ControlDurationSeconds
 	if src.ControlChannel != nil {
 		tmp := *src.ControlChannel
@@ -73,17 +115,17 @@ func reuseCandidate(dst, src *shared.Candidate) {
 	}
 }
 
-func touchHistoryFromCandidate(c *shared.Candidate, now time.Time) {
+func (e *Engine) touchHistoryFromCandidate(c *shared.Candidate, now time.Time) {
 	if c == nil || c.Proc == nil {
 		return
 	}
-	hist := getHistory(c.Proc.Pid, now)
+	hist := e.getHistory(c.Proc.Pid, now)
 
 	if c.InboundTotal > 0 {
-		shared.RecentClientSeen[c.Proc.Pid] = now
+		e.recentClientSeen[c.Proc.Pid] = now
 	}
 	if c.OutTotal > 0 {
-		shared.RecentOutboundSeen[c.Proc.Pid] = now
+		e.recentOutboundSeen[c.Proc.Pid] = now
 	}
 
 	if c.ActiveProxying {
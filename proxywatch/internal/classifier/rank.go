@@ -2,13 +2,15 @@ package classifier
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
+	"proxywatch/internal/baseline"
+	"proxywatch/internal/fingerprint"
 	"proxywatch/internal/shared"
 )
 
-func ScoreCandidate(c *shared.Candidate) {
-	scoreVal := 0
+func (e *Engine) scoreCandidate(c *shared.Candidate, now time.Time) {
 	reasons := []string{}
 	signals := []string{}
 	addSignal := func(s string) {
@@ -21,16 +23,25 @@ func ScoreCandidate(c *shared.Candidate) {
 	}
 
 	p := c.Proc
-	now := time.Now()
-	hist := getHistory(p.Pid, now)
-	updateConnHistory(p.Pid, c.Conns, now)
+	hist := e.getHistory(p.Pid, now)
+	e.updateConnHistory(p.Pid, c.Conns, now)
+	e.updateUDPHistory(p.Pid, c.UDPConns, now)
 
 	ports, loopbackOnly, anyWildcard := socksListenerPorts(c.Listeners)
 	hasListener := len(ports) > 0
 
-	activeClients, _ := countActiveClientSessions(c.Conns, ports)
-	outTotal, outExternal, outInternal, outLoopback := outboundTargets(c.Conns, ports)
-	outLongLived, outShortLived := outboundConnAgeStats(c.Conns, ports, now)
+	// a scan's half-open attempts are reported on their own and would
+	// otherwise read as proxied fan-out
+	scan := e.updateScan(p.Pid, c.Conns, now)
+	c.Scan = scan
+	conns := c.Conns
+	if scan != nil {
+		conns = withoutState(c.Conns, "SYN_SENT")
+	}
+
+	activeClients, _ := countActiveClientSessions(conns, ports)
+	outTotal, outExternal, outInternal, outLoopback := outboundTargets(e.zones, conns, ports)
+	outLongLived, outShortLived := e.outboundConnAgeStats(conns, ports, now)
 
 	c.OutTotal = outTotal
 	c.OutExternal = outExternal
@@ -40,13 +51,29 @@ func ScoreCandidate(c *shared.Candidate) {
 	c.OutShortLived = outShortLived
 	c.InboundTotal = activeClients
 
+	beacon := e.updateBeacons(p.Pid, c.Conns, ports, now)
+	c.Beacon = beacon
+
+	udp := e.udpFlowSummary(c, now)
+	udpLongLived := e.longLivedPeer(udp)
+	udpDNSOffPort := dnsOffPort(udp)
+	udpRelaying := udpRelay(udp, activeClients)
+	c.UDPInbound = udp.inbound
+	c.UDPOutbound = udp.outbound
+	c.UDPDNSOffPort = udpDNSOffPort
+	c.UDPRelay = udpRelaying
+	if udpLongLived {
+		c.UDPPeer = udp.peer
+		c.UDPPeerSeconds = udp.peerSecs
+	}
+
 	if activeClients > 0 {
 		addSignal("inbound-active")
-		shared.RecentClientSeen[p.Pid] = now
+		e.recentClientSeen[p.Pid] = now
 	}
 	if outTotal > 0 {
 		addSignal("outbound-active")
-		shared.RecentOutboundSeen[p.Pid] = now
+		e.recentOutboundSeen[p.Pid] = now
 	}
 	if outInternal > 0 {
 		addSignal("outbound-internal")
@@ -63,35 +90,71 @@ func ScoreCandidate(c *shared.Candidate) {
 	if outShortLived > 0 && outLongLived == 0 {
 		addSignal("outbound-bursty")
 	}
+	if udp.outbound > 0 {
+		addSignal("udp-outbound")
+		// plain DNS lookups are not forwarding
+		if udp.outbound > udp.dnsOut {
+			e.recentOutboundSeen[p.Pid] = now
+		}
+	}
+	if udp.inbound > 0 {
+		addSignal("udp-inbound")
+		e.recentClientSeen[p.Pid] = now
+	}
+	if udpLongLived {
+		addSignal("udp-long-lived-peer")
+	}
+	if udpDNSOffPort {
+		addSignal("udp-dns-offport")
+	}
+	if udpRelaying {
+		addSignal("udp-relay")
+	}
+	if beacon != nil {
+		addSignal("beacon")
+	}
+	if scan != nil {
+		addSignal("scanner")
+	}
 
 	inboundRecent := activeClients > 0
-	if t, ok := shared.RecentClientSeen[p.Pid]; ok && now.Sub(t) <= shared.ActiveWindow {
+	if t, ok := e.recentClientSeen[p.Pid]; ok && now.Sub(t) <= e.thresholds.ActiveWindow {
 		inboundRecent = true
 	}
 
 	outboundRecent := outTotal > 0
-	if t, ok := shared.RecentOutboundSeen[p.Pid]; ok && now.Sub(t) <= shared.ActiveWindow {
+	if t, ok := e.recentOutboundSeen[p.Pid]; ok && now.Sub(t) <= e.thresholds.ActiveWindow {
 		outboundRecent = true
 	}
 
 	forwardActiveNow := hasListener && inboundRecent && outboundRecent
 
-	controlConn, controlSecs := findPersistentControl(p.Pid, c.Conns, now)
+	controlConn, controlSecs := e.findPersistentControl(p.Pid, conns, now)
 	if controlConn != nil {
 		addSignal("control-channel")
 		c.ControlChannel = controlConn
 		c.ControlDurationSeconds = controlSecs
 	}
 
+	busiest, transferBps := busiestConn(conns)
+	c.BusiestConn = busiest
+	c.TransferBps = transferBps
+	controlCarries := controlConn != nil && busiest != nil &&
+		connKeyFromConn(p.Pid, *controlConn) == connKeyFromConn(p.Pid, *busiest) &&
+		busiest.SendBps+busiest.RecvBps >= e.thresholds.ActiveTransferBps
+	if controlCarries {
+		addSignal("control-carries-traffic")
+	}
+
 	reverseProxyNow := false
 
-	outboundActive, distinctTargets, distinctTargetPorts := outboundActivity(c.Conns, ports)
-	internalTargets, internalPorts, internalLateral := outboundInternalSummary(c.Conns, ports)
+	outboundActive, distinctTargets, distinctTargetPorts := outboundActivity(conns, ports)
+	internalTargets, internalPorts, internalLateral := outboundInternalSummary(e.zones, conns, ports)
 	reverseTunnelEligible := internalLateral ||
-		len(internalTargets) >= shared.MinInternalTargetsForRev ||
-		len(internalPorts) >= shared.MinInternalPortsForRev
+		len(internalTargets) >= e.thresholds.MinInternalTargetsForRev ||
+		len(internalPorts) >= e.thresholds.MinInternalPortsForRev
 
-	localTransport, localCount := localTransportActivity(c.Conns)
+	localTransport, localCount := localTransportActivity(conns)
 	if localTransport {
 		addSignal("loopback-transport")
 	}
@@ -100,7 +163,7 @@ func ScoreCandidate(c *shared.Candidate) {
 
 	if controlConn != nil && !hasListener {
 		controlKey := connKeyFromConn(p.Pid, *controlConn)
-		proxyOutTotal, _, _ := outboundTargetsExcluding(c.Conns, ports, &controlKey)
+		proxyOutTotal, _, _ := outboundTargetsExcluding(e.zones, conns, ports, &controlKey)
 		if proxyOutTotal > 0 && reverseTunnelEligible {
 			reverseProxyNow = true
 		}
@@ -121,192 +184,246 @@ func ScoreCandidate(c *shared.Candidate) {
 	if reverseProxyNow {
 		hist.LastSuspicious = now
 		hist.SuspicionKind = shared.SuspicionProxy
-		if hist.StickyScore < shared.ReverseStickyScore {
-			hist.StickyScore = shared.ReverseStickyScore
+		hist.SuspicionHalfOpen = onlyHalfOpenOutbound(conns, ports)
+		if hist.StickyScore < e.thresholds.ReverseStickyScore {
+			hist.StickyScore = e.thresholds.ReverseStickyScore
 		}
 	} else if forwardActiveNow {
-		if hist.StickyScore < shared.ForwardStickyScore {
-			hist.StickyScore = shared.ForwardStickyScore
+		if hist.StickyScore < e.thresholds.ForwardStickyScore {
+			hist.StickyScore = e.thresholds.ForwardStickyScore
 		}
 	}
 
-	activeRecent := !hist.LastActive.IsZero() && now.Sub(hist.LastActive) <= shared.ActiveHoldWindow
-	suspiciousRecent := !hist.LastSuspicious.IsZero() && now.Sub(hist.LastSuspicious) <= shared.SuspicionWindow
+	// a proxy suspicion raised before the scan thresholds were crossed
+	// rested on scan traffic alone
+	if scan != nil && !reverseProxyNow &&
+		hist.SuspicionKind == shared.SuspicionProxy && hist.SuspicionHalfOpen {
+		hist.SuspicionKind = shared.SuspicionNone
+		hist.SuspicionHalfOpen = false
+		hist.LastSuspicious = time.Time{}
+		hist.LastActive = time.Time{}
+		hist.StickyScore = 0
+	}
+
+	activeRecent := !hist.LastActive.IsZero() && now.Sub(hist.LastActive) <= e.thresholds.ActiveHoldWindow
+	suspiciousRecent := !hist.LastSuspicious.IsZero() && now.Sub(hist.LastSuspicious) <= e.thresholds.SuspicionWindow
 
 	activeProxying := forwardActiveNow || reverseProxyNow || activeRecent
 
 	// ---------------- Reverse control detection ----------------
 	reverseControl := false
 	if !hasListener && outTotal == 1 && len(distinctTargets) == 1 && controlConn != nil {
-		if isLikelyBenignControlPort(controlConn.RemotePort) && !internalLateral && outInternal == 0 {
-			reverseControl = false
-		} else {
-			reverseControl = true
-		}
-		if reverseControl {
-			if localTransport {
-				scoreVal = 60 + min((controlSecs/10)*5, 40)
-				if localCount > 0 {
-					scoreVal += 20
-					if localCount > 3 {
-						scoreVal += 20
-					}
-				}
-
-				c.Score = scoreVal
-				c.Role = "reverse-transport"
-				c.ActiveProxying = true
-				c.ControlChannel = controlConn
-				c.ControlDurationSeconds = controlSecs
-				c.Reasons = []string{
-					"Persistent reverse control channel with local transport activity",
-				}
-				addSignal("reverse-transport")
-				c.Signals = signals
-				c.Confidence = confidenceFor(c.Role, c.Score, c.ActiveProxying)
-				return
-			}
-		}
+		reverseControl = !(e.isLikelyBenignControlPort(controlConn.RemotePort) && !internalLateral && outInternal == 0)
 	}
-	if reverseControl {
+	reverseTransport := reverseControl && localTransport
+	if reverseControl && !reverseTransport {
 		addSignal("reverse-control")
 	}
 
-	// ---------------- Heuristics ----------------
-
-	if hasListener {
-		scoreVal += 5
-		addSignal("listener")
-		reasons = append(reasons, "Process has TCP listener(s)")
-		if loopbackOnly {
-			addSignal("listener-loopback")
-			reasons = append(reasons, "Listener is loopback-only")
-		}
-		if anyWildcard {
-			addSignal("listener-wildcard")
-			reasons = append(reasons, "Listener bound to wildcard address")
-		}
-	}
-
-	if outboundActive >= 2 {
-		scoreVal += 15
-	}
-	if outboundActive >= 4 {
-		scoreVal += 25
-	}
-	if outboundActive >= 8 {
-		scoreVal += 40
-	}
-
-	if outLongLived > 0 {
-		scoreVal += 10
-		reasons = append(reasons, "Long-lived outbound connection(s)")
-	}
-
-	if outTotal > 0 {
-		scoreVal += 20
-	}
-	if outTotal >= 3 {
-		scoreVal += 30
-	}
-	if outTotal >= 6 {
-		scoreVal += 50
-	}
-
-	if len(distinctTargets) >= 2 {
-		scoreVal += 20
-	}
-	if len(distinctTargets) >= 5 {
-		scoreVal += 40
-	}
+	// ---------------- Role ----------------
+	c.ActiveProxying = activeProxying
+	role := deriveRole(hasListener, activeClients, outTotal)
 
-	if len(distinctTargetPorts) >= 3 {
-		scoreVal += 25
+	if tunnelLikely && !reverseProxyNow && !reverseControl {
+		role = "tunnel-likely"
+		c.ActiveProxying = true
+		addSignal("tunnel-likely")
 	}
 
-	if activeClients > 0 {
-		scoreVal += 25
+	if udpRelaying && rolePriority(role) < rolePriority("udp-relay") && !reverseProxyNow && !reverseControl {
+		role = "udp-relay"
+		c.ActiveProxying = true
 	}
 
-	if internalLateral {
-		addSignal("internal-lateral")
-		scoreVal += 25
+	if udpLongLived && !hasListener && rolePriority(role) < rolePriority("udp-tunnel") && !reverseProxyNow && !reverseControl {
+		role = "udp-tunnel"
+		c.ActiveProxying = true
+		addSignal("udp-tunnel")
 	}
 
-	if hasListener && activeClients == 0 && outTotal == 0 {
-		scoreVal -= 10
+	if beacon != nil && rolePriority(role) < rolePriority("beacon") && !reverseProxyNow && !reverseControl {
+		role = "beacon"
 	}
 
-	if scoreVal < 0 {
-		scoreVal = 0
+	if scan != nil && rolePriority(role) < rolePriority("scanner") && !reverseProxyNow && !reverseControl {
+		role = "scanner"
 	}
 
-	c.Score = scoreVal
-	c.Reasons = reasons
-	c.ActiveProxying = activeProxying
-	c.Role = deriveRole(hasListener, activeClients, outTotal, reverseTunnelEligible)
+	baseRole := role
 
-	if tunnelLikely && !reverseProxyNow && !reverseControl {
-		c.Role = "tunnel-likely"
+	switch {
+	case reverseTransport:
+		role = "reverse-transport"
 		c.ActiveProxying = true
-		addSignal("tunnel-likely")
-		base := 60 + min(outLongLived*5, 25)
-		if c.Score < base {
-			c.Score = base
-		}
-		c.Reasons = append(c.Reasons, "Long-lived outbound connection with local loopback transport")
-	}
-
-	if c.Role == "outbound-only" &&
-		outInternal == 0 &&
-		!hasListener &&
-		!reverseProxyNow &&
-		!reverseControl {
-		if c.Score > shared.OutboundOnlyExternalCap {
-			c.Score = shared.OutboundOnlyExternalCap
-			c.Reasons = append(c.Reasons, "External-only outbound traffic de-emphasized")
-		}
-	}
-
-	if reverseProxyNow || (suspiciousRecent && hist.SuspicionKind == shared.SuspicionProxy) {
-		c.Role = "reverse-proxy"
-		if hist.StickyScore > c.Score {
-			c.Score = hist.StickyScore
-		}
-		if reverseProxyNow {
-			c.Reasons = append(c.Reasons, "Persistent control channel with proxied outbound activity")
-		}
+		addSignal("reverse-transport")
+	case reverseProxyNow || (suspiciousRecent && hist.SuspicionKind == shared.SuspicionProxy):
+		role = "reverse-proxy"
 		addSignal("reverse-proxy")
-	} else if reverseControl || (suspiciousRecent && hist.SuspicionKind == shared.SuspicionControl) {
-		c.Role = "reverse-control"
+	case reverseControl || (suspiciousRecent && hist.SuspicionKind == shared.SuspicionControl):
+		role = "reverse-control"
 		c.ActiveProxying = false
-		c.Reasons = []string{
-			"Persistent reverse control channel detected",
-		}
-
 		if reverseControl {
-			base := controlStickyScore(controlSecs)
+			base := e.controlStickyScore(controlSecs)
 			if hist.StickyScore < base {
 				hist.StickyScore = base
 			}
 			hist.LastSuspicious = now
 			hist.SuspicionKind = shared.SuspicionControl
 		}
-		if hist.StickyScore > c.Score {
-			c.Score = hist.StickyScore
-		}
 		addSignal("reverse-control")
 	}
 
+	if c.Chain != nil {
+		addSignal("loopback-chain")
+	}
+
+	var fingerprints []fingerprint.Match
+	if e.fingerprints != nil {
+		fingerprints = e.fingerprints.Match(c, role, signals)
+	}
+	for _, m := range fingerprints {
+		addSignal("fingerprint:" + m.Tool)
+		reasons = append(reasons, fmt.Sprintf("Matches %s fingerprint (%s)", m.Tool, strings.Join(m.Indicators, ", ")))
+	}
+
+	var (
+		deviations    []baseline.Deviation
+		baselineKnown bool
+	)
+	if e.baseline != nil {
+		deviations, baselineKnown = e.baseline.Check(c, e.zones)
+	}
+	deviated := make(map[string]bool, len(deviations))
+	for _, d := range deviations {
+		addSignal("deviation")
+		addSignal("deviation:" + d.Kind)
+		reasons = append(reasons, d.Detail)
+		deviated[d.Kind] = true
+	}
+
+	// ---------------- Scoring rules ----------------
+	beaconCount, beaconPeriod, beaconJitter := 0, 0.0, 0.0
+	if beacon != nil {
+		beaconCount = beacon.Count
+		beaconPeriod = beacon.MeanSeconds
+		beaconJitter = beacon.JitterSeconds / beacon.MeanSeconds
+	}
+
+	scanAttempts, scanSweepHosts, scanPorts := 0, 0, 0
+	if scan != nil {
+		scanAttempts = scan.Attempts
+		scanSweepHosts = scan.SweepHosts
+		scanPorts = scan.ScanPorts
+	}
+
+	chainLength, chainEgress := 0, 0
+	if c.Chain != nil {
+		chainLength = len(c.Chain.Hops)
+		chainEgress = len(c.Chain.Egress)
+	}
+
+	features := featureSet{
+		"hasListener":             boolVal(hasListener),
+		"listenerLoopbackOnly":    boolVal(hasListener && loopbackOnly),
+		"listenerWildcard":        boolVal(hasListener && anyWildcard),
+		"activeClients":           numVal(float64(activeClients)),
+		"outTotal":                numVal(float64(outTotal)),
+		"outExternal":             numVal(float64(outExternal)),
+		"outInternal":             numVal(float64(outInternal)),
+		"outLoopback":             numVal(float64(outLoopback)),
+		"outLongLived":            numVal(float64(outLongLived)),
+		"outShortLived":           numVal(float64(outShortLived)),
+		"outboundActive":          numVal(float64(outboundActive)),
+		"distinctTargets":         numVal(float64(len(distinctTargets))),
+		"distinctTargetPorts":     numVal(float64(len(distinctTargetPorts))),
+		"internalTargets":         numVal(float64(len(internalTargets))),
+		"internalPorts":           numVal(float64(len(internalPorts))),
+		"internalLateral":         boolVal(internalLateral),
+		"reverseTunnelEligible":   boolVal(reverseTunnelEligible),
+		"controlChannel":          boolVal(controlConn != nil),
+		"controlSecs":             numVal(float64(controlSecs)),
+		"controlCarriesTraffic":   boolVal(controlCarries),
+		"transferBps":             numVal(float64(transferBps)),
+		"localTransport":          boolVal(localTransport),
+		"localCount":              numVal(float64(localCount)),
+		"tunnelLikely":            boolVal(tunnelLikely),
+		"forwardActive":           boolVal(forwardActiveNow),
+		"reverseProxyNow":         boolVal(reverseProxyNow),
+		"reverseControl":          boolVal(reverseControl),
+		"reverseTransport":        boolVal(reverseTransport),
+		"activeProxying":          boolVal(c.ActiveProxying),
+		"udpInbound":              numVal(float64(udp.inbound)),
+		"udpOutbound":             numVal(float64(udp.outbound)),
+		"udpLongLivedPeer":        boolVal(udpLongLived),
+		"udpPeerSecs":             numVal(float64(udp.peerSecs)),
+		"udpDnsOffPort":           boolVal(udpDNSOffPort),
+		"udpRelay":                boolVal(udpRelaying),
+		"beacon":                  boolVal(beacon != nil),
+		"beaconCount":             numVal(float64(beaconCount)),
+		"beaconPeriodSecs":        numVal(beaconPeriod),
+		"beaconJitterRatio":       numVal(beaconJitter),
+		"scan":                    boolVal(scan != nil),
+		"scanAttempts":            numVal(float64(scanAttempts)),
+		"scanSweepHosts":          numVal(float64(scanSweepHosts)),
+		"scanPorts":               numVal(float64(scanPorts)),
+		"chainLength":             numVal(float64(chainLength)),
+		"chainEgress":             numVal(float64(chainEgress)),
+		"fingerprint":             boolVal(len(fingerprints) > 0),
+		"fingerprintCount":        numVal(float64(len(fingerprints))),
+		"baselineKnown":           boolVal(baselineKnown),
+		"deviation":               boolVal(len(deviations) > 0),
+		"deviationCount":          numVal(float64(len(deviations))),
+		"newListener":             boolVal(deviated[baseline.NewListener]),
+		"newLateralPort":          boolVal(deviated[baseline.NewLateralPort]),
+		"newRemotePort":           boolVal(deviated[baseline.NewRemotePort]),
+		"moreTargets":             boolVal(deviated[baseline.MoreTargets]),
+		"internalShift":           boolVal(deviated[baseline.InternalShift]),
+		"stickyScore":             numVal(float64(hist.StickyScore)),
+		"baseRole":                strVal(baseRole),
+		"role":                    strVal(role),
+		"outboundOnlyExternalCap": numVal(float64(e.thresholds.OutboundOnlyExternalCap)),
+	}
+
+	var contributions []shared.ScoreContribution
+	c.Score = e.rules.apply(features, func(r *rule, before, delta int) {
+		if r.spec.Signal != "" {
+			addSignal(r.spec.Signal)
+		}
+		if r.spec.Reason != "" {
+			reasons = append(reasons, r.spec.Reason)
+		}
+		if delta == 0 {
+			return
+		}
+		contrib := shared.ScoreContribution{Feature: "score", Value: before, Delta: delta, Rule: r.spec.Name}
+		if r.feature != "" {
+			contrib.Feature = r.feature
+			contrib.Value = features[r.feature].native()
+		}
+		contributions = append(contributions, contrib)
+	})
+	c.Contributions = contributions
+	c.Features = make(map[string]any, len(features))
+	for name, v := range features {
+		c.Features[name] = v.native()
+	}
+	c.Reasons = reasons
+	c.Role = role
+
+	c.Flows = e.pairFlows(c, ports, now)
+	if len(c.Flows) > 0 {
+		addSignal("flow-pairs")
+	}
+
 	c.Signals = signals
 	c.Confidence = confidenceFor(c.Role, c.Score, c.ActiveProxying)
 
-	purgeHistory(now)
+	e.purgeHistory(now)
 }
 
 /* ---------------- helpers ---------------- */
 
-func deriveRole(hasListener bool, clients int, out int, reverseTunnelEligible bool) string {
+func deriveRole(hasListener bool, clients int, out int) string {
 	switch {
 	case hasListener && clients > 0 && out > 0:
 		return "proxy-listener"
@@ -316,8 +433,6 @@ func deriveRole(hasListener bool, clients int, out int, reverseTunnelEligible bo
 		return "listener-with-outbound"
 	case hasListener:
 		return "listener-only"
-	case out >= 3 && reverseTunnelEligible:
-		return "reverse-tunnel"
 	case out > 0:
 		return "outbound-only"
 	default:
@@ -325,6 +440,64 @@ func deriveRole(hasListener bool, clients int, out int, reverseTunnelEligible bo
 	}
 }
 
+// busiestConn picks the connection with the highest current throughput,
+// falling back to lifetime byte counts when no rate is known yet.
+func busiestConn(conns []shared.ConnectionInfo) (*shared.ConnectionInfo, uint64) {
+	var best *shared.ConnectionInfo
+	var bestRate, bestBytes, total uint64
+
+	for i := range conns {
+		cn := &conns[i]
+		rate := cn.SendBps + cn.RecvBps
+		bytes := cn.BytesSent + cn.BytesReceived
+		total += rate
+		if bytes == 0 {
+			continue
+		}
+		if best == nil || rate > bestRate || (rate == bestRate && bytes > bestBytes) {
+			best = cn
+			bestRate = rate
+			bestBytes = bytes
+		}
+	}
+
+	if best == nil {
+		return nil, 0
+	}
+	tmp := *best
+	return &tmp, total
+}
+
+// onlyHalfOpenOutbound reports whether every outbound connection is still in
+// SYN_SENT.
+func onlyHalfOpenOutbound(conns []shared.ConnectionInfo, ports map[int]struct{}) bool {
+	for _, c := range conns {
+		if !isActiveConnState(c.State) || c.State == "SYN_SENT" {
+			continue
+		}
+		if c.RemoteAddress == "" ||
+			shared.IsWildcardIP(c.RemoteAddress) ||
+			shared.IsLoopbackIP(c.RemoteAddress) {
+			continue
+		}
+		if _, ok := ports[c.LocalPort]; ok {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+func withoutState(conns []shared.ConnectionInfo, state string) []shared.ConnectionInfo {
+	out := make([]shared.ConnectionInfo, 0, len(conns))
+	for _, c := range conns {
+		if c.State != state {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
 func socksListenerPorts(listeners []shared.ListenerInfo) (map[int]struct{}, bool, bool) {
 	ports := make(map[int]struct{})
 	loopbackOnly := true
@@ -367,6 +540,7 @@ func countActiveClientSessions(
 }
 
 func outboundTargets(
+	zones *shared.NetworkZones,
 	conns []shared.ConnectionInfo,
 	ports map[int]struct{},
 ) (total, external, internal, loopback int) {
@@ -388,7 +562,7 @@ func outboundTargets(
 		}
 
 		total++
-		if shared.IsInternalIP(c.RemoteAddress) {
+		if zones.IsInternal(c.RemoteAddress) {
 			internal++
 		} else {
 			external++
@@ -427,7 +601,7 @@ func outboundActivity(
 	return
 }
 
-func outboundConnAgeStats(
+func (e *Engine) outboundConnAgeStats(
 	conns []shared.ConnectionInfo,
 	ports map[int]struct{},
 	now time.Time,
@@ -446,15 +620,15 @@ func outboundConnAgeStats(
 		}
 
 		key := connKeyFromConn(c.Pid, c)
-		first, ok := shared.ConnFirstSeen[key]
+		first, ok := e.connFirstSeen[key]
 		if !ok {
 			continue
 		}
 		age := now.Sub(first)
-		if age >= shared.LongLivedOutboundMinAge {
+		if age >= e.thresholds.LongLivedOutboundMinAge {
 			longLived++
 		}
-		if age <= shared.ShortLivedOutboundMaxAge {
+		if age <= e.thresholds.ShortLivedOutboundMaxAge {
 			shortLived++
 		}
 	}
@@ -462,6 +636,7 @@ func outboundConnAgeStats(
 }
 
 func outboundInternalSummary(
+	zones *shared.NetworkZones,
 	conns []shared.ConnectionInfo,
 	ports map[int]struct{},
 ) (internalTargets map[string]struct{}, internalPorts map[int]struct{}, internalLateral bool) {
@@ -480,14 +655,14 @@ func outboundInternalSummary(
 		if _, ok := ports[c.LocalPort]; ok {
 			continue
 		}
-		if !shared.IsInternalIP(c.RemoteAddress) {
+		if !zones.IsInternal(c.RemoteAddress) {
 			continue
 		}
 
 		internalTargets[c.RemoteAddress] = struct{}{}
 		if c.RemotePort > 0 {
 			internalPorts[c.RemotePort] = struct{}{}
-			if shared.LateralPorts[c.RemotePort] {
+			if zones.IsLateral(c.RemotePort) {
 				internalLateral = true
 			}
 		}
@@ -496,6 +671,7 @@ func outboundInternalSummary(
 }
 
 func outboundTargetsExcluding(
+	zones *shared.NetworkZones,
 	conns []shared.ConnectionInfo,
 	ports map[int]struct{},
 	exclude *shared.ConnKey,
@@ -518,7 +694,7 @@ func outboundTargetsExcluding(
 		}
 
 		total++
-		if shared.IsInternalIP(c.RemoteAddress) {
+		if zones.IsInternal(c.RemoteAddress) {
 			internal++
 		} else {
 			external++
@@ -527,11 +703,11 @@ func outboundTargetsExcluding(
 	return
 }
 
-func hasInternalLateral(conns []shared.ConnectionInfo) bool {
+func hasInternalLateral(zones *shared.NetworkZones, conns []shared.ConnectionInfo) bool {
 	for _, c := range conns {
 		if isActiveConnState(c.State) &&
-			shared.IsInternalIP(c.RemoteAddress) &&
-			shared.LateralPorts[c.RemotePort] {
+			zones.IsInternal(c.RemoteAddress) &&
+			zones.IsLateral(c.RemotePort) {
 			return true
 		}
 	}
@@ -563,7 +739,7 @@ func connKeyFromConn(pid int, cn shared.ConnectionInfo) shared.ConnKey {
 	}
 }
 
-func updateConnHistory(pid int, conns []shared.ConnectionInfo, now time.Time) {
+func (e *Engine) updateConnHistory(pid int, conns []shared.ConnectionInfo, now time.Time) {
 	current := make(map[shared.ConnKey]struct{})
 	for _, cn := range conns {
 		if !isEstablishedState(cn.State) {
@@ -571,21 +747,21 @@ func updateConnHistory(pid int, conns []shared.ConnectionInfo, now time.Time) {
 		}
 		key := connKeyFromConn(pid, cn)
 		current[key] = struct{}{}
-		if _, ok := shared.ConnFirstSeen[key]; !ok {
-			shared.ConnFirstSeen[key] = now
+		if _, ok := e.connFirstSeen[key]; !ok {
+			e.connFirstSeen[key] = now
 		}
 	}
 
-	for k := range shared.ConnFirstSeen {
+	for k := range e.connFirstSeen {
 		if k.Pid == pid {
 			if _, ok := current[k]; !ok {
-				delete(shared.ConnFirstSeen, k)
+				delete(e.connFirstSeen, k)
 			}
 		}
 	}
 }
 
-func findPersistentControl(pid int, conns []shared.ConnectionInfo, now time.Time) (*shared.ConnectionInfo, int) {
+func (e *Engine) findPersistentControl(pid int, conns []shared.ConnectionInfo, now time.Time) (*shared.ConnectionInfo, int) {
 	var best *shared.ConnectionInfo
 	var bestAge time.Duration
 
@@ -600,12 +776,12 @@ func findPersistentControl(pid int, conns []shared.ConnectionInfo, now time.Time
 		}
 
 		key := connKeyFromConn(pid, cn)
-		first, ok := shared.ConnFirstSeen[key]
+		first, ok := e.connFirstSeen[key]
 		if !ok {
 			continue
 		}
 		age := now.Sub(first)
-		if age >= shared.ReverseControlMinDuration && age > bestAge {
+		if age >= e.thresholds.ReverseControlMinDuration && age > bestAge {
 			tmp := cn
 			best = &tmp
 			bestAge = age
@@ -618,40 +794,47 @@ func findPersistentControl(pid int, conns []shared.ConnectionInfo, now time.Time
 	return best, int(bestAge.Seconds())
 }
 
-func getHistory(pid int, now time.Time) *shared.ProcHistory {
-	h := shared.ProcHistoryByPID[pid]
+func (e *Engine) getHistory(pid int, now time.Time) *shared.ProcHistory {
+	h := e.procHistory[pid]
 	if h == nil {
 		h = &shared.ProcHistory{}
-		shared.ProcHistoryByPID[pid] = h
+		e.procHistory[pid] = h
 	}
 	h.LastSeen = now
 	return h
 }
 
-func purgeHistory(now time.Time) {
-	if !shared.LastHistoryCleanup.IsZero() && now.Sub(shared.LastHistoryCleanup) < shared.CleanupInterval {
+func (e *Engine) purgeHistory(now time.Time) {
+	if !e.lastCleanup.IsZero() && now.Sub(e.lastCleanup) < e.thresholds.CleanupInterval {
 		return
 	}
-	shared.LastHistoryCleanup = now
+	e.lastCleanup = now
 
-	for pid, h := range shared.ProcHistoryByPID {
-		if now.Sub(h.LastSeen) <= shared.HistoryTTL {
+	for pid, h := range e.procHistory {
+		if now.Sub(h.LastSeen) <= e.thresholds.HistoryTTL {
 			continue
 		}
 
-		delete(shared.ProcHistoryByPID, pid)
-		delete(shared.RecentClientSeen, pid)
-		delete(shared.RecentOutboundSeen, pid)
+		delete(e.procHistory, pid)
+		for k := range e.udpFirstSeen {
+			if k.Pid == pid {
+				delete(e.udpFirstSeen, k)
+			}
+		}
+		delete(e.recentClientSeen, pid)
+		delete(e.recentOutboundSeen, pid)
+		delete(e.beacons, pid)
+		delete(e.scanAttempts, pid)
 
-		for k := range shared.ConnFirstSeen {
+		for k := range e.connFirstSeen {
 			if k.Pid == pid {
-				delete(shared.ConnFirstSeen, k)
+				delete(e.connFirstSeen, k)
 			}
 		}
 	}
 }
 
-func controlStickyScore(controlSecs int) int {
+func (e *Engine) controlStickyScore(controlSecs int) int {
 	switch {
 	case controlSecs >= 300:
 		return 85
@@ -660,7 +843,7 @@ func controlStickyScore(controlSecs int) int {
 	case controlSecs >= 60:
 		return 60
 	default:
-		return shared.ReverseControlBaseScore
+		return e.thresholds.ReverseControlBaseScore
 	}
 }
 
@@ -675,10 +858,16 @@ func confidenceFor(role string, score int, active bool) int {
 		base = 75
 	case "tunnel-likely":
 		base = 65
+	case "udp-relay":
+		base = 55
+	case "udp-tunnel":
+		base = 55
+	case "beacon":
+		base = 55
+	case "scanner":
+		base = 70
 	case "proxy-listener":
 		base = 60
-	case "reverse-tunnel":
-		base = 55
 	case "listener-with-clients":
 		base = 50
 	case "listener-with-outbound":
@@ -726,8 +915,8 @@ func isActiveConnState(state string) bool {
 	}
 }
 
-func isLikelyBenignControlPort(port int) bool {
-	return shared.BenignControlPorts[port]
+func (e *Engine) isLikelyBenignControlPort(port int) bool {
+	return e.thresholds.BenignControlPorts[port]
 }
 
 func min(a, b int) int {
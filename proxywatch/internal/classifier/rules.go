@@ -0,0 +1,276 @@
+package classifier
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+//go:embed default_rules.json
+var defaultRulesJSON []byte
+
+// RuleSpec is one entry of a rules file. Rules run in file order against the
+// candidate's features. A matching rule either adds Delta, or moves the score
+// with exactly one of Floor (raise to at least), Cap (lower to at most) or Set
+// (replace); those three are expressions. Signal and Reason are recorded when
+// the rule changes the score, or on every match for delta rules. Stop ends
+// evaluation for the candidate. Feature names the feature reported in the
+// score breakdown; it defaults to the first feature in When.
+type RuleSpec struct {
+	Name    string `json:"name"`
+	Feature string `json:"feature,omitempty"`
+	When    string `json:"when,omitempty"`
+	Delta   int    `json:"delta,omitempty"`
+	Floor   string `json:"floor,omitempty"`
+	Cap     string `json:"cap,omitempty"`
+	Set     string `json:"set,omitempty"`
+	Signal  string `json:"signal,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+	Stop    bool   `json:"stop,omitempty"`
+}
+
+type rulesFile struct {
+	Rules []RuleSpec `json:"rules"`
+}
+
+type rule struct {
+	spec    RuleSpec
+	feature string
+	when    expr
+	op      string // "delta", "floor", "cap", "set"
+	bound   expr
+}
+
+// RuleSet is a compiled, immutable list of scoring rules.
+type RuleSet struct {
+	Source string
+	rules  []rule
+}
+
+// DefaultRules returns the built-in rule set, which reproduces the historical
+// hard-coded weights.
+func DefaultRules() *RuleSet {
+	rs, err := ParseRules(defaultRulesJSON, "built-in")
+	if err != nil {
+		panic("classifier: invalid built-in rules: " + err.Error())
+	}
+	return rs
+}
+
+func LoadRules(path string) (*RuleSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseRules(data, path)
+}
+
+func ParseRules(data []byte, source string) (*RuleSet, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	var file rulesFile
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("rules %s: %w", source, err)
+	}
+	if len(file.Rules) == 0 {
+		return nil, fmt.Errorf("rules %s: no rules defined", source)
+	}
+
+	rs := &RuleSet{Source: source}
+	names := make(map[string]bool, len(file.Rules))
+	for i, spec := range file.Rules {
+		r, err := compileRule(spec)
+		if err != nil {
+			label := spec.Name
+			if label == "" {
+				label = fmt.Sprintf("#%d", i+1)
+			}
+			return nil, fmt.Errorf("rules %s: rule %s: %w", source, label, err)
+		}
+		if names[spec.Name] {
+			return nil, fmt.Errorf("rules %s: rule %s: duplicate name", source, spec.Name)
+		}
+		names[spec.Name] = true
+		rs.rules = append(rs.rules, r)
+	}
+	return rs, nil
+}
+
+func compileRule(spec RuleSpec) (rule, error) {
+	r := rule{spec: spec, op: "delta"}
+	if spec.Name == "" {
+		return r, errors.New("missing name")
+	}
+
+	if spec.When != "" {
+		w, err := compileExpr(spec.When, isKnownFeature)
+		if err != nil {
+			return r, fmt.Errorf("when: %w", err)
+		}
+		r.when = w
+		r.feature = firstIdent(w)
+	}
+	if spec.Feature != "" {
+		if !isKnownFeature(spec.Feature) {
+			return r, fmt.Errorf("feature: unknown feature %q", spec.Feature)
+		}
+		r.feature = spec.Feature
+	}
+
+	bounds := 0
+	for op, src := range map[string]string{"floor": spec.Floor, "cap": spec.Cap, "set": spec.Set} {
+		if src == "" {
+			continue
+		}
+		bounds++
+		b, err := compileExpr(src, isKnownFeature)
+		if err != nil {
+			return r, fmt.Errorf("%s: %w", op, err)
+		}
+		r.op = op
+		r.bound = b
+	}
+	if bounds > 1 {
+		return r, errors.New("only one of floor, cap and set may be given")
+	}
+	if bounds == 1 && spec.Delta != 0 {
+		return r, fmt.Errorf("delta cannot be combined with %s", r.op)
+	}
+	return r, nil
+}
+
+// apply runs the rules and returns the resulting score. onFire is called for
+// every rule that takes effect, with the score before it and the change made.
+func (rs *RuleSet) apply(f featureSet, onFire func(r *rule, before, delta int)) int {
+	score := 0
+	for i := range rs.rules {
+		r := &rs.rules[i]
+		if r.when != nil && !r.when.eval(f).truthy() {
+			continue
+		}
+
+		next := score
+		fired := true
+		switch r.op {
+		case "delta":
+			next = score + r.spec.Delta
+		case "set":
+			next = int(r.bound.eval(f).float())
+		case "floor":
+			if b := int(r.bound.eval(f).float()); score < b {
+				next = b
+			} else {
+				fired = false
+			}
+		case "cap":
+			if b := int(r.bound.eval(f).float()); score > b {
+				next = b
+			} else {
+				fired = false
+			}
+		}
+
+		if fired {
+			onFire(r, score, next-score)
+			score = next
+		}
+		if r.spec.Stop {
+			break
+		}
+	}
+	return score
+}
+
+// Specs returns the rules in evaluation order.
+func (rs *RuleSet) Specs() []RuleSpec {
+	out := make([]RuleSpec, len(rs.rules))
+	for i, r := range rs.rules {
+		out[i] = r.spec
+	}
+	return out
+}
+
+// featureNames lists every feature rules may reference.
+var featureNames = []string{
+	"hasListener",
+	"listenerLoopbackOnly",
+	"listenerWildcard",
+	"activeClients",
+	"outTotal",
+	"outExternal",
+	"outInternal",
+	"outLoopback",
+	"outLongLived",
+	"outShortLived",
+	"outboundActive",
+	"distinctTargets",
+	"distinctTargetPorts",
+	"internalTargets",
+	"internalPorts",
+	"internalLateral",
+	"reverseTunnelEligible",
+	"controlChannel",
+	"controlSecs",
+	"controlCarriesTraffic",
+	"transferBps",
+	"localTransport",
+	"localCount",
+	"tunnelLikely",
+	"forwardActive",
+	"reverseProxyNow",
+	"reverseControl",
+	"reverseTransport",
+	"activeProxying",
+	"udpInbound",
+	"udpOutbound",
+	"udpLongLivedPeer",
+	"udpPeerSecs",
+	"udpDnsOffPort",
+	"udpRelay",
+	"beacon",
+	"beaconCount",
+	"beaconPeriodSecs",
+	"beaconJitterRatio",
+	"scan",
+	"scanAttempts",
+	"scanSweepHosts",
+	"scanPorts",
+	"chainLength",
+	"chainEgress",
+	"fingerprint",
+	"fingerprintCount",
+	"baselineKnown",
+	"deviation",
+	"deviationCount",
+	"newListener",
+	"newLateralPort",
+	"newRemotePort",
+	"moreTargets",
+	"internalShift",
+	"stickyScore",
+	"baseRole",
+	"role",
+	"outboundOnlyExternalCap",
+}
+
+var knownFeatures = func() map[string]bool {
+	m := make(map[string]bool, len(featureNames))
+	for _, n := range featureNames {
+		m[n] = true
+	}
+	return m
+}()
+
+func isKnownFeature(name string) bool { return knownFeatures[name] }
+
+// FeatureNames returns the sorted list of features available to rules.
+func FeatureNames() []string {
+	out := append([]string(nil), featureNames...)
+	sort.Strings(out)
+	return out
+}
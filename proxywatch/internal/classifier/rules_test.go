@@ -0,0 +1,137 @@
+package classifier
+
+import (
+	"strings"
+	"testing"
+)
+
+const testRulesJSON = `{"rules": [
+	{"name": "base", "when": "outTotal > 0", "delta": 40},
+	{"name": "fanout", "when": "distinctTargets >= 2", "delta": 30},
+	{"name": "penalty", "when": "hasListener", "delta": -90},
+	{"name": "non-negative", "floor": "0"},
+	{"name": "lateral-set", "feature": "controlSecs", "when": "internalLateral", "set": "50 + controlSecs"},
+	{"name": "sticky", "when": "stickyScore > 0", "floor": "stickyScore"},
+	{"name": "cap", "when": "role == \"outbound-only\"", "cap": "45", "reason": "capped"},
+	{"name": "done", "when": "scan", "stop": true},
+	{"name": "after-stop", "delta": 7}
+]}`
+
+func TestRuleSetApplyContributions(t *testing.T) {
+	rs, err := ParseRules([]byte(testRulesJSON), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		f     featureSet
+		score int
+		fired []string
+	}{
+		{
+			name:  "deltas only",
+			f:     featureSet{"outTotal": numVal(1), "distinctTargets": numVal(2)},
+			score: 77,
+			fired: []string{"base", "fanout", "after-stop"},
+		},
+		{
+			name:  "floor lifts a negative sum",
+			f:     featureSet{"outTotal": numVal(1), "hasListener": boolVal(true)},
+			score: 7,
+			fired: []string{"base", "penalty", "non-negative", "after-stop"},
+		},
+		{
+			name:  "set replaces the score",
+			f:     featureSet{"outTotal": numVal(1), "internalLateral": boolVal(true), "controlSecs": numVal(12)},
+			score: 69,
+			fired: []string{"base", "lateral-set", "after-stop"},
+		},
+		{
+			name:  "floor below the score does not fire",
+			f:     featureSet{"outTotal": numVal(1), "distinctTargets": numVal(3), "stickyScore": numVal(60)},
+			score: 77,
+			fired: []string{"base", "fanout", "after-stop"},
+		},
+		{
+			name:  "sticky floor then cap",
+			f:     featureSet{"stickyScore": numVal(80), "role": strVal("outbound-only")},
+			score: 52,
+			fired: []string{"sticky", "cap", "after-stop"},
+		},
+		{
+			name:  "stop skips the rest",
+			f:     featureSet{"outTotal": numVal(1), "scan": boolVal(true)},
+			score: 40,
+			fired: []string{"base"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sum := 0
+			var fired []string
+			score := rs.apply(tt.f, func(r *rule, before, delta int) {
+				if before != sum {
+					t.Errorf("rule %s saw score %d, contributions so far sum to %d", r.spec.Name, before, sum)
+				}
+				sum += delta
+				if delta != 0 {
+					fired = append(fired, r.spec.Name)
+				}
+			})
+			if score != tt.score {
+				t.Errorf("score = %d, want %d", score, tt.score)
+			}
+			if sum != score {
+				t.Errorf("contributions sum to %d, score is %d", sum, score)
+			}
+			if strings.Join(fired, ",") != strings.Join(tt.fired, ",") {
+				t.Errorf("fired %v, want %v", fired, tt.fired)
+			}
+		})
+	}
+}
+
+func TestParseRulesErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"empty", `{"rules": []}`, "no rules defined"},
+		{"unknown field", `{"rules": [{"name": "a", "weight": 3}]}`, `unknown field "weight"`},
+		{"missing name", `{"rules": [{"delta": 3}]}`, "rule #1: missing name"},
+		{"duplicate", `{"rules": [{"name": "a"}, {"name": "a"}]}`, "rule a: duplicate name"},
+		{"two bounds", `{"rules": [{"name": "a", "floor": "1", "cap": "2"}]}`, "only one of floor, cap and set"},
+		{"delta and set", `{"rules": [{"name": "a", "delta": 1, "set": "2"}]}`, "delta cannot be combined with set"},
+		{"bad when", `{"rules": [{"name": "a", "when": "outTotal >"}]}`, "rule a: when: unexpected end"},
+		{"bad bound", `{"rules": [{"name": "a", "cap": "min(1)"}]}`, "rule a: cap: min takes 2"},
+		{"unknown feature", `{"rules": [{"name": "a", "feature": "nope", "delta": 1}]}`, `feature: unknown feature "nope"`},
+		{"unknown ident", `{"rules": [{"name": "a", "when": "nope", "delta": 1}]}`, `when: unknown feature "nope"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseRules([]byte(tt.src), "test.json")
+			if err == nil {
+				t.Fatal("ParseRules succeeded")
+			}
+			if !strings.Contains(err.Error(), tt.want) || !strings.HasPrefix(err.Error(), "rules test.json: ") {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultRulesFeaturesKnown(t *testing.T) {
+	rs := DefaultRules()
+	for _, spec := range rs.Specs() {
+		for _, src := range []string{spec.When, spec.Floor, spec.Cap, spec.Set} {
+			if src == "" {
+				continue
+			}
+			if _, err := compileExpr(src, isKnownFeature); err != nil {
+				t.Errorf("rule %s: %v", spec.Name, err)
+			}
+		}
+	}
+}
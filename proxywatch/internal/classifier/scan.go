@@ -0,0 +1,99 @@
+package classifier
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"proxywatch/internal/shared"
+)
+
+type scanTarget struct {
+	Host string
+	Port int
+}
+
+// updateScan records the half-open (SYN_SENT) attempts of pid and returns a
+// summary when, within the scan window, one port was tried on many internal
+// hosts (sweep) or one host on many ports (port scan).
+func (e *Engine) updateScan(pid int, conns []shared.ConnectionInfo, now time.Time) *shared.ScanInfo {
+	attempts := e.scanAttempts[pid]
+	for _, c := range conns {
+		if c.State != "SYN_SENT" {
+			continue
+		}
+		if c.RemoteAddress == "" ||
+			shared.IsWildcardIP(c.RemoteAddress) ||
+			shared.IsLoopbackIP(c.RemoteAddress) {
+			continue
+		}
+		if attempts == nil {
+			attempts = make(map[scanTarget]time.Time)
+			e.scanAttempts[pid] = attempts
+		}
+		attempts[scanTarget{Host: c.RemoteAddress, Port: c.RemotePort}] = now
+	}
+	if attempts == nil {
+		return nil
+	}
+
+	hostsByPort := make(map[int]map[string]struct{})
+	portsByHost := make(map[string]map[int]struct{})
+	for t, seen := range attempts {
+		if now.Sub(seen) > e.thresholds.ScanWindow {
+			delete(attempts, t)
+			continue
+		}
+		if e.zones.IsInternal(t.Host) {
+			if hostsByPort[t.Port] == nil {
+				hostsByPort[t.Port] = make(map[string]struct{})
+			}
+			hostsByPort[t.Port][t.Host] = struct{}{}
+		}
+		if portsByHost[t.Host] == nil {
+			portsByHost[t.Host] = make(map[int]struct{})
+		}
+		portsByHost[t.Host][t.Port] = struct{}{}
+	}
+	if len(attempts) == 0 {
+		delete(e.scanAttempts, pid)
+		return nil
+	}
+
+	info := &shared.ScanInfo{
+		Attempts: len(attempts),
+		Hosts:    len(portsByHost),
+		Ports:    len(hostsByPort),
+	}
+	for port, hosts := range hostsByPort {
+		if len(hosts) > info.SweepHosts || (len(hosts) == info.SweepHosts && port < info.SweepPort) {
+			info.SweepPort = port
+			info.SweepHosts = len(hosts)
+		}
+	}
+	for host, ports := range portsByHost {
+		if len(ports) > info.ScanPorts || (len(ports) == info.ScanPorts && host < info.ScanHost) {
+			info.ScanHost = host
+			info.ScanPorts = len(ports)
+		}
+	}
+
+	var parts []string
+	if info.SweepHosts >= e.thresholds.ScanSweepMinHosts {
+		parts = append(parts, fmt.Sprintf("sweep of %d internal hosts on port %d", info.SweepHosts, info.SweepPort))
+	} else {
+		info.SweepPort, info.SweepHosts = 0, 0
+	}
+	if info.ScanPorts >= e.thresholds.ScanMinPorts {
+		parts = append(parts, fmt.Sprintf("%d ports on %s", info.ScanPorts, info.ScanHost))
+	} else {
+		info.ScanHost, info.ScanPorts = "", 0
+	}
+	if len(parts) == 0 {
+		return nil
+	}
+
+	info.Summary = fmt.Sprintf("%s (%d half-open attempts in %s)",
+		strings.Join(parts, "; "), info.Attempts, e.thresholds.ScanWindow)
+	return info
+}
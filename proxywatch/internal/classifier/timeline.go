@@ -0,0 +1,31 @@
+package classifier
+
+import (
+	"time"
+
+	"proxywatch/internal/shared"
+)
+
+// recordTransition appends c's state to its process timeline when the role,
+// score or active state differs from the last entry, then gives c its own
+// copy of the timeline.
+func (e *Engine) recordTransition(c *shared.Candidate, now time.Time) {
+	hist := e.getHistory(c.Proc.Pid, now)
+	t := shared.Transition{At: now, Role: c.Role, Score: c.Score, Active: c.ActiveProxying}
+
+	n := len(hist.Timeline)
+	if n == 0 || !sameState(hist.Timeline[n-1], t) {
+		if n >= shared.TimelineMax {
+			// drop the oldest entries in place
+			kept := copy(hist.Timeline, hist.Timeline[n-shared.TimelineMax+1:])
+			hist.Timeline = hist.Timeline[:kept]
+		}
+		hist.Timeline = append(hist.Timeline, t)
+	}
+
+	c.Timeline = append([]shared.Transition(nil), hist.Timeline...)
+}
+
+func sameState(a, b shared.Transition) bool {
+	return a.Role == b.Role && a.Score == b.Score && a.Active == b.Active
+}
@@ -0,0 +1,137 @@
+package classifier
+
+import (
+	"time"
+
+	"proxywatch/internal/shared"
+)
+
+type udpSummary struct {
+	inbound   int
+	outbound  int
+	dnsOut    int
+	external  map[string]struct{}
+	peer      *shared.UDPConnInfo
+	peerSecs  int
+	dnsFwd    bool
+	listeners map[int]struct{}
+	// offPortIn counts inbound datagrams on exposed listeners off port 53
+	offPortIn int
+}
+
+func udpKey(pid int, u shared.UDPConnInfo) shared.ConnKey {
+	return shared.ConnKey{
+		Pid:        pid,
+		LocalAddr:  u.LocalAddress,
+		LocalPort:  u.LocalPort,
+		RemoteAddr: u.RemoteAddress,
+		RemotePort: u.RemotePort,
+	}
+}
+
+func (e *Engine) updateUDPHistory(pid int, conns []shared.UDPConnInfo, now time.Time) {
+	current := make(map[shared.ConnKey]struct{}, len(conns))
+	for _, u := range conns {
+		key := udpKey(pid, u)
+		current[key] = struct{}{}
+		if _, ok := e.udpFirstSeen[key]; !ok {
+			e.udpFirstSeen[key] = now
+		}
+	}
+
+	for k := range e.udpFirstSeen {
+		if k.Pid == pid {
+			if _, ok := current[k]; !ok {
+				delete(e.udpFirstSeen, k)
+			}
+		}
+	}
+}
+
+// udpFlowSummary splits connected UDP sockets into inbound (sharing a port
+// with one of the process' UDP listeners) and outbound, and finds the oldest
+// high-port external peer.
+func (e *Engine) udpFlowSummary(c *shared.Candidate, now time.Time) udpSummary {
+	sum := udpSummary{
+		external:  make(map[string]struct{}),
+		listeners: make(map[int]struct{}),
+	}
+
+	exposed := make(map[int]bool)
+	for _, ul := range c.UDPListeners {
+		sum.listeners[ul.LocalPort] = struct{}{}
+		if !shared.IsLoopbackIP(ul.LocalAddress) && ul.LocalPort != 53 {
+			exposed[ul.LocalPort] = true
+		}
+	}
+
+	var bestAge time.Duration
+	for _, u := range c.UDPConns {
+		if u.RemoteAddress == "" || shared.IsWildcardIP(u.RemoteAddress) {
+			continue
+		}
+		if _, ok := sum.listeners[u.LocalPort]; ok {
+			sum.inbound++
+			if exposed[u.LocalPort] {
+				sum.offPortIn++
+			}
+			continue
+		}
+		if shared.IsLoopbackIP(u.RemoteAddress) {
+			continue
+		}
+
+		sum.outbound++
+		if u.RemotePort == 53 {
+			sum.dnsFwd = true
+			sum.dnsOut++
+		}
+		if e.zones.IsInternal(u.RemoteAddress) {
+			continue
+		}
+		sum.external[u.RemoteAddress] = struct{}{}
+
+		if u.RemotePort < e.thresholds.UDPHighPortMin {
+			continue
+		}
+		first, ok := e.udpFirstSeen[udpKey(c.Proc.Pid, u)]
+		if !ok {
+			continue
+		}
+		if age := now.Sub(first); age > bestAge {
+			tmp := u
+			sum.peer = &tmp
+			bestAge = age
+		}
+	}
+
+	sum.peerSecs = int(bestAge.Seconds())
+	return sum
+}
+
+// longLivedPeer reports a single external high-port UDP peer held for at
+// least UDPLongLivedPeerMinAge: the shape of WireGuard, KCP and QUIC tunnels.
+func (e *Engine) longLivedPeer(sum udpSummary) bool {
+	return sum.peer != nil &&
+		len(sum.external) == 1 &&
+		time.Duration(sum.peerSecs)*time.Second >= e.thresholds.UDPLongLivedPeerMinAge
+}
+
+// dnsOffPort reports an exposed UDP listener on a port other than 53 that
+// receives datagrams in a process that sends UDP to DNS servers and does not
+// itself serve port 53. A resolver that merely listens (mDNS on 5353) and
+// looks names up does not qualify.
+func dnsOffPort(sum udpSummary) bool {
+	if !sum.dnsFwd || sum.offPortIn == 0 {
+		return false
+	}
+	_, serves53 := sum.listeners[53]
+	return !serves53
+}
+
+// udpRelay reports a UDP listener that receives traffic (connected UDP
+// clients or TCP clients, e.g. SOCKS5 UDP ASSOCIATE) and sends UDP on. Name
+// lookups are not relayed traffic.
+func udpRelay(sum udpSummary, tcpClients int) bool {
+	return len(sum.listeners) > 0 && sum.outbound > sum.dnsOut && (sum.inbound > 0 || tcpClients > 0)
+}
@@ -0,0 +1,44 @@
+package classifier_test
+
+import (
+	"testing"
+
+	"proxywatch/internal/classifier"
+	"proxywatch/internal/scenario"
+	"proxywatch/internal/shared"
+)
+
+// an engine configured with other zones does not change how its neighbours
+// read the same traffic
+func TestEnginesKeepTheirOwnZones(t *testing.T) {
+	list, err := scenario.Parse([]byte(goldenScenarios), "golden.scn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := scenario.Find(list, "golden-internal-control")
+
+	narrow, err := shared.NewNetworkZones([]string{"192.168.0.0/16"}, []int{3389})
+	if err != nil {
+		t.Fatal(err)
+	}
+	def := classifier.NewEngine(shared.DefaultThresholds())
+	other := classifier.NewEngine(shared.DefaultThresholds())
+	other.SetNetworkZones(narrow)
+
+	before := scenario.Run(s, def).Final[320]
+	moved := scenario.Run(s, other).Final[320]
+	after := scenario.Run(s, classifier.NewEngine(shared.DefaultThresholds())).Final[320]
+
+	if before.OutInternal != 1 || after.OutInternal != 1 {
+		t.Errorf("default zones count %d then %d internal targets, want 1", before.OutInternal, after.OutInternal)
+	}
+	if before.Role != after.Role || before.Score != after.Score {
+		t.Errorf("default engine went from %s/%d to %s/%d", before.Role, before.Score, after.Role, after.Score)
+	}
+	if moved.OutInternal != 0 || moved.OutExternal != 2 {
+		t.Errorf("narrow zones count %d internal, %d external targets, want 0 and 2", moved.OutInternal, moved.OutExternal)
+	}
+	if moved.Score == before.Score {
+		t.Errorf("narrow zones scored %d like the defaults", moved.Score)
+	}
+}
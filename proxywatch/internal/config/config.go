@@ -0,0 +1,422 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"proxywatch/internal/shared"
+)
+
+// Config is the on-disk configuration. A file starts from a preset
+// (workstation unless it names another) and overrides only the keys it sets.
+type Config struct {
+	Preset   string `json:"preset,omitempty"`
+	MinScore int    `json:"min_score"`
+	Rules    string `json:"rules,omitempty"`
+
+	Suppressions string `json:"suppressions,omitempty"`
+	Fingerprints string `json:"fingerprints,omitempty"` // "builtin" or an extension file
+	IOC          string `json:"ioc,omitempty"`          // directory of threat intel lists
+	Baseline     string `json:"baseline,omitempty"`     // profiles written by -learn
+
+	Zones     Zones     `json:"zones"`
+	Detection Detection `json:"detection"`
+	Burst     Burst     `json:"burst"`
+}
+
+type Zones struct {
+	InternalCIDRs []string `json:"internal_cidrs"`
+	LateralPorts  []int    `json:"lateral_ports"`
+}
+
+type Detection struct {
+	ReverseControlMinDuration Duration `json:"reverse_control_min_duration"`
+	LongLivedOutboundMinAge   Duration `json:"long_lived_outbound_min_age"`
+	ShortLivedOutboundMaxAge  Duration `json:"short_lived_outbound_max_age"`
+	ActiveWindow              Duration `json:"active_window"`
+	ActiveHoldWindow          Duration `json:"active_hold_window"`
+	SuspicionWindow           Duration `json:"suspicion_window"`
+	HistoryTTL                Duration `json:"history_ttl"`
+	CleanupInterval           Duration `json:"cleanup_interval"`
+	ReverseStickyScore        int      `json:"reverse_sticky_score"`
+	ForwardStickyScore        int      `json:"forward_sticky_score"`
+	ReverseControlBaseScore   int      `json:"reverse_control_base_score"`
+	MinInternalTargetsForRev  int      `json:"min_internal_targets_for_reverse"`
+	MinInternalPortsForRev    int      `json:"min_internal_ports_for_reverse"`
+	OutboundOnlyExternalCap   int      `json:"outbound_only_external_cap"`
+	UDPLongLivedPeerMinAge    Duration `json:"udp_long_lived_peer_min_age"`
+	UDPHighPortMin            int      `json:"udp_high_port_min"`
+	ActiveTransferBps         uint64   `json:"active_transfer_bps"`
+	FlowPairWindow            Duration `json:"flow_pair_window"`
+	BeaconMinIntervals        int      `json:"beacon_min_intervals"`
+	BeaconMinPeriod           Duration `json:"beacon_min_period"`
+	BeaconMaxPeriod           Duration `json:"beacon_max_period"`
+	BeaconMaxJitter           float64  `json:"beacon_max_jitter"`
+	ScanWindow                Duration `json:"scan_window"`
+	ScanSweepMinHosts         int      `json:"scan_sweep_min_hosts"`
+	ScanMinPorts              int      `json:"scan_min_ports"`
+	BenignControlPorts        []int    `json:"benign_control_ports"`
+}
+
+type Burst struct {
+	SamplesMax            int      `json:"samples_max"`
+	SamplesMid            int      `json:"samples_mid"`
+	SamplesMin            int      `json:"samples_min"`
+	Sleep                 Duration `json:"sleep"`
+	IdleConnThreshold     int      `json:"idle_conn_threshold"`
+	ModerateConnThreshold int      `json:"moderate_conn_threshold"`
+}
+
+// Duration is a time.Duration written as a Go duration string ("90s", "5m").
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return &durationError{raw: string(b)}
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return &durationError{raw: string(b)}
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// durationError carries the offending literal; encoding/json does not report
+// the field for errors raised by UnmarshalJSON, so Parse looks the key up.
+type durationError struct {
+	raw string
+}
+
+func (e *durationError) Error() string {
+	return "invalid duration " + e.raw + " (want e.g. \"30s\" or \"5m\")"
+}
+
+// BuiltinFingerprints selects the embedded fingerprint pack without an
+// extension file.
+const BuiltinFingerprints = "builtin"
+
+// Load reads path on top of its preset. fallbackPreset is used when the file
+// does not name one.
+func Load(path, fallbackPreset string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := Parse(data, fallbackPreset)
+	if err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	// referenced files are relative to the config file
+	for _, ref := range []*string{&cfg.Rules, &cfg.Suppressions, &cfg.Fingerprints, &cfg.IOC, &cfg.Baseline} {
+		if *ref != "" && *ref != BuiltinFingerprints && !filepath.IsAbs(*ref) {
+			*ref = filepath.Join(filepath.Dir(path), *ref)
+		}
+	}
+	return cfg, nil
+}
+
+func Parse(data []byte, fallbackPreset string) (*Config, error) {
+	var head struct {
+		Preset string `json:"preset"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return nil, describeJSONError(err)
+	}
+
+	name := head.Preset
+	if name == "" {
+		name = fallbackPreset
+	}
+	cfg, err := Preset(name)
+	if err != nil {
+		return nil, fmt.Errorf("key \"preset\": %w", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		var durErr *durationError
+		if errors.As(err, &durErr) {
+			if key := findKey(data, "", durErr.raw); key != "" {
+				return nil, keyError(key, durErr.Error())
+			}
+		}
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			name, _ := strconv.Unquote(field)
+			if key := unknownKey(data, reflect.TypeOf(*cfg), "", name); key != "" {
+				return nil, fmt.Errorf("unknown key %q", key)
+			}
+		}
+		return nil, describeJSONError(err)
+	}
+	cfg.Preset = name
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// findKey returns the dotted path of the first nested key whose raw value is
+// raw.
+func findKey(data []byte, prefix, raw string) string {
+	var obj map[string]json.RawMessage
+	if json.Unmarshal(data, &obj) != nil {
+		return ""
+	}
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		path := k
+		if prefix != "" {
+			path = prefix + "." + k
+		}
+		if prefix != "" && string(bytes.TrimSpace(obj[k])) == raw {
+			return path
+		}
+		if found := findKey(obj[k], path, raw); found != "" {
+			return found
+		}
+	}
+	return ""
+}
+
+// unknownKey returns the dotted path of the first key called name that t has
+// no field for; encoding/json reports only the last path element.
+func unknownKey(data []byte, t reflect.Type, prefix, name string) string {
+	var obj map[string]json.RawMessage
+	if json.Unmarshal(data, &obj) != nil {
+		return ""
+	}
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		fields[name] = t.Field(i).Type
+	}
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		path := k
+		if prefix != "" {
+			path = prefix + "." + k
+		}
+		ft, ok := fields[k]
+		if !ok {
+			if k == name {
+				return path
+			}
+			continue
+		}
+		if ft.Kind() == reflect.Struct {
+			if found := unknownKey(obj[k], ft, path, name); found != "" {
+				return found
+			}
+		}
+	}
+	return ""
+}
+
+func describeJSONError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return fmt.Errorf("key %q: cannot use a JSON %s as %s", typeErr.Field, typeErr.Value, typeErr.Type)
+	}
+	if msg := err.Error(); strings.HasPrefix(msg, "json: unknown field ") {
+		return fmt.Errorf("unknown key %s", strings.TrimPrefix(msg, "json: unknown field "))
+	}
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return fmt.Errorf("invalid JSON at offset %d: %w", syntaxErr.Offset, err)
+	}
+	return err
+}
+
+func (c *Config) Validate() error {
+	if c.MinScore < 0 {
+		return keyError("min_score", "must not be negative")
+	}
+
+	for i, cidr := range c.Zones.InternalCIDRs {
+		if _, err := shared.NewNetworkZones([]string{cidr}, nil); err != nil {
+			return keyError(fmt.Sprintf("zones.internal_cidrs[%d]", i), err.Error())
+		}
+	}
+	if err := checkPorts("zones.lateral_ports", c.Zones.LateralPorts); err != nil {
+		return err
+	}
+
+	d := c.Detection
+	durations := []struct {
+		key string
+		v   Duration
+	}{
+		{"detection.reverse_control_min_duration", d.ReverseControlMinDuration},
+		{"detection.long_lived_outbound_min_age", d.LongLivedOutboundMinAge},
+		{"detection.short_lived_outbound_max_age", d.ShortLivedOutboundMaxAge},
+		{"detection.active_window", d.ActiveWindow},
+		{"detection.active_hold_window", d.ActiveHoldWindow},
+		{"detection.suspicion_window", d.SuspicionWindow},
+		{"detection.history_ttl", d.HistoryTTL},
+		{"detection.cleanup_interval", d.CleanupInterval},
+		{"detection.udp_long_lived_peer_min_age", d.UDPLongLivedPeerMinAge},
+		{"detection.flow_pair_window", d.FlowPairWindow},
+		{"detection.beacon_min_period", d.BeaconMinPeriod},
+		{"detection.beacon_max_period", d.BeaconMaxPeriod},
+		{"detection.scan_window", d.ScanWindow},
+	}
+	for _, dur := range durations {
+		if dur.v <= 0 {
+			return keyError(dur.key, "must be a positive duration")
+		}
+	}
+
+	scores := []struct {
+		key string
+		v   int
+	}{
+		{"detection.reverse_sticky_score", d.ReverseStickyScore},
+		{"detection.forward_sticky_score", d.ForwardStickyScore},
+		{"detection.reverse_control_base_score", d.ReverseControlBaseScore},
+		{"detection.outbound_only_external_cap", d.OutboundOnlyExternalCap},
+	}
+	for _, sc := range scores {
+		if sc.v < 0 {
+			return keyError(sc.key, "must not be negative")
+		}
+	}
+	if d.MinInternalTargetsForRev < 1 {
+		return keyError("detection.min_internal_targets_for_reverse", "must be at least 1")
+	}
+	if d.MinInternalPortsForRev < 1 {
+		return keyError("detection.min_internal_ports_for_reverse", "must be at least 1")
+	}
+	if d.BeaconMinIntervals < 2 {
+		return keyError("detection.beacon_min_intervals", "must be at least 2")
+	}
+	if d.BeaconMaxPeriod < d.BeaconMinPeriod {
+		return keyError("detection.beacon_max_period", "must not be less than detection.beacon_min_period")
+	}
+	if d.BeaconMaxJitter <= 0 || d.BeaconMaxJitter > 1 {
+		return keyError("detection.beacon_max_jitter", "must be greater than 0 and at most 1")
+	}
+	if d.ScanSweepMinHosts < 2 {
+		return keyError("detection.scan_sweep_min_hosts", "must be at least 2")
+	}
+	if d.ScanMinPorts < 2 {
+		return keyError("detection.scan_min_ports", "must be at least 2")
+	}
+	if d.UDPHighPortMin < 1 || d.UDPHighPortMin > 65535 {
+		return keyError("detection.udp_high_port_min", "must be a port number")
+	}
+	if err := checkPorts("detection.benign_control_ports", d.BenignControlPorts); err != nil {
+		return err
+	}
+
+	b := c.Burst
+	if b.SamplesMin < 1 {
+		return keyError("burst.samples_min", "must be at least 1")
+	}
+	if b.SamplesMid < b.SamplesMin {
+		return keyError("burst.samples_mid", "must not be less than burst.samples_min")
+	}
+	if b.SamplesMax < b.SamplesMid {
+		return keyError("burst.samples_max", "must not be less than burst.samples_mid")
+	}
+	if b.Sleep < 0 {
+		return keyError("burst.sleep", "must not be negative")
+	}
+	if b.IdleConnThreshold < 0 {
+		return keyError("burst.idle_conn_threshold", "must not be negative")
+	}
+	if b.ModerateConnThreshold < b.IdleConnThreshold {
+		return keyError("burst.moderate_conn_threshold", "must not be less than burst.idle_conn_threshold")
+	}
+	return nil
+}
+
+func checkPorts(key string, ports []int) error {
+	for i, p := range ports {
+		if p <= 0 || p > 65535 {
+			return keyError(fmt.Sprintf("%s[%d]", key, i), fmt.Sprintf("invalid port %d", p))
+		}
+	}
+	return nil
+}
+
+func keyError(key, msg string) error {
+	return fmt.Errorf("key %q: %s", key, msg)
+}
+
+func (c *Config) Thresholds() shared.Thresholds {
+	d := c.Detection
+	benign := make(map[int]bool, len(d.BenignControlPorts))
+	for _, p := range d.BenignControlPorts {
+		benign[p] = true
+	}
+	return shared.Thresholds{
+		ReverseControlMinDuration: time.Duration(d.ReverseControlMinDuration),
+		LongLivedOutboundMinAge:   time.Duration(d.LongLivedOutboundMinAge),
+		ShortLivedOutboundMaxAge:  time.Duration(d.ShortLivedOutboundMaxAge),
+		ActiveWindow:              time.Duration(d.ActiveWindow),
+		ActiveHoldWindow:          time.Duration(d.ActiveHoldWindow),
+		SuspicionWindow:           time.Duration(d.SuspicionWindow),
+		HistoryTTL:                time.Duration(d.HistoryTTL),
+		CleanupInterval:           time.Duration(d.CleanupInterval),
+		ReverseStickyScore:        d.ReverseStickyScore,
+		ForwardStickyScore:        d.ForwardStickyScore,
+		ReverseControlBaseScore:   d.ReverseControlBaseScore,
+		MinInternalTargetsForRev:  d.MinInternalTargetsForRev,
+		MinInternalPortsForRev:    d.MinInternalPortsForRev,
+		OutboundOnlyExternalCap:   d.OutboundOnlyExternalCap,
+		UDPLongLivedPeerMinAge:    time.Duration(d.UDPLongLivedPeerMinAge),
+		UDPHighPortMin:            d.UDPHighPortMin,
+		ActiveTransferBps:         d.ActiveTransferBps,
+		FlowPairWindow:            time.Duration(d.FlowPairWindow),
+		BeaconMinIntervals:        d.BeaconMinIntervals,
+		BeaconMinPeriod:           time.Duration(d.BeaconMinPeriod),
+		BeaconMaxPeriod:           time.Duration(d.BeaconMaxPeriod),
+		BeaconMaxJitter:           d.BeaconMaxJitter,
+		ScanWindow:                time.Duration(d.ScanWindow),
+		ScanSweepMinHosts:         d.ScanSweepMinHosts,
+		ScanMinPorts:              d.ScanMinPorts,
+		BenignControlPorts:        benign,
+	}
+}
+
+func (c *Config) NetworkZones() (*shared.NetworkZones, error) {
+	return shared.NewNetworkZones(c.Zones.InternalCIDRs, c.Zones.LateralPorts)
+}
+
+func (c *Config) BurstSettings() shared.BurstSettings {
+	return shared.BurstSettings{
+		SamplesMax:            c.Burst.SamplesMax,
+		SamplesMid:            c.Burst.SamplesMid,
+		SamplesMin:            c.Burst.SamplesMin,
+		Sleep:                 time.Duration(c.Burst.Sleep),
+		IdleConnThreshold:     c.Burst.IdleConnThreshold,
+		ModerateConnThreshold: c.Burst.ModerateConnThreshold,
+	}
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"proxywatch/internal/shared"
+)
+
+const DefaultPreset = "workstation"
+
+const DefaultMinScore = 15
+
+var presets = map[string]func(*Config){
+	// built-in defaults
+	"workstation": func(*Config) {},
+
+	// many legitimate listeners with clients and long-lived backend
+	// connections; report less, and sample less under heavy connection load
+	"server": func(c *Config) {
+		c.MinScore = 25
+		c.Detection.LongLivedOutboundMinAge = Duration(120 * time.Second)
+		c.Detection.ForwardStickyScore = 60
+		c.Detection.OutboundOnlyExternalCap = 20
+		c.Detection.MinInternalTargetsForRev = 3
+		c.Burst.SamplesMax = 3
+		c.Burst.ModerateConnThreshold = 100
+	},
+
+	// interactive SSH/RDP into the internal network is expected; flag
+	// fan-out and persistent channels rather than single admin sessions
+	"jump-host": func(c *Config) {
+		c.MinScore = 20
+		c.Zones.LateralPorts = withoutPorts(c.Zones.LateralPorts, 22, 3389)
+		c.Detection.ReverseControlMinDuration = Duration(30 * time.Second)
+		c.Detection.MinInternalTargetsForRev = 4
+		c.Detection.MinInternalPortsForRev = 3
+		c.Detection.BenignControlPorts = append(c.Detection.BenignControlPorts, 22)
+	},
+}
+
+func PresetNames() []string {
+	names := make([]string, 0, len(presets))
+	for n := range presets {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Preset returns a fresh copy of the named preset.
+func Preset(name string) (*Config, error) {
+	apply, ok := presets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown preset %q (available: %s)", name, strings.Join(PresetNames(), ", "))
+	}
+	c := defaults()
+	c.Preset = name
+	apply(c)
+	return c, nil
+}
+
+func defaults() *Config {
+	t := shared.DefaultThresholds()
+	b := shared.DefaultBurstSettings()
+
+	lateral := make([]int, 0, len(shared.LateralPorts))
+	for p := range shared.LateralPorts {
+		lateral = append(lateral, p)
+	}
+	sort.Ints(lateral)
+
+	benign := make([]int, 0, len(t.BenignControlPorts))
+	for p := range t.BenignControlPorts {
+		benign = append(benign, p)
+	}
+	sort.Ints(benign)
+
+	return &Config{
+		MinScore: DefaultMinScore,
+		Zones: Zones{
+			InternalCIDRs: append([]string(nil), shared.InternalCIDRs...),
+			LateralPorts:  lateral,
+		},
+		Detection: Detection{
+			ReverseControlMinDuration: Duration(t.ReverseControlMinDuration),
+			LongLivedOutboundMinAge:   Duration(t.LongLivedOutboundMinAge),
+			ShortLivedOutboundMaxAge:  Duration(t.ShortLivedOutboundMaxAge),
+			ActiveWindow:              Duration(t.ActiveWindow),
+			ActiveHoldWindow:          Duration(t.ActiveHoldWindow),
+			SuspicionWindow:           Duration(t.SuspicionWindow),
+			HistoryTTL:                Duration(t.HistoryTTL),
+			CleanupInterval:           Duration(t.CleanupInterval),
+			ReverseStickyScore:        t.ReverseStickyScore,
+			ForwardStickyScore:        t.ForwardStickyScore,
+			ReverseControlBaseScore:   t.ReverseControlBaseScore,
+			MinInternalTargetsForRev:  t.MinInternalTargetsForRev,
+			MinInternalPortsForRev:    t.MinInternalPortsForRev,
+			OutboundOnlyExternalCap:   t.OutboundOnlyExternalCap,
+			UDPLongLivedPeerMinAge:    Duration(t.UDPLongLivedPeerMinAge),
+			UDPHighPortMin:            t.UDPHighPortMin,
+			ActiveTransferBps:         t.ActiveTransferBps,
+			FlowPairWindow:            Duration(t.FlowPairWindow),
+			BeaconMinIntervals:        t.BeaconMinIntervals,
+			BeaconMinPeriod:           Duration(t.BeaconMinPeriod),
+			BeaconMaxPeriod:           Duration(t.BeaconMaxPeriod),
+			BeaconMaxJitter:           t.BeaconMaxJitter,
+			ScanWindow:                Duration(t.ScanWindow),
+			ScanSweepMinHosts:         t.ScanSweepMinHosts,
+			ScanMinPorts:              t.ScanMinPorts,
+			BenignControlPorts:        benign,
+		},
+		Burst: Burst{
+			SamplesMax:            b.SamplesMax,
+			SamplesMid:            b.SamplesMid,
+			SamplesMin:            b.SamplesMin,
+			Sleep:                 Duration(b.Sleep),
+			IdleConnThreshold:     b.IdleConnThreshold,
+			ModerateConnThreshold: b.ModerateConnThreshold,
+		},
+	}
+}
+
+func withoutPorts(ports []int, drop ...int) []int {
+	out := make([]int, 0, len(ports))
+	for _, p := range ports {
+		keep := true
+		for _, d := range drop {
+			if p == d {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,343 @@
+// Package fingerprint matches candidates against known tunnelling and proxy
+// tools. The built-in pack is embedded; extension files add tools or replace
+// built-in ones by name.
+package fingerprint
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"proxywatch/internal/shared"
+)
+
+//go:embed fingerprints.json
+var builtinJSON []byte
+
+// Fingerprint combines indicator groups. A group matches when any of its
+// entries does; the fingerprint matches when at least MinMatches groups
+// (default 2) match, including every group named in Require and at least one
+// named in RequireAny. RequireAny keeps tools that share generic ports (SOCKS
+// on 1080, HTTP on 8080) from matching on port and role alone.
+type Fingerprint struct {
+	Tool          string   `json:"tool"`
+	Description   string   `json:"description,omitempty"`
+	ProcessNames  []string `json:"process_names,omitempty"`
+	ExePaths      []string `json:"exe_paths,omitempty"`
+	Companies     []string `json:"companies,omitempty"`
+	ListenerPorts []int    `json:"listener_ports,omitempty"`
+	RemotePorts   []int    `json:"remote_ports,omitempty"`
+	Roles         []string `json:"roles,omitempty"`
+	Signals       []string `json:"signals,omitempty"`
+	Require       []string `json:"require,omitempty"`
+	RequireAny    []string `json:"require_any,omitempty"`
+	MinMatches    int      `json:"min_matches,omitempty"`
+}
+
+// indicator group names, as used in Require and in match reports
+const (
+	groupProcessName  = "process_name"
+	groupExePath      = "exe_path"
+	groupCompany      = "company"
+	groupListenerPort = "listener_port"
+	groupRemotePort   = "remote_port"
+	groupRole         = "role"
+	groupSignal       = "signal"
+)
+
+var groupNames = []string{
+	groupProcessName,
+	groupExePath,
+	groupCompany,
+	groupListenerPort,
+	groupRemotePort,
+	groupRole,
+	groupSignal,
+}
+
+type file struct {
+	Fingerprints []Fingerprint `json:"fingerprints"`
+}
+
+// Match is one fingerprint hit and the indicators that matched.
+type Match struct {
+	Tool       string
+	Indicators []string
+}
+
+// Pack is an immutable, ordered set of fingerprints.
+type Pack struct {
+	fps []Fingerprint
+}
+
+func Builtin() *Pack {
+	fps, err := Parse(builtinJSON, "built-in")
+	if err != nil {
+		panic("fingerprint: invalid built-in pack: " + err.Error())
+	}
+	return &Pack{fps: fps}
+}
+
+// Load returns the built-in pack extended with the fingerprints in path.
+func Load(path string) (*Pack, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fps, err := Parse(data, path)
+	if err != nil {
+		return nil, err
+	}
+	return Builtin().Extend(fps), nil
+}
+
+func Parse(data []byte, source string) ([]Fingerprint, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	var f file
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("fingerprints %s: %w", source, err)
+	}
+
+	seen := make(map[string]bool, len(f.Fingerprints))
+	for i, fp := range f.Fingerprints {
+		if err := fp.validate(); err != nil {
+			label := fp.Tool
+			if label == "" {
+				label = fmt.Sprintf("#%d", i+1)
+			}
+			return nil, fmt.Errorf("fingerprints %s: %s: %w", source, label, err)
+		}
+		if seen[fp.Tool] {
+			return nil, fmt.Errorf("fingerprints %s: %s: duplicate tool", source, fp.Tool)
+		}
+		seen[fp.Tool] = true
+	}
+	return f.Fingerprints, nil
+}
+
+func (fp *Fingerprint) validate() error {
+	if fp.Tool == "" {
+		return fmt.Errorf("missing tool")
+	}
+	if strings.ContainsAny(fp.Tool, " \t:") {
+		return fmt.Errorf("tool name must not contain spaces or ':'")
+	}
+	for _, globs := range [][]string{fp.ProcessNames, fp.ExePaths, fp.Companies} {
+		for _, g := range globs {
+			if g == "" || !shared.ValidGlob(g) {
+				return fmt.Errorf("invalid pattern %q", g)
+			}
+		}
+	}
+	for _, ports := range [][]int{fp.ListenerPorts, fp.RemotePorts} {
+		for _, p := range ports {
+			if p <= 0 || p > 65535 {
+				return fmt.Errorf("invalid port %d", p)
+			}
+		}
+	}
+
+	populated := map[string]bool{
+		groupProcessName:  len(fp.ProcessNames) > 0,
+		groupExePath:      len(fp.ExePaths) > 0,
+		groupCompany:      len(fp.Companies) > 0,
+		groupListenerPort: len(fp.ListenerPorts) > 0,
+		groupRemotePort:   len(fp.RemotePorts) > 0,
+		groupRole:         len(fp.Roles) > 0,
+		groupSignal:       len(fp.Signals) > 0,
+	}
+	n := 0
+	for _, ok := range populated {
+		if ok {
+			n++
+		}
+	}
+	for _, r := range append(append([]string(nil), fp.Require...), fp.RequireAny...) {
+		if !populated[r] {
+			return fmt.Errorf("require: %q is not a populated indicator group (groups: %s)", r, strings.Join(groupNames, ", "))
+		}
+	}
+	if fp.MinMatches < 0 {
+		return fmt.Errorf("min_matches must not be negative")
+	}
+	if fp.minMatches() > n {
+		return fmt.Errorf("needs at least %d indicator groups, has %d", fp.minMatches(), n)
+	}
+	return nil
+}
+
+func (fp *Fingerprint) minMatches() int {
+	if fp.MinMatches > 0 {
+		return fp.MinMatches
+	}
+	return 2
+}
+
+// Extend returns a pack with fps added; a fingerprint whose tool already
+// exists replaces it.
+func (p *Pack) Extend(fps []Fingerprint) *Pack {
+	out := &Pack{fps: append([]Fingerprint(nil), p.fps...)}
+	for _, fp := range fps {
+		replaced := false
+		for i := range out.fps {
+			if out.fps[i].Tool == fp.Tool {
+				out.fps[i] = fp
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			out.fps = append(out.fps, fp)
+		}
+	}
+	return out
+}
+
+func (p *Pack) Tools() []string {
+	out := make([]string, len(p.fps))
+	for i, fp := range p.fps {
+		out[i] = fp.Tool
+	}
+	return out
+}
+
+// Match checks c, with the role and signals the classifier derived for it,
+// against every fingerprint.
+func (p *Pack) Match(c *shared.Candidate, role string, signals []string) []Match {
+	if c.Proc == nil {
+		return nil
+	}
+
+	var out []Match
+	for i := range p.fps {
+		fp := &p.fps[i]
+		hit := fp.indicators(c, role, signals)
+
+		ok := len(hit) >= fp.minMatches()
+		for _, r := range fp.Require {
+			if _, matched := hit[r]; !matched {
+				ok = false
+				break
+			}
+		}
+		if ok && len(fp.RequireAny) > 0 {
+			ok = false
+			for _, r := range fp.RequireAny {
+				if _, matched := hit[r]; matched {
+					ok = true
+					break
+				}
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		m := Match{Tool: fp.Tool}
+		for _, g := range groupNames {
+			if desc, matched := hit[g]; matched {
+				m.Indicators = append(m.Indicators, desc)
+			}
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// indicators returns a description per matched group.
+func (fp *Fingerprint) indicators(c *shared.Candidate, role string, signals []string) map[string]string {
+	hit := make(map[string]string)
+
+	if matchAny(fp.ProcessNames, c.Proc.Name) {
+		hit[groupProcessName] = "process name " + c.Proc.Name
+	}
+	if matchAny(fp.ExePaths, c.Proc.ExePath) {
+		hit[groupExePath] = "exe path " + c.Proc.ExePath
+	}
+	if matchAny(fp.Companies, c.Proc.Company) {
+		hit[groupCompany] = "company " + c.Proc.Company
+	}
+
+	if len(fp.ListenerPorts) > 0 {
+		for _, l := range c.Listeners {
+			if containsInt(fp.ListenerPorts, l.LocalPort) {
+				hit[groupListenerPort] = fmt.Sprintf("listener port %d", l.LocalPort)
+				break
+			}
+		}
+		if _, ok := hit[groupListenerPort]; !ok {
+			for _, u := range c.UDPListeners {
+				if containsInt(fp.ListenerPorts, u.LocalPort) {
+					hit[groupListenerPort] = fmt.Sprintf("UDP listener port %d", u.LocalPort)
+					break
+				}
+			}
+		}
+	}
+
+	if len(fp.RemotePorts) > 0 {
+		listening := make(map[int]bool, len(c.Listeners))
+		for _, l := range c.Listeners {
+			listening[l.LocalPort] = true
+		}
+		for _, cn := range c.Conns {
+			if listening[cn.LocalPort] || cn.RemoteAddress == "" ||
+				shared.IsWildcardIP(cn.RemoteAddress) || shared.IsLoopbackIP(cn.RemoteAddress) {
+				continue
+			}
+			if containsInt(fp.RemotePorts, cn.RemotePort) {
+				hit[groupRemotePort] = fmt.Sprintf("remote port %d", cn.RemotePort)
+				break
+			}
+		}
+	}
+
+	for _, r := range fp.Roles {
+		if r == role {
+			hit[groupRole] = "role " + role
+			break
+		}
+	}
+
+	for _, want := range fp.Signals {
+		found := false
+		for _, s := range signals {
+			if s == want {
+				found = true
+				break
+			}
+		}
+		if found {
+			hit[groupSignal] = "signal " + want
+			break
+		}
+	}
+
+	return hit
+}
+
+func matchAny(patterns []string, value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, p := range patterns {
+		if shared.MatchGlob(p, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(list []int, v int) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,299 @@
+// Package ioc matches candidates against flat-file threat intel: IP and CIDR
+// lists, SHA-256 executable hashes and executable names.
+package ioc
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"proxywatch/internal/shared"
+)
+
+// entry is where an indicator came from. The same indicator may appear in
+// several lists.
+type entry struct {
+	list      string
+	indicator string
+}
+
+// Set holds every loaded indicator in lookup-friendly form: exact maps for
+// addresses, hashes and names, and CIDRs bucketed by prefix length so an
+// address costs one map lookup per distinct length.
+type Set struct {
+	Dir   string
+	Lists []string
+
+	addrs    map[netip.Addr][]entry
+	prefixes map[int]map[netip.Prefix][]entry
+	bits     []int // prefix lengths present, longest first
+	hashes   map[string][]entry
+	names    map[string][]entry
+
+	hashMu    sync.Mutex
+	hashCache map[string]fileHash
+}
+
+type fileHash struct {
+	size    int64
+	modTime time.Time
+	sum     string
+}
+
+func newSet(dir string) *Set {
+	return &Set{
+		Dir:       dir,
+		addrs:     make(map[netip.Addr][]entry),
+		prefixes:  make(map[int]map[netip.Prefix][]entry),
+		hashes:    make(map[string][]entry),
+		names:     make(map[string][]entry),
+		hashCache: make(map[string]fileHash),
+	}
+}
+
+// Load reads every regular, non-hidden file in dir as one list named after
+// the file. Lines hold one indicator each (only the first comma- or
+// whitespace-separated field is used); '#' starts a comment.
+func Load(dir string) (*Set, error) {
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	s := newSet(dir)
+	for _, de := range ents {
+		if !de.Type().IsRegular() || strings.HasPrefix(de.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, de.Name())
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		list := strings.TrimSuffix(de.Name(), filepath.Ext(de.Name()))
+		err = s.read(f, list, path)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		s.Lists = append(s.Lists, list)
+	}
+	if len(s.Lists) == 0 {
+		return nil, fmt.Errorf("ioc %s: no list files", dir)
+	}
+
+	for bits := range s.prefixes {
+		s.bits = append(s.bits, bits)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(s.bits)))
+	return s, nil
+}
+
+func (s *Set) read(r io.Reader, list, source string) error {
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t' || r == ';'
+		})
+		if len(fields) == 0 {
+			continue
+		}
+		if err := s.add(list, fields[0]); err != nil {
+			return fmt.Errorf("ioc %s:%d: %w", source, n, err)
+		}
+	}
+	return sc.Err()
+}
+
+func (s *Set) add(list, ind string) error {
+	e := entry{list: list, indicator: ind}
+
+	if addr, err := netip.ParseAddr(ind); err == nil {
+		addr = addr.Unmap()
+		s.addrs[addr] = append(s.addrs[addr], e)
+		return nil
+	}
+	if strings.Contains(ind, "/") && !strings.ContainsAny(ind, `\`) && strings.ContainsAny(ind, ".:") {
+		p, err := netip.ParsePrefix(ind)
+		if err != nil {
+			return fmt.Errorf("invalid CIDR %q", ind)
+		}
+		p = netip.PrefixFrom(p.Addr().Unmap(), unmappedBits(p)).Masked()
+		if s.prefixes[p.Bits()] == nil {
+			s.prefixes[p.Bits()] = make(map[netip.Prefix][]entry)
+		}
+		s.prefixes[p.Bits()][p] = append(s.prefixes[p.Bits()][p], e)
+		return nil
+	}
+	if isSHA256(ind) {
+		h := strings.ToLower(ind)
+		s.hashes[h] = append(s.hashes[h], e)
+		return nil
+	}
+	if strings.ContainsAny(ind, `/\`) {
+		return fmt.Errorf("%q is not an IP, CIDR, SHA-256 hash or executable name", ind)
+	}
+	n := normalizeName(ind)
+	s.names[n] = append(s.names[n], e)
+	return nil
+}
+
+// unmappedBits converts the length of an IPv4-mapped IPv6 prefix to its IPv4
+// length.
+func unmappedBits(p netip.Prefix) int {
+	if p.Addr().Is4In6() {
+		return max(p.Bits()-96, 0)
+	}
+	return p.Bits()
+}
+
+func isSHA256(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// normalizeName folds case and a trailing ".exe" so one list serves Windows
+// and Linux hosts.
+func normalizeName(n string) string {
+	return strings.TrimSuffix(strings.ToLower(n), ".exe")
+}
+
+func (s *Set) Match(c *shared.Candidate) []shared.IOCHit {
+	if c == nil || c.Proc == nil {
+		return nil
+	}
+
+	var hits []shared.IOCHit
+	seen := make(map[string]bool)
+	add := func(es []entry, kind, where, observed string) {
+		for _, e := range es {
+			key := e.list + "|" + e.indicator + "|" + where + "|" + observed
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			hits = append(hits, shared.IOCHit{
+				List:      e.list,
+				Indicator: e.indicator,
+				Kind:      kind,
+				Where:     where,
+				Observed:  observed,
+			})
+		}
+	}
+
+	s.matchName(c.Proc.Name, add)
+	if c.Proc.ExePath != "" {
+		base := filepath.Base(strings.ReplaceAll(c.Proc.ExePath, `\`, "/"))
+		if normalizeName(base) != normalizeName(c.Proc.Name) {
+			s.matchName(base, add)
+		}
+		if len(s.hashes) > 0 {
+			if sum := s.exeHash(c.Proc.ExePath); sum != "" {
+				add(s.hashes[sum], "sha256", "process", sum)
+			}
+		}
+	}
+
+	for _, cn := range c.Conns {
+		s.matchAddr(cn.RemoteAddress, "remote", add)
+	}
+	for _, l := range c.Listeners {
+		s.matchAddr(l.LocalAddress, "listener", add)
+	}
+	for _, u := range c.UDPConns {
+		s.matchAddr(u.RemoteAddress, "udp-remote", add)
+	}
+	for _, u := range c.UDPListeners {
+		s.matchAddr(u.LocalAddress, "udp-listener", add)
+	}
+	return hits
+}
+
+func (s *Set) matchName(name string, add func([]entry, string, string, string)) {
+	if name == "" {
+		return
+	}
+	if es := s.names[normalizeName(name)]; len(es) > 0 {
+		add(es, "name", "process", name)
+	}
+}
+
+func (s *Set) matchAddr(ip string, where string, add func([]entry, string, string, string)) {
+	if ip == "" || shared.IsWildcardIP(ip) {
+		return
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return
+	}
+	addr = addr.Unmap()
+	if es := s.addrs[addr]; len(es) > 0 {
+		add(es, "ip", where, ip)
+	}
+	for _, bits := range s.bits {
+		if bits > addr.BitLen() {
+			continue
+		}
+		p, err := addr.Prefix(bits)
+		if err != nil {
+			continue
+		}
+		if es := s.prefixes[bits][p]; len(es) > 0 {
+			add(es, "cidr", where, ip)
+		}
+	}
+}
+
+// exeHash returns the lower-case SHA-256 of the file at path, cached by size
+// and modification time. Unreadable files (other users' processes, replays
+// from another host) yield "".
+func (s *Set) exeHash(path string) string {
+	fi, err := os.Stat(path)
+	if err != nil || !fi.Mode().IsRegular() {
+		return ""
+	}
+
+	s.hashMu.Lock()
+	defer s.hashMu.Unlock()
+
+	if h, ok := s.hashCache[path]; ok && h.size == fi.Size() && h.modTime.Equal(fi.ModTime()) {
+		return h.sum
+	}
+
+	// failures are cached too, so an unreadable binary is not retried
+	// every tick
+	sum := hashFile(path)
+	s.hashCache[path] = fileHash{size: fi.Size(), modTime: fi.ModTime(), sum: sum}
+	return sum
+}
+
+func hashFile(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
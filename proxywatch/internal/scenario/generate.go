@@ -0,0 +1,231 @@
+package scenario
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+
+	"proxywatch/internal/shared"
+)
+
+// Epoch is the timestamp of every scenario's first snapshot, so runs are
+// reproducible.
+var Epoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+const firstEphemeralPort = 49152
+
+// socket is one generated socket and the interval it exists in.
+type socket struct {
+	from, until time.Duration // until 0: to the end
+	listener    *shared.ListenerInfo
+	conn        *shared.ConnectionInfo
+	udpListener *shared.UDPListenerInfo
+	udpConn     *shared.UDPConnInfo
+}
+
+func (s socket) aliveAt(t time.Duration) bool {
+	return t >= s.from && (s.until == 0 || t < s.until)
+}
+
+// Snapshots renders the scenario as one snapshot per step, from 0 to
+// Duration inclusive.
+func (s *Scenario) Snapshots() []*shared.Snapshot {
+	sockets := s.sockets()
+
+	var out []*shared.Snapshot
+	for t := time.Duration(0); t <= s.Duration; t += s.Step {
+		snap := &shared.Snapshot{
+			Timestamp: Epoch.Add(t),
+			Processes: make(map[int]*shared.ProcessInfo, len(s.procs)),
+		}
+		for _, p := range s.procs {
+			snap.Processes[p.pid] = &shared.ProcessInfo{
+				Pid:       p.pid,
+				ParentPid: p.parent,
+				Name:      p.name,
+				ExePath:   p.path,
+				Company:   p.company,
+				UserName:  p.user,
+				Status:    "Running",
+			}
+		}
+		for _, so := range sockets {
+			if !so.aliveAt(t) {
+				continue
+			}
+			switch {
+			case so.listener != nil:
+				snap.Listeners = append(snap.Listeners, *so.listener)
+			case so.conn != nil:
+				snap.Connections = append(snap.Connections, *so.conn)
+			case so.udpListener != nil:
+				snap.UDPListeners = append(snap.UDPListeners, *so.udpListener)
+			case so.udpConn != nil:
+				snap.UDPConns = append(snap.UDPConns, *so.udpConn)
+			}
+		}
+		out = append(out, snap)
+	}
+	return out
+}
+
+// sockets expands every event into concrete sockets with fixed ephemeral
+// ports.
+func (s *Scenario) sockets() []socket {
+	var out []socket
+	ephemeral := firstEphemeralPort
+	nextPort := func() int {
+		p := ephemeral
+		ephemeral++
+		return p
+	}
+
+	for _, ev := range s.events {
+		for start := ev.at; start <= s.Duration; start += ev.every {
+			until := time.Duration(0)
+			if ev.dur > 0 {
+				until = start + ev.dur
+			}
+
+			for _, host := range ev.hosts {
+				for _, port := range ev.ports {
+					so := socket{from: start, until: until}
+					switch ev.kind {
+					case "listen":
+						if ev.udp {
+							so.udpListener = &shared.UDPListenerInfo{Pid: ev.pid, LocalAddress: host, LocalPort: port}
+						} else {
+							so.listener = &shared.ListenerInfo{Pid: ev.pid, LocalAddress: host, LocalPort: port, State: "LISTEN"}
+						}
+					case "connect":
+						laddr, lport := s.localEndpoint(ev.from, host, nextPort)
+						state := ev.state
+						if state == "" {
+							state = "ESTABLISHED"
+						}
+						so.conn = &shared.ConnectionInfo{
+							Pid:           ev.pid,
+							LocalAddress:  laddr,
+							LocalPort:     lport,
+							RemoteAddress: host,
+							RemotePort:    port,
+							State:         state,
+						}
+					case "accept":
+						so.conn = &shared.ConnectionInfo{
+							Pid:           ev.pid,
+							LocalAddress:  s.listenAddress(ev.pid, port, host),
+							LocalPort:     port,
+							RemoteAddress: host,
+							RemotePort:    nextPort(),
+							State:         "ESTABLISHED",
+						}
+					case "udp":
+						laddr, lport := s.localEndpoint(ev.from, host, nextPort)
+						so.udpConn = &shared.UDPConnInfo{
+							Pid:           ev.pid,
+							LocalAddress:  laddr,
+							LocalPort:     lport,
+							RemoteAddress: host,
+							RemotePort:    port,
+						}
+					}
+					out = append(out, so)
+				}
+			}
+
+			if ev.every == 0 {
+				break
+			}
+		}
+	}
+	return out
+}
+
+// localEndpoint resolves a "from" override, or picks the loopback or host
+// address to match the remote end and a fresh ephemeral port.
+func (s *Scenario) localEndpoint(from, remote string, nextPort func() int) (string, int) {
+	if from != "" {
+		host, port, _ := splitHostPort(from)
+		if p, err := strconv.Atoi(port); err == nil && p > 0 {
+			return host, p
+		}
+		return host, nextPort()
+	}
+	if shared.IsLoopbackIP(remote) {
+		return "127.0.0.1", nextPort()
+	}
+	return s.Host, nextPort()
+}
+
+// listenAddress is the local address an accepted connection arrives on: the
+// pid's listener on port, with wildcards resolved to the host (or loopback for
+// loopback clients).
+func (s *Scenario) listenAddress(pid, port int, client string) string {
+	for _, ev := range s.events {
+		if ev.kind != "listen" || ev.udp || ev.pid != pid || ev.ports[0] != port {
+			continue
+		}
+		addr := ev.hosts[0]
+		if !shared.IsWildcardIP(addr) {
+			return addr
+		}
+		break
+	}
+	if a, err := netip.ParseAddr(client); err == nil && a.IsLoopback() {
+		return "127.0.0.1"
+	}
+	return s.Host
+}
+
+/* ---------------- source ---------------- */
+
+// Source replays a scenario's snapshots, then reports ErrSourceExhausted.
+type Source struct {
+	mu    sync.Mutex
+	name  string
+	snaps []*shared.Snapshot
+	next  int
+}
+
+func init() {
+	shared.RegisterSource("scenario", func(arg string) (shared.Source, error) {
+		if arg == "" {
+			return nil, errors.New("scenario source requires a built-in scenario name (scenario:<name>)")
+		}
+		s := Find(Builtin(), arg)
+		if s == nil {
+			return nil, fmt.Errorf("unknown scenario %q", arg)
+		}
+		return NewSource(s), nil
+	})
+}
+
+func NewSource(s *Scenario) *Source {
+	return &Source{name: s.Name, snaps: s.Snapshots()}
+}
+
+func (s *Source) Name() string { return "scenario" }
+
+func (s *Source) Capabilities() shared.SourceCapabilities {
+	return shared.SourceCapabilities{UDP: true, UDPPeers: true, PerConnection: true}
+}
+
+func (s *Source) Collect(ctx context.Context) (*shared.Snapshot, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.next >= len(s.snaps) {
+		return nil, shared.ErrSourceExhausted
+	}
+	snap := s.snaps[s.next]
+	s.next++
+	return snap, nil
+}
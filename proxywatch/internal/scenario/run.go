@@ -0,0 +1,100 @@
+package scenario
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+
+	"proxywatch/internal/classifier"
+	"proxywatch/internal/shared"
+)
+
+// Result is the outcome of one scenario run.
+type Result struct {
+	Scenario *Scenario
+	Final    map[int]shared.Candidate // every candidate on the last tick
+	Failures []string
+}
+
+func (r *Result) Passed() bool { return len(r.Failures) == 0 }
+
+// Run feeds the scenario through engine, which should be fresh so no history
+// leaks between scenarios, and checks the expectations on the last tick.
+func Run(s *Scenario, engine *classifier.Engine) *Result {
+	res := &Result{Scenario: s, Final: make(map[int]shared.Candidate)}
+
+	src := NewSource(s)
+	var last []shared.Candidate
+	for {
+		snap, err := src.Collect(context.Background())
+		if err != nil {
+			break
+		}
+		last = engine.Classify(snap, shared.ClassifyOptions{MinScore: math.MinInt})
+	}
+	for _, c := range last {
+		res.Final[c.Proc.Pid] = c
+	}
+
+	for _, x := range s.Expects {
+		if msg := check(x, res.Final); msg != "" {
+			res.Failures = append(res.Failures, msg)
+		}
+	}
+	return res
+}
+
+// check returns a failure message, or "" when x holds.
+func check(x Expectation, final map[int]shared.Candidate) string {
+	c, ok := final[x.Pid]
+	if !ok {
+		return fmt.Sprintf("pid %d not reported on the last tick", x.Pid)
+	}
+
+	switch x.What {
+	case "role":
+		if c.Role != x.Value {
+			return fmt.Sprintf("pid %d role = %s, want %s", x.Pid, c.Role, x.Value)
+		}
+	case "active":
+		if strconv.FormatBool(c.ActiveProxying) != x.Value {
+			return fmt.Sprintf("pid %d active = %v, want %s", x.Pid, c.ActiveProxying, x.Value)
+		}
+	case "signal", "no-signal":
+		has := false
+		for _, sig := range c.Signals {
+			if sig == x.Value {
+				has = true
+				break
+			}
+		}
+		if has != (x.What == "signal") {
+			return fmt.Sprintf("pid %d %s %s (signals: %v)", x.Pid, map[bool]string{true: "has", false: "lacks"}[has], x.Value, c.Signals)
+		}
+	case "score":
+		want, _ := strconv.Atoi(x.Value)
+		if !compare(c.Score, x.Op, want) {
+			return fmt.Sprintf("pid %d score = %d, want %s %d", x.Pid, c.Score, x.Op, want)
+		}
+	}
+	return ""
+}
+
+func compare(a int, op string, b int) bool {
+	switch op {
+	case "==":
+		return a == b
+	case "!=":
+		return a != b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	}
+	return false
+}
@@ -0,0 +1,500 @@
+// Package scenario describes synthetic process and socket activity over time
+// in a small line-based DSL, turns it into snapshot sequences and checks the
+// classifier's verdicts against expected outcomes.
+//
+//	scenario chisel-reverse-socks
+//	  describe chisel reverse socks with 3 internal targets after 15s
+//	  duration 60s
+//	  process 120 chisel.exe path=C:\Tools\chisel.exe
+//	  at 0s  connect 120 203.0.113.12:443
+//	  at 15s connect 120 10.0.1.10-12:445
+//	  expect 120 role reverse-proxy
+//	  expect 120 score >= 90
+//
+// Statements:
+//
+//	scenario <name>                 start a scenario; later lines belong to it
+//	describe <text>
+//	duration <d> | step <d>         run length (default 60s), tick (default 5s)
+//	host <ip>                       local address of the host (default 10.0.0.2)
+//	process <pid> <name> [path=..] [company=..] [user=..] [parent=<pid>]   (_ in company is a space)
+//	at <t> listen  <pid> <addr>:<port> [udp]
+//	at <t> connect <pid> <host>:<port> [from <addr>:<port>] [state=<S>] [for <d>] [every <d>]
+//	at <t> accept  <pid> <port> from <host> [for <d>] [every <d>]
+//	at <t> udp     <pid> <host>:<port> [from <addr>:<port>] [for <d>] [every <d>]
+//	expect <pid> role <role> | score <op> <n> | signal <s> | no-signal <s> | active <bool>
+//
+// Hosts may end in an octet range (10.0.1.1-30) and ports may be ranges
+// (1-100); a statement then opens one socket per host and port. Sockets live
+// from <t> for <d> (default: to the end), repeating every <d> when given.
+// '#' starts a comment.
+package scenario
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed builtin.scn
+var builtinScenarios []byte
+
+const (
+	defaultDuration = 60 * time.Second
+	defaultStep     = 5 * time.Second
+	defaultHost     = "10.0.0.2"
+)
+
+// Scenario is one parsed scenario.
+type Scenario struct {
+	Name        string
+	Description string
+	Duration    time.Duration
+	Step        time.Duration
+	Host        string
+	Source      string // file:line it was declared at
+
+	procs   []process
+	events  []event
+	Expects []Expectation
+}
+
+type process struct {
+	pid     int
+	name    string
+	path    string
+	company string
+	user    string
+	parent  int
+}
+
+type event struct {
+	line  int
+	at    time.Duration
+	dur   time.Duration // 0: until the end
+	every time.Duration // 0: once
+	kind  string        // "listen", "connect", "accept", "udp"
+	pid   int
+	udp   bool // listen only
+	hosts []string
+	ports []int
+	from  string // local addr:port override
+	state string
+}
+
+// Expectation is one checked outcome for a process on the final tick.
+type Expectation struct {
+	Pid   int
+	What  string // "role", "score", "signal", "no-signal", "active"
+	Op    string // score comparisons only
+	Value string
+	Line  int
+}
+
+func (x Expectation) String() string {
+	if x.What == "score" {
+		return fmt.Sprintf("%d score %s %s", x.Pid, x.Op, x.Value)
+	}
+	return fmt.Sprintf("%d %s %s", x.Pid, x.What, x.Value)
+}
+
+// Builtin returns the shipped scenarios, one or more per classifier role.
+func Builtin() []*Scenario {
+	list, err := Parse(builtinScenarios, "builtin.scn")
+	if err != nil {
+		panic("scenario: invalid built-in scenarios: " + err.Error())
+	}
+	return list
+}
+
+func Load(path string) ([]*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data, path)
+}
+
+// Find returns the scenario called name from list, or nil.
+func Find(list []*Scenario, name string) *Scenario {
+	for _, s := range list {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+func Parse(data []byte, source string) ([]*Scenario, error) {
+	var (
+		out []*Scenario
+		cur *Scenario
+	)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			continue
+		}
+
+		if f[0] == "scenario" {
+			if len(f) != 2 {
+				return nil, fmt.Errorf("%s:%d: scenario takes a name", source, n)
+			}
+			if cur != nil {
+				if err := cur.validate(); err != nil {
+					return nil, err
+				}
+			}
+			if Find(out, f[1]) != nil {
+				return nil, fmt.Errorf("%s:%d: duplicate scenario %q", source, n, f[1])
+			}
+			cur = &Scenario{
+				Name:     f[1],
+				Duration: defaultDuration,
+				Step:     defaultStep,
+				Host:     defaultHost,
+				Source:   fmt.Sprintf("%s:%d", source, n),
+			}
+			out = append(out, cur)
+			continue
+		}
+		if cur == nil {
+			return nil, fmt.Errorf("%s:%d: %q before the first scenario", source, n, f[0])
+		}
+		if err := cur.parseLine(f, strings.TrimSpace(line), n); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", source, n, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if cur != nil {
+		if err := cur.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("%s: no scenarios", source)
+	}
+	return out, nil
+}
+
+func (s *Scenario) parseLine(f []string, line string, n int) error {
+	var err error
+	switch f[0] {
+	case "describe":
+		s.Description = strings.TrimSpace(strings.TrimPrefix(line, "describe"))
+	case "duration", "step":
+		if len(f) != 2 {
+			return fmt.Errorf("%s takes one duration", f[0])
+		}
+		d, err := parseDuration(f[1])
+		if err != nil {
+			return err
+		}
+		if d <= 0 {
+			return fmt.Errorf("%s must be positive", f[0])
+		}
+		if f[0] == "duration" {
+			s.Duration = d
+		} else {
+			s.Step = d
+		}
+	case "host":
+		if len(f) != 2 {
+			return fmt.Errorf("host takes one address")
+		}
+		if _, err := netip.ParseAddr(f[1]); err != nil {
+			return fmt.Errorf("invalid host %q", f[1])
+		}
+		s.Host = f[1]
+	case "process":
+		err = s.parseProcess(f)
+	case "at":
+		err = s.parseEvent(f, n)
+	case "expect":
+		err = s.parseExpect(f, n)
+	default:
+		return fmt.Errorf("unknown statement %q", f[0])
+	}
+	return err
+}
+
+func (s *Scenario) parseProcess(f []string) error {
+	if len(f) < 3 {
+		return fmt.Errorf("process takes <pid> <name> [key=value...]")
+	}
+	pid, err := parsePid(f[1])
+	if err != nil {
+		return err
+	}
+	if s.process(pid) != nil {
+		return fmt.Errorf("process %d declared twice", pid)
+	}
+	p := process{pid: pid, name: f[2]}
+	for _, kv := range f[3:] {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			return fmt.Errorf("expected key=value, got %q", kv)
+		}
+		switch k {
+		case "path":
+			p.path = v
+		case "company":
+			p.company = strings.ReplaceAll(v, "_", " ")
+		case "user":
+			p.user = v
+		case "parent":
+			if p.parent, err = parsePid(v); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown process key %q", k)
+		}
+	}
+	s.procs = append(s.procs, p)
+	return nil
+}
+
+func (s *Scenario) parseEvent(f []string, n int) error {
+	if len(f) < 5 {
+		return fmt.Errorf("at takes <time> <listen|connect|accept|udp> <pid> <target> ...")
+	}
+	at, err := parseDuration(f[1])
+	if err != nil {
+		return err
+	}
+	ev := event{line: n, at: at, kind: f[2]}
+	if ev.pid, err = parsePid(f[3]); err != nil {
+		return err
+	}
+
+	rest := f[5:]
+	switch ev.kind {
+	case "listen", "connect", "udp":
+		if ev.hosts, ev.ports, err = parseEndpoint(f[4]); err != nil {
+			return err
+		}
+		if ev.kind == "listen" && (len(ev.hosts) != 1 || len(ev.ports) != 1) {
+			return fmt.Errorf("listen takes a single address and port")
+		}
+	case "accept":
+		port, err := strconv.Atoi(f[4])
+		if err != nil || port <= 0 || port > 65535 {
+			return fmt.Errorf("invalid port %q", f[4])
+		}
+		ev.ports = []int{port}
+		if len(rest) < 2 || rest[0] != "from" {
+			return fmt.Errorf("accept needs from <host>")
+		}
+		if ev.hosts, err = parseHosts(rest[1]); err != nil {
+			return err
+		}
+		rest = rest[2:]
+	default:
+		return fmt.Errorf("unknown event %q", ev.kind)
+	}
+
+	for i := 0; i < len(rest); i++ {
+		tok := rest[i]
+		switch {
+		case tok == "udp" && ev.kind == "listen":
+			ev.udp = true
+		case tok == "from" && (ev.kind == "connect" || ev.kind == "udp") && i+1 < len(rest):
+			i++
+			if _, _, err := splitHostPort(rest[i]); err != nil {
+				return err
+			}
+			ev.from = rest[i]
+		case (tok == "for" || tok == "every") && ev.kind != "listen" && i+1 < len(rest):
+			i++
+			d, err := parseDuration(rest[i])
+			if err != nil {
+				return err
+			}
+			if d <= 0 {
+				return fmt.Errorf("%s must be positive", tok)
+			}
+			if tok == "for" {
+				ev.dur = d
+			} else {
+				ev.every = d
+			}
+		case strings.HasPrefix(tok, "state=") && ev.kind == "connect":
+			ev.state = strings.ToUpper(strings.TrimPrefix(tok, "state="))
+		default:
+			return fmt.Errorf("unexpected %q in %s", tok, ev.kind)
+		}
+	}
+	s.events = append(s.events, ev)
+	return nil
+}
+
+func (s *Scenario) parseExpect(f []string, n int) error {
+	if len(f) < 4 {
+		return fmt.Errorf("expect takes <pid> <role|score|signal|no-signal|active> ...")
+	}
+	pid, err := parsePid(f[1])
+	if err != nil {
+		return err
+	}
+	x := Expectation{Pid: pid, What: f[2], Line: n}
+	switch x.What {
+	case "role", "signal", "no-signal":
+		if len(f) != 4 {
+			return fmt.Errorf("expect %s takes one value", x.What)
+		}
+		x.Value = f[3]
+	case "active":
+		if len(f) != 4 || (f[3] != "true" && f[3] != "false") {
+			return fmt.Errorf("expect active takes true or false")
+		}
+		x.Value = f[3]
+	case "score":
+		if len(f) != 5 {
+			return fmt.Errorf("expect score takes <op> <n>")
+		}
+		switch f[3] {
+		case "==", "!=", "<", "<=", ">", ">=":
+		default:
+			return fmt.Errorf("unknown comparison %q", f[3])
+		}
+		if _, err := strconv.Atoi(f[4]); err != nil {
+			return fmt.Errorf("invalid score %q", f[4])
+		}
+		x.Op, x.Value = f[3], f[4]
+	default:
+		return fmt.Errorf("unknown expectation %q", x.What)
+	}
+	s.Expects = append(s.Expects, x)
+	return nil
+}
+
+func (s *Scenario) validate() error {
+	for _, ev := range s.events {
+		if s.process(ev.pid) == nil {
+			return fmt.Errorf("%s: scenario %s: line %d: process %d is not declared", s.Source, s.Name, ev.line, ev.pid)
+		}
+	}
+	for _, x := range s.Expects {
+		if s.process(x.Pid) == nil {
+			return fmt.Errorf("%s: scenario %s: line %d: process %d is not declared", s.Source, s.Name, x.Line, x.Pid)
+		}
+	}
+	if len(s.procs) == 0 {
+		return fmt.Errorf("%s: scenario %s: no processes", s.Source, s.Name)
+	}
+	return nil
+}
+
+func (s *Scenario) process(pid int) *process {
+	for i := range s.procs {
+		if s.procs[i].pid == pid {
+			return &s.procs[i]
+		}
+	}
+	return nil
+}
+
+func parsePid(v string) (int, error) {
+	pid, err := strconv.Atoi(v)
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("invalid pid %q", v)
+	}
+	return pid, nil
+}
+
+func parseDuration(v string) (time.Duration, error) {
+	if v == "0" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", v)
+	}
+	return d, nil
+}
+
+// parseEndpoint expands "host[-last]:port[-last]".
+func parseEndpoint(v string) ([]string, []int, error) {
+	host, port, err := splitHostPort(v)
+	if err != nil {
+		return nil, nil, err
+	}
+	hosts, err := parseHosts(host)
+	if err != nil {
+		return nil, nil, err
+	}
+	ports, err := parseRange(port, 0, 65535)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid port %q", port)
+	}
+	return hosts, ports, nil
+}
+
+func splitHostPort(v string) (string, string, error) {
+	i := strings.LastIndexByte(v, ':')
+	if i <= 0 {
+		return "", "", fmt.Errorf("expected <host>:<port>, got %q", v)
+	}
+	host := strings.TrimSuffix(strings.TrimPrefix(v[:i], "["), "]")
+	return host, v[i+1:], nil
+}
+
+// parseHosts expands an IPv4 last-octet range such as 10.0.1.1-30.
+func parseHosts(v string) ([]string, error) {
+	if addr, err := netip.ParseAddr(v); err == nil {
+		return []string{addr.String()}, nil
+	}
+	dot := strings.LastIndexByte(v, '.')
+	if dot < 0 {
+		return nil, fmt.Errorf("invalid host %q", v)
+	}
+	prefix := v[:dot+1]
+	octets, err := parseRange(v[dot+1:], 0, 255)
+	if err != nil {
+		return nil, fmt.Errorf("invalid host %q", v)
+	}
+	var out []string
+	for _, o := range octets {
+		h := prefix + strconv.Itoa(o)
+		if addr, err := netip.ParseAddr(h); err != nil || !addr.Is4() {
+			return nil, fmt.Errorf("invalid host %q", v)
+		}
+		out = append(out, h)
+	}
+	return out, nil
+}
+
+func parseRange(v string, lo, hi int) ([]int, error) {
+	first, last, isRange := strings.Cut(v, "-")
+	a, err := strconv.Atoi(first)
+	if err != nil {
+		return nil, err
+	}
+	b := a
+	if isRange {
+		if b, err = strconv.Atoi(last); err != nil {
+			return nil, err
+		}
+	}
+	if a < lo || b > hi || a > b {
+		return nil, fmt.Errorf("range %q out of bounds", v)
+	}
+	out := make([]int, 0, b-a+1)
+	for i := a; i <= b; i++ {
+		out = append(out, i)
+	}
+	return out, nil
+}
@@ -0,0 +1,77 @@
+package scenario
+
+import (
+	"testing"
+
+	"proxywatch/internal/classifier"
+	"proxywatch/internal/shared"
+)
+
+func TestBuiltinScenarios(t *testing.T) {
+	list := Builtin()
+	if len(list) == 0 {
+		t.Fatal("no built-in scenarios")
+	}
+	for _, s := range list {
+		t.Run(s.Name, func(t *testing.T) {
+			res := Run(s, classifier.NewEngine(shared.DefaultThresholds()))
+			for _, f := range res.Failures {
+				t.Error(f)
+			}
+		})
+	}
+}
+
+// every role the classifier can assign has a scenario asserting it
+func TestBuiltinScenariosCoverRoles(t *testing.T) {
+	roles := []string{
+		"reverse-transport",
+		"reverse-proxy",
+		"reverse-control",
+		"scanner",
+		"proxy-listener",
+		"udp-relay",
+		"tunnel-likely",
+		"udp-tunnel",
+		"beacon",
+		"listener-with-clients",
+		"listener-with-outbound",
+		"listener-only",
+		"outbound-only",
+		"no-network-activity",
+	}
+
+	asserted := make(map[string]bool)
+	for _, s := range Builtin() {
+		for _, x := range s.Expects {
+			if x.What == "role" {
+				asserted[x.Value] = true
+			}
+		}
+	}
+	for _, role := range roles {
+		if !asserted[role] {
+			t.Errorf("no built-in scenario expects role %s", role)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"no scenario", "process 1 a\n"},
+		{"unknown directive", "scenario a\n  frobnicate 1\n"},
+		{"unknown pid", "scenario a\n  duration 10s\n  at 0s connect 9 10.0.0.1:445\n  expect 9 role scanner\n"},
+		{"bad duration", "scenario a\n  duration ten\n"},
+		{"bad endpoint", "scenario a\n  duration 10s\n  process 1 a\n  at 0s connect 1 10.0.0.1\n  expect 1 role scanner\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.src), "test.scn"); err == nil {
+				t.Errorf("Parse(%q) succeeded", tt.src)
+			}
+		})
+	}
+}
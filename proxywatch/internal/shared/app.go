@@ -1,6 +1,9 @@
 package shared
 
 import (
+	"context"
+	"errors"
+	"sync"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
@@ -11,6 +14,8 @@ type AppMode int
 const (
 	ModeDashboard AppMode = iota
 	ModeInspect
+	ModeChain
+	ModeWhy
 )
 
 type AppState struct {
@@ -24,17 +29,56 @@ type AppState struct {
 	ConfirmKillPID      int
 	ConfirmKillDeadline time.Time
 
-	Candidates  []Candidate
-	Mode        AppMode
+	Candidates []Candidate
+	Mode       AppMode
+
+	// suppressed candidates are dropped from Candidates unless
+	// ShowSuppressed is set, but always counted; Classified keeps every
+	// candidate of the last tick so the filter can change without a refresh
+	ShowSuppressed  bool
+	SuppressedCount int
+	Classified      []Candidate
+
+	// SourcePaced starts the next refresh as soon as one lands instead of
+	// on the tick, for sources that pace themselves (replays). SourceDone is
+	// set once a finite source has run dry.
+	SourcePaced bool
+	SourceDone  bool
+
 	SelectedPID int
 	SelectedIdx int
 	InspectPID  int
+
+	// Zones labels remote addresses internal or external on screen; set it
+	// to the engine's so the display agrees with the scores.
+	Zones *NetworkZones
+
+	// Reloader, when set, is invoked by the reload key and whenever a
+	// value arrives on ReloadRequests (e.g. on SIGHUP).
+	Reloader       Reloader
+	ReloadRequests <-chan struct{}
 }
 
 type Scanner interface {
 	Refresh(app *AppState)
 }
 
+// Reloader re-applies the configuration without restarting the scanner or
+// dropping classifier history.
+type Reloader interface {
+	Reload() error
+}
+
+type ReloaderFunc func() error
+
+func (f ReloaderFunc) Reload() error { return f() }
+
+// Observer is handed every classified snapshot after it has been logged, e.g.
+// to forward findings to a collector. Errors are shown, not fatal.
+type Observer interface {
+	Observe(snap *Snapshot, cands []Candidate) error
+}
+
 type IOSample struct {
 	Read      uint64
 	Write     uint64
@@ -42,38 +86,63 @@ type IOSample struct {
 	Timestamp time.Time
 }
 
+type ConnSample struct {
+	Sent      uint64
+	Received  uint64
+	Timestamp time.Time
+}
+
 type ScannerAdapter struct {
-	Options  ClassifyOptions
-	Cache    ClassifierCache
-	LastIO   map[int]IOSample
-	Logger   *JSONLogger
-	Collect  func() (*Snapshot, error)
-	Classify ClassifyFunc
+	mu sync.Mutex
+
+	Options   ClassifyOptions
+	LastIO    map[int]IOSample
+	Logger    *JSONLogger
+	Observers []Observer
+	Source    Source
+	Classify  ClassifyFunc
+}
+
+// SetOptions replaces the classify options used from the next refresh on.
+func (s *ScannerAdapter) SetOptions(opts ClassifyOptions) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Options = opts
 }
 
 func (s *ScannerAdapter) Refresh(app *AppState) {
-	if s.Collect == nil || s.Classify == nil {
+	if s.Source == nil || s.Classify == nil {
 		app.LastError = "scanner not configured"
-		app.Candidates = nil
-		app.SelectedIdx = -1
-		app.SelectedPID = 0
+		app.SetCandidates(nil)
 		app.LastUpdate = time.Now().UTC()
 		return
 	}
 
-	snap, err := s.Collect()
+	snap, err := s.Source.Collect(context.Background())
+	if errors.Is(err, ErrSourceExhausted) {
+		// keep the last results on screen once a finite source runs dry
+		app.LastError = s.Source.Name() + ": no more snapshots"
+		app.SourceDone = true
+		return
+	}
 	if err != nil {
 		app.LastError = err.Error()
-		app.Candidates = nil
-		app.SelectedIdx = -1
-		app.SelectedPID = 0
+		app.SetCandidates(nil)
 		app.LastUpdate = time.Now().UTC()
 		return
 	}
 
-	cands := s.Classify(snap, s.Options, &s.Cache)
 	now := time.Now().UTC()
-	applyIORates(cands, now, &s.LastIO)
+	sampledAt := snap.Timestamp
+	if sampledAt.IsZero() {
+		sampledAt = now
+	}
+	s.mu.Lock()
+	opts := s.Options
+	s.mu.Unlock()
+
+	cands := s.Classify(snap, opts)
+	applyIORates(cands, sampledAt, &s.LastIO)
 
 	app.LastError = ""
 	if s.Logger != nil {
@@ -81,10 +150,34 @@ func (s *ScannerAdapter) Refresh(app *AppState) {
 			app.LastError = "log write failed: " + err.Error()
 		}
 	}
+	for _, o := range s.Observers {
+		if err := o.Observe(snap, cands); err != nil {
+			app.LastError = err.Error()
+		}
+	}
 
-	app.Candidates = cands
+	app.SetCandidates(cands)
 	app.LastUpdate = now
 	// app.LastError already set above
+}
+
+// SetCandidates installs the candidates of a tick, hiding suppressed ones
+// unless ShowSuppressed is set, and keeps the selection on the same PID where
+// it is still listed. Calling it again with Classified re-applies the filter.
+func (app *AppState) SetCandidates(cands []Candidate) {
+	app.Classified = cands
+	app.SuppressedCount = 0
+	visible := cands[:0:0]
+	for _, c := range cands {
+		if c.Suppressed != nil {
+			app.SuppressedCount++
+			if !app.ShowSuppressed {
+				continue
+			}
+		}
+		visible = append(visible, c)
+	}
+	app.Candidates = visible
 
 	// maintain selection across refreshes
 	if len(app.Candidates) == 0 {
@@ -106,6 +199,49 @@ func (s *ScannerAdapter) Refresh(app *AppState) {
 	app.SelectedPID = app.Candidates[0].Proc.Pid
 }
 
+// ApplyConnRates derives SendBps and RecvBps from the byte counters of
+// connections also present in prev, and replaces prev with this sample.
+// Connections without counters keep whatever rates they carry.
+func ApplyConnRates(conns []ConnectionInfo, now time.Time, prev *map[ConnKey]ConnSample) {
+	if *prev == nil {
+		*prev = make(map[ConnKey]ConnSample, len(conns))
+	}
+
+	next := make(map[ConnKey]ConnSample, len(conns))
+	for i := range conns {
+		cn := &conns[i]
+		if cn.BytesSent == 0 && cn.BytesReceived == 0 {
+			continue
+		}
+
+		key := ConnKey{
+			Pid:        cn.Pid,
+			LocalAddr:  cn.LocalAddress,
+			LocalPort:  cn.LocalPort,
+			RemoteAddr: cn.RemoteAddress,
+			RemotePort: cn.RemotePort,
+		}
+
+		if p, ok := (*prev)[key]; ok && now.After(p.Timestamp) {
+			dt := now.Sub(p.Timestamp).Seconds()
+			if cn.BytesSent >= p.Sent {
+				cn.SendBps = uint64(float64(cn.BytesSent-p.Sent) / dt)
+			}
+			if cn.BytesReceived >= p.Received {
+				cn.RecvBps = uint64(float64(cn.BytesReceived-p.Received) / dt)
+			}
+		}
+
+		next[key] = ConnSample{
+			Sent:      cn.BytesSent,
+			Received:  cn.BytesReceived,
+			Timestamp: now,
+		}
+	}
+
+	*prev = next
+}
+
 func applyIORates(cands []Candidate, now time.Time, prev *map[int]IOSample) {
 	if *prev == nil {
 		*prev = make(map[int]IOSample, len(cands))
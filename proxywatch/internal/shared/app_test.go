@@ -0,0 +1,58 @@
+package shared
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// countingSource hands out n empty snapshots.
+type countingSource struct {
+	n, collected int
+}
+
+func (s *countingSource) Name() string                     { return "counting" }
+func (s *countingSource) Capabilities() SourceCapabilities { return SourceCapabilities{} }
+
+func (s *countingSource) Collect(context.Context) (*Snapshot, error) {
+	if s.collected >= s.n {
+		return nil, ErrSourceExhausted
+	}
+	s.collected++
+	return &Snapshot{Timestamp: time.Unix(int64(s.collected), 0)}, nil
+}
+
+func TestShowSuppressedDoesNotCollect(t *testing.T) {
+	src := &countingSource{n: 1}
+	sc := &ScannerAdapter{
+		Source: src,
+		Classify: func(*Snapshot, ClassifyOptions) []Candidate {
+			return []Candidate{
+				{Proc: &ProcessInfo{Pid: 1}, Suppressed: &Suppression{}},
+				{Proc: &ProcessInfo{Pid: 2}},
+			}
+		},
+	}
+	app := &AppState{}
+	sc.Refresh(app)
+	if len(app.Candidates) != 1 || app.SuppressedCount != 1 || app.SelectedPID != 2 {
+		t.Fatalf("after refresh: %d shown, %d suppressed, pid %d selected", len(app.Candidates), app.SuppressedCount, app.SelectedPID)
+	}
+
+	app.ShowSuppressed = true
+	app.SetCandidates(app.Classified)
+	if len(app.Candidates) != 2 || app.SuppressedCount != 1 {
+		t.Errorf("showing suppressed: %d shown, %d suppressed", len(app.Candidates), app.SuppressedCount)
+	}
+	if app.SelectedPID != 2 || app.SelectedIdx != 1 {
+		t.Errorf("selection moved to pid %d at %d", app.SelectedPID, app.SelectedIdx)
+	}
+	if src.collected != 1 {
+		t.Errorf("toggling collected %d snapshots, want 1", src.collected)
+	}
+
+	sc.Refresh(app)
+	if !app.SourceDone || len(app.Candidates) != 2 {
+		t.Errorf("exhausted source: done %v, %d shown", app.SourceDone, len(app.Candidates))
+	}
+}
@@ -1,14 +1,84 @@
 package shared
 
+// FlowPair links a session arriving at a relay with the outbound connection it
+// most likely feeds: client -> proxy -> target.
+type FlowPair struct {
+	ClientAddr string
+	ClientPort int
+	ProxyAddr  string
+	ProxyPort  int
+	TargetAddr string
+	TargetPort int
+	GapSeconds float64 // target first seen minus client first seen
+	Via        string  // "listener" or "control-channel"
+}
+
+// ChainHop is one process in a loopback chain. Port is the loopback port the
+// previous hop connected to; it is 0 for the first hop.
+type ChainHop struct {
+	Pid  int
+	Name string
+	Port int
+}
+
+// ProcessChain is a host-wide path of processes linked over loopback, e.g.
+// browser -> local SOCKS client -> tunnel agent, plus where the last hop
+// leaves the host.
+type ProcessChain struct {
+	ID       string
+	Hops     []ChainHop
+	Egress   []string
+	Position int // index of the owning candidate in Hops
+}
+
+// BeaconInfo describes a destination a process reconnects to at near-regular
+// intervals. Count is the number of intervals measured; JitterSeconds is
+// their standard deviation.
+type BeaconInfo struct {
+	RemoteAddr    string
+	RemotePort    int
+	Count         int
+	MeanSeconds   float64
+	JitterSeconds float64
+}
+
+// ScanInfo summarises half-open connection attempts within the scan window.
+// SweepPort/SweepHosts describe the port tried on the most internal hosts,
+// ScanHost/ScanPorts the host tried on the most ports; each pair is zero
+// unless it crossed its threshold.
+type ScanInfo struct {
+	Attempts   int
+	Hosts      int
+	Ports      int
+	SweepPort  int
+	SweepHosts int
+	ScanHost   string
+	ScanPorts  int
+	Summary    string
+}
+
+// ScoreContribution is one scoring rule's effect on a candidate. Feature and
+// Value are the input the rule keyed on ("score" and the running score for
+// clamps). The Deltas of a candidate's contributions sum to its Score.
+type ScoreContribution struct {
+	Feature string
+	Value   any
+	Delta   int
+	Rule    string
+}
+
 type Candidate struct {
 	Proc         *ProcessInfo
 	Listeners    []ListenerInfo
 	Conns        []ConnectionInfo
 	UDPListeners []UDPListenerInfo
+	UDPConns     []UDPConnInfo
 
 	// classifier-owned fields
 	Score          int
 	Confidence     int
+	Contributions  []ScoreContribution
+	Features       map[string]any // raw feature values the rules saw
 	Reasons        []string
 	Signals        []string
 	Role           string
@@ -25,4 +95,29 @@ type Candidate struct {
 	OutShortLived int
 
 	InboundTotal int
+
+	Flows []FlowPair
+	Chain *ProcessChain
+
+	BusiestConn *ConnectionInfo // connection moving the most bytes, when counters exist
+	TransferBps uint64
+
+	UDPInbound     int
+	UDPOutbound    int
+	UDPPeer        *UDPConnInfo
+	UDPPeerSeconds int
+	UDPDNSOffPort  bool
+	UDPRelay       bool
+
+	Beacon *BeaconInfo
+	Scan   *ScanInfo
+
+	Timeline []Transition // copy of the process' timeline, oldest first
+
+	// threat intel hits; candidates with hits are always reported
+	IOC []IOCHit
+
+	// set when an allowlist rule matched; suppressed candidates are still
+	// returned and logged, the UI hides them by default
+	Suppressed *Suppression
 }
@@ -16,43 +16,100 @@ type ProcHistory struct {
 	LastSuspicious time.Time
 	SuspicionKind  int
 	StickyScore    int
+
+	// SuspicionHalfOpen is set when the last proxy suspicion rested only on
+	// SYN_SENT attempts, which may turn out to be a scan.
+	SuspicionHalfOpen bool
+
+	// Timeline holds role, score and active-state changes, oldest first and
+	// at most TimelineMax entries.
+	Timeline []Transition
 }
 
+// Transition is the state a process changed to at At.
+type Transition struct {
+	At     time.Time
+	Role   string
+	Score  int
+	Active bool
+}
+
+const TimelineMax = 64
+
 const (
 	SuspicionNone = iota
 	SuspicionControl
 	SuspicionProxy
 )
 
-var (
-	ConnFirstSeen             = make(map[ConnKey]time.Time)
-	ReverseControlMinDuration = 10 * time.Second
-	LongLivedOutboundMinAge   = 60 * time.Second
-	ShortLivedOutboundMaxAge  = 10 * time.Second
-	RecentClientSeen          = make(map[int]time.Time)
-	RecentOutboundSeen        = make(map[int]time.Time)
-	ActiveWindow              = 10 * time.Second
-	ActiveHoldWindow          = 30 * time.Second
-	SuspicionWindow           = 5 * time.Minute
-	HistoryTTL                = 5 * time.Minute
-	CleanupInterval           = 30 * time.Second
-	ReverseStickyScore        = 90
-	ForwardStickyScore        = 70
-	ReverseControlBaseScore   = 40
-	MinInternalTargetsForRev  = 2
-	MinInternalPortsForRev    = 2
-	OutboundOnlyExternalCap   = 30
-	ProcHistoryByPID          = make(map[int]*ProcHistory)
-	LastHistoryCleanup        time.Time
-	BenignControlPorts        = map[int]bool{
-		53:   true,
-		80:   true,
-		443:  true,
-		8080: true,
-		8443: true,
-		8000: true,
-		8001: true,
-		8008: true,
-		8888: true,
+// Thresholds holds the classifier tunables. Each classifier engine owns its
+// own copy, so they can be changed at runtime without affecting other engines.
+type Thresholds struct {
+	ReverseControlMinDuration time.Duration
+	LongLivedOutboundMinAge   time.Duration
+	ShortLivedOutboundMaxAge  time.Duration
+	ActiveWindow              time.Duration
+	ActiveHoldWindow          time.Duration
+	SuspicionWindow           time.Duration
+	HistoryTTL                time.Duration
+	CleanupInterval           time.Duration
+	ReverseStickyScore        int
+	ForwardStickyScore        int
+	ReverseControlBaseScore   int
+	MinInternalTargetsForRev  int
+	MinInternalPortsForRev    int
+	OutboundOnlyExternalCap   int
+	UDPLongLivedPeerMinAge    time.Duration
+	UDPHighPortMin            int
+	ActiveTransferBps         uint64
+	FlowPairWindow            time.Duration
+	BeaconMinIntervals        int
+	BeaconMinPeriod           time.Duration
+	BeaconMaxPeriod           time.Duration
+	BeaconMaxJitter           float64 // standard deviation / mean interval
+	ScanWindow                time.Duration
+	ScanSweepMinHosts         int
+	ScanMinPorts              int
+	BenignControlPorts        map[int]bool
+}
+
+func DefaultThresholds() Thresholds {
+	return Thresholds{
+		ReverseControlMinDuration: 10 * time.Second,
+		LongLivedOutboundMinAge:   60 * time.Second,
+		ShortLivedOutboundMaxAge:  10 * time.Second,
+		ActiveWindow:              10 * time.Second,
+		ActiveHoldWindow:          30 * time.Second,
+		SuspicionWindow:           5 * time.Minute,
+		HistoryTTL:                5 * time.Minute,
+		CleanupInterval:           30 * time.Second,
+		ReverseStickyScore:        90,
+		ForwardStickyScore:        70,
+		ReverseControlBaseScore:   40,
+		MinInternalTargetsForRev:  2,
+		MinInternalPortsForRev:    2,
+		OutboundOnlyExternalCap:   30,
+		UDPLongLivedPeerMinAge:    60 * time.Second,
+		UDPHighPortMin:            1024,
+		ActiveTransferBps:         1024,
+		FlowPairWindow:            5 * time.Second,
+		BeaconMinIntervals:        4,
+		BeaconMinPeriod:           5 * time.Second,
+		BeaconMaxPeriod:           30 * time.Minute,
+		BeaconMaxJitter:           0.2,
+		ScanWindow:                60 * time.Second,
+		ScanSweepMinHosts:         10,
+		ScanMinPorts:              15,
+		BenignControlPorts: map[int]bool{
+			53:   true,
+			80:   true,
+			443:  true,
+			8080: true,
+			8443: true,
+			8000: true,
+			8001: true,
+			8008: true,
+			8888: true,
+		},
 	}
-)
+}
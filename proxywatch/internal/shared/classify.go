@@ -4,6 +4,10 @@ type ClassifyOptions struct {
 	MinScore    int
 	RoleFilter  map[string]bool
 	Incremental bool
+
+	// Clock overrides the classification time. When nil the snapshot
+	// timestamp is used, so replays reproduce the durations of the live run.
+	Clock Clock
 }
 
 type CandidateSignature struct {
@@ -17,4 +21,4 @@ type ClassifierCache struct {
 	Signatures map[int]CandidateSignature
 }
 
-type ClassifyFunc func(*Snapshot, ClassifyOptions, *ClassifierCache) []Candidate
+type ClassifyFunc func(*Snapshot, ClassifyOptions) []Candidate
@@ -0,0 +1,17 @@
+package shared
+
+import "time"
+
+// Clock supplies the classifier's notion of "now".
+type Clock interface {
+	Now() time.Time
+}
+
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time { return time.Now() }
+
+// ClockFunc adapts a plain function, e.g. a test's fake time source.
+type ClockFunc func() time.Time
+
+func (f ClockFunc) Now() time.Time { return f() }
@@ -1,5 +1,7 @@
 package shared
 
+// InternalCIDRs and LateralPorts are the built-in network zones; a config
+// file can replace them per engine via Engine.SetNetworkZones.
 var InternalCIDRs = []string{
 	"10.0.0.0/8",
 	"172.16.0.0/12",
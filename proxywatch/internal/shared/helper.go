@@ -1,23 +1,10 @@
 package shared
 
-import "net"
-
-func IsInternalIP(ip string) bool {
-	netIP := net.ParseIP(ip)
-	if netIP == nil {
-		return false
-	}
-	for _, cidr := range InternalCIDRs {
-		_, network, err := net.ParseCIDR(cidr)
-		if err != nil {
-			continue
-		}
-		if network.Contains(netIP) {
-			return true
-		}
-	}
-	return false
-}
+import (
+	"net"
+	"path"
+	"strings"
+)
 
 func IsLoopbackIP(ip string) bool {
 	parsed := net.ParseIP(ip)
@@ -31,33 +18,6 @@ func IsWildcardIP(ip string) bool {
 	return ip == "0.0.0.0" || ip == "::"
 }
 
-func UDPScopeCounts(list []UDPListenerInfo) (internal, external, loopback int) {
-	for _, u := range list {
-		switch {
-		case IsLoopbackIP(u.LocalAddress):
-			loopback++
-		case IsInternalIP(u.LocalAddress):
-			internal++
-		default:
-			external++
-		}
-	}
-	return
-}
-
-func ScopeLabelForLocalAddress(addr string) string {
-	switch {
-	case IsWildcardIP(addr):
-		return "any"
-	case IsLoopbackIP(addr):
-		return "loopback"
-	case IsInternalIP(addr):
-		return "internal"
-	default:
-		return "external"
-	}
-}
-
 func TrimName(name string, max int) string {
 	if len(name) <= max {
 		return name
@@ -67,3 +27,24 @@ func TrimName(name string, max int) string {
 	}
 	return name[:max-3] + "..."
 }
+
+// MatchGlob matches value against a case-insensitive glob. An empty pattern
+// matches anything. Backslashes are folded to slashes so Windows paths can be
+// written naturally.
+func MatchGlob(pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, _ := path.Match(foldGlob(pattern), foldGlob(value))
+	return ok
+}
+
+// ValidGlob reports whether pattern is well-formed for MatchGlob.
+func ValidGlob(pattern string) bool {
+	_, err := path.Match(foldGlob(pattern), "")
+	return err == nil
+}
+
+func foldGlob(s string) string {
+	return strings.ToLower(strings.ReplaceAll(s, `\`, "/"))
+}
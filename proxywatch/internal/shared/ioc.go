@@ -0,0 +1,16 @@
+package shared
+
+// IOCHit is one indicator-of-compromise list entry observed on a candidate.
+type IOCHit struct {
+	List      string // list name (file name without extension)
+	Indicator string // entry as written in the list
+	Kind      string // "ip", "cidr", "sha256" or "name"
+	Where     string // "remote", "listener", "udp-remote", "udp-listener" or "process"
+	Observed  string // the address, hash or name that matched
+}
+
+// IOCMatcher checks a candidate against threat intel lists. Candidates with
+// hits are reported regardless of their score.
+type IOCMatcher interface {
+	Match(c *Candidate) []IOCHit
+}
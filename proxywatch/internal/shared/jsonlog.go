@@ -2,6 +2,8 @@ package shared
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"sync"
@@ -14,15 +16,98 @@ type LogSnapshot struct {
 	Candidates []Candidate `json:"candidates"`
 }
 
+// Log formats accepted by OpenJSONLogger.
+const (
+	LogFormatJSON   = "json"
+	LogFormatNDJSON = "ndjson"
+	LogFormatDelta  = "delta"
+)
+
 type JSONLogger struct {
 	mu      sync.Mutex
 	w       io.Writer
 	closeFn func() error
+	rot     *rotatingFile
+	delta   *deltaState
 	pretty  bool
+	ndjson  bool
 	started bool
 	first   bool
 }
 
+// OpenJSONLogger opens a logger for path in the given format: a pretty JSON
+// array ("json", closed on Close), one compact LogSnapshot per line
+// ("ndjson"), which stays valid however the process ends, or NDJSON holding
+// periodic keyframes and only the changes in between ("delta"). With rotation
+// enabled every rotated file is complete on its own.
+func OpenJSONLogger(path, format string, rot LogRotation) (*JSONLogger, error) {
+	if format == "" {
+		format = LogFormatJSON
+	}
+	if format != LogFormatJSON && format != LogFormatNDJSON && format != LogFormatDelta {
+		return nil, fmt.Errorf("unknown log format %q (want %s, %s or %s)", format, LogFormatJSON, LogFormatNDJSON, LogFormatDelta)
+	}
+	ndjson := format != LogFormatJSON
+
+	var (
+		l   *JSONLogger
+		err error
+	)
+	switch {
+	case path == "" || !rot.Enabled():
+		if ndjson {
+			l, err = NewNDJSONLogger(path)
+		} else {
+			l, err = NewJSONLogger(path, true)
+		}
+		if l == nil || err != nil {
+			return l, err
+		}
+	case path == "-":
+		return nil, errors.New("log rotation needs a file, not stdout")
+	default:
+		rf, err := openRotatingFile(path, rot, !ndjson)
+		if err != nil {
+			return nil, err
+		}
+		l = &JSONLogger{
+			w:       rf,
+			closeFn: rf.Close,
+			rot:     rf,
+			pretty:  !ndjson,
+			ndjson:  ndjson,
+			first:   true,
+		}
+	}
+
+	if format == LogFormatDelta {
+		l.delta = &deltaState{every: DefaultKeyframeInterval}
+	}
+	return l, nil
+}
+
+// SetKeyframeInterval sets how often a delta log writes a full snapshot; 0
+// writes one only at the start of each file.
+func (l *JSONLogger) SetKeyframeInterval(d time.Duration) {
+	if l == nil || l.delta == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.delta.every = d
+}
+
+// NewNDJSONLogger writes one self-contained LogSnapshot per line. Each line
+// goes out in a single unbuffered write, so readers following the file never
+// see a partial entry other than one cut by a crash mid-write.
+func NewNDJSONLogger(path string) (*JSONLogger, error) {
+	l, err := NewJSONLogger(path, false)
+	if l != nil {
+		l.ndjson = true
+	}
+	return l, err
+}
+
 func NewJSONLogger(path string, pretty bool) (*JSONLogger, error) {
 	if path == "" {
 		return nil, nil
@@ -53,8 +138,13 @@ func (l *JSONLogger) WriteSnapshot(snap *Snapshot, candidates []Candidate) error
 		return nil
 	}
 
+	capturedAt := time.Now().UTC()
+	if snap != nil && !snap.Timestamp.IsZero() {
+		capturedAt = snap.Timestamp.UTC()
+	}
+
 	entry := LogSnapshot{
-		CapturedAt: time.Now().UTC(),
+		CapturedAt: capturedAt,
 		Snapshot:   snap,
 		Candidates: candidates,
 	}
@@ -62,6 +152,45 @@ func (l *JSONLogger) WriteSnapshot(snap *Snapshot, candidates []Candidate) error
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	if l.rot != nil && l.rot.due(time.Now()) {
+		if err := l.endArray(); err != nil {
+			return err
+		}
+		if err := l.rot.rotate(); err != nil {
+			return err
+		}
+		l.first = true
+		if l.delta != nil {
+			l.delta.reset()
+		}
+	}
+
+	if err := l.writeEntry(entry); err != nil {
+		return err
+	}
+	if l.rot != nil {
+		l.rot.noteEntry(capturedAt)
+	}
+	return nil
+}
+
+func (l *JSONLogger) writeEntry(entry LogSnapshot) error {
+	if l.ndjson {
+		var line any = entry
+		if l.delta != nil {
+			var err error
+			if line, err = l.delta.next(entry); err != nil {
+				return err
+			}
+		}
+		out, err := json.Marshal(line)
+		if err != nil {
+			return err
+		}
+		_, err = l.w.Write(append(out, '\n'))
+		return err
+	}
+
 	if !l.started {
 		if _, err := io.WriteString(l.w, "[\n"); err != nil {
 			return err
@@ -99,6 +228,18 @@ func (l *JSONLogger) WriteSnapshot(snap *Snapshot, candidates []Candidate) error
 	return nil
 }
 
+// endArray closes an open JSON array.
+func (l *JSONLogger) endArray() error {
+	if !l.started {
+		return nil
+	}
+	if _, err := io.WriteString(l.w, "]\n"); err != nil {
+		return err
+	}
+	l.started = false
+	return nil
+}
+
 func (l *JSONLogger) Close() error {
 	if l == nil || l.w == nil {
 		return nil
@@ -107,11 +248,8 @@ func (l *JSONLogger) Close() error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	if l.started {
-		if _, err := io.WriteString(l.w, "]\n"); err != nil {
-			return err
-		}
-		l.started = false
+	if err := l.endArray(); err != nil {
+		return err
 	}
 
 	if l.closeFn != nil {
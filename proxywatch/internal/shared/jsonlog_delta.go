@@ -0,0 +1,271 @@
+package shared
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+// DefaultKeyframeInterval is how often a delta log repeats a full snapshot.
+const DefaultKeyframeInterval = 5 * time.Minute
+
+// LogDelta is what changed since the previous entry of a delta log. Processes
+// holds new and changed processes; sockets are added and removed by value, so
+// a connection whose counters moved appears in both lists. Candidates holds
+// the fields that changed on existing candidates and every field of new ones,
+// and CandidateOrder lists the PIDs of every candidate of the entry in output
+// order.
+type LogDelta struct {
+	Timestamp time.Time `json:"timestamp"`
+
+	Processes []ProcessInfo `json:"processes,omitempty"`
+	Exited    []int         `json:"exited,omitempty"`
+
+	ListenersAdded      []ListenerInfo    `json:"listeners_added,omitempty"`
+	ListenersRemoved    []ListenerInfo    `json:"listeners_removed,omitempty"`
+	ConnectionsAdded    []ConnectionInfo  `json:"connections_added,omitempty"`
+	ConnectionsRemoved  []ConnectionInfo  `json:"connections_removed,omitempty"`
+	UDPListenersAdded   []UDPListenerInfo `json:"udp_listeners_added,omitempty"`
+	UDPListenersRemoved []UDPListenerInfo `json:"udp_listeners_removed,omitempty"`
+	UDPConnsAdded       []UDPConnInfo     `json:"udp_conns_added,omitempty"`
+	UDPConnsRemoved     []UDPConnInfo     `json:"udp_conns_removed,omitempty"`
+
+	Candidates     []CandidatePatch `json:"candidates,omitempty"`
+	CandidateOrder []int            `json:"candidate_order"`
+}
+
+// CandidatePatch holds the top-level Candidate fields (by JSON name) that
+// changed for one PID.
+type CandidatePatch struct {
+	Pid    int                        `json:"pid"`
+	Fields map[string]json.RawMessage `json:"fields"`
+}
+
+// logDeltaLine is a non-keyframe line of a delta log. Keyframes are plain
+// LogSnapshot lines, so a delta log reads as NDJSON with some lines
+// carrying "delta" instead of "snapshot".
+type logDeltaLine struct {
+	CapturedAt time.Time `json:"captured_at"`
+	Delta      *LogDelta `json:"delta"`
+}
+
+// deltaState is the writer side of a delta log: the last entry written, as
+// the reader will have rebuilt it.
+type deltaState struct {
+	every   time.Duration
+	lastKey time.Time
+	snap    *Snapshot
+	cands   map[int]map[string]json.RawMessage
+}
+
+// reset forces the next entry to be a keyframe.
+func (d *deltaState) reset() {
+	d.snap = nil
+	d.cands = nil
+}
+
+// next returns the line to write for entry: the entry itself when a keyframe
+// is due, otherwise its delta against the previous one.
+func (d *deltaState) next(entry LogSnapshot) (any, error) {
+	cands := make(map[int]map[string]json.RawMessage, len(entry.Candidates))
+	order := make([]int, 0, len(entry.Candidates))
+	for _, c := range entry.Candidates {
+		fields, err := candidateFields(c)
+		if err != nil {
+			return nil, err
+		}
+		pid := candidatePid(c)
+		cands[pid] = fields
+		order = append(order, pid)
+	}
+
+	at := entry.CapturedAt
+	keyframe := d.snap == nil || entry.Snapshot == nil ||
+		at.Before(d.lastKey) || (d.every > 0 && at.Sub(d.lastKey) >= d.every)
+
+	var line any = entry
+	if keyframe {
+		d.lastKey = at
+	} else {
+		delta := diffSnapshots(d.snap, entry.Snapshot)
+		delta.CandidateOrder = order
+		for _, pid := range order {
+			patch := CandidatePatch{Pid: pid, Fields: make(map[string]json.RawMessage)}
+			prev := d.cands[pid]
+			for name, raw := range cands[pid] {
+				if old, ok := prev[name]; !ok || !bytes.Equal(old, raw) {
+					patch.Fields[name] = raw
+				}
+			}
+			if len(patch.Fields) > 0 {
+				delta.Candidates = append(delta.Candidates, patch)
+			}
+		}
+		line = logDeltaLine{CapturedAt: at, Delta: delta}
+	}
+
+	d.snap = nil
+	if entry.Snapshot != nil {
+		d.snap = copySnapshot(entry.Snapshot)
+	}
+	d.cands = cands
+	return line, nil
+}
+
+func candidateFields(c Candidate) (map[string]json.RawMessage, error) {
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(raw, &fields)
+	return fields, err
+}
+
+func candidatePid(c Candidate) int {
+	if c.Proc == nil {
+		return 0
+	}
+	return c.Proc.Pid
+}
+
+func diffSnapshots(prev, cur *Snapshot) *LogDelta {
+	d := &LogDelta{Timestamp: cur.Timestamp}
+
+	for pid, p := range cur.Processes {
+		if p == nil {
+			continue
+		}
+		if old, ok := prev.Processes[pid]; !ok || *old != *p {
+			d.Processes = append(d.Processes, *p)
+		}
+	}
+	for pid := range prev.Processes {
+		if cur.Processes[pid] == nil {
+			d.Exited = append(d.Exited, pid)
+		}
+	}
+	slices.SortFunc(d.Processes, func(a, b ProcessInfo) int { return a.Pid - b.Pid })
+	slices.Sort(d.Exited)
+
+	d.ListenersAdded, d.ListenersRemoved = diffSlice(prev.Listeners, cur.Listeners)
+	d.ConnectionsAdded, d.ConnectionsRemoved = diffSlice(prev.Connections, cur.Connections)
+	d.UDPListenersAdded, d.UDPListenersRemoved = diffSlice(prev.UDPListeners, cur.UDPListeners)
+	d.UDPConnsAdded, d.UDPConnsRemoved = diffSlice(prev.UDPConns, cur.UDPConns)
+	return d
+}
+
+// applyDelta rebuilds the snapshot and candidates of a delta entry on top of
+// the previous entry.
+func applyDelta(prev LogSnapshot, d *LogDelta) (LogSnapshot, error) {
+	snap := copySnapshot(prev.Snapshot)
+	snap.Timestamp = d.Timestamp
+	for _, pid := range d.Exited {
+		delete(snap.Processes, pid)
+	}
+	for i := range d.Processes {
+		p := d.Processes[i]
+		snap.Processes[p.Pid] = &p
+	}
+	snap.Listeners = applySlice(snap.Listeners, d.ListenersAdded, d.ListenersRemoved)
+	snap.Connections = applySlice(snap.Connections, d.ConnectionsAdded, d.ConnectionsRemoved)
+	snap.UDPListeners = applySlice(snap.UDPListeners, d.UDPListenersAdded, d.UDPListenersRemoved)
+	snap.UDPConns = applySlice(snap.UDPConns, d.UDPConnsAdded, d.UDPConnsRemoved)
+
+	byPid := make(map[int]Candidate, len(prev.Candidates))
+	for _, c := range prev.Candidates {
+		byPid[candidatePid(c)] = c
+	}
+	for _, p := range d.Candidates {
+		fields := make(map[string]json.RawMessage)
+		if c, ok := byPid[p.Pid]; ok {
+			var err error
+			if fields, err = candidateFields(c); err != nil {
+				return LogSnapshot{}, err
+			}
+		}
+		for name, raw := range p.Fields {
+			fields[name] = raw
+		}
+		raw, err := json.Marshal(fields)
+		if err != nil {
+			return LogSnapshot{}, err
+		}
+		var c Candidate
+		if err := json.Unmarshal(raw, &c); err != nil {
+			return LogSnapshot{}, err
+		}
+		byPid[p.Pid] = c
+	}
+	var cands []Candidate
+	for _, pid := range d.CandidateOrder {
+		if c, ok := byPid[pid]; ok {
+			cands = append(cands, c)
+		}
+	}
+
+	return LogSnapshot{Snapshot: snap, Candidates: cands}, nil
+}
+
+func copySnapshot(s *Snapshot) *Snapshot {
+	out := &Snapshot{
+		Timestamp:    s.Timestamp,
+		Processes:    make(map[int]*ProcessInfo, len(s.Processes)),
+		Listeners:    slices.Clone(s.Listeners),
+		Connections:  slices.Clone(s.Connections),
+		UDPListeners: slices.Clone(s.UDPListeners),
+		UDPConns:     slices.Clone(s.UDPConns),
+	}
+	for pid, p := range s.Processes {
+		if p != nil {
+			cp := *p
+			out.Processes[pid] = &cp
+		}
+	}
+	return out
+}
+
+// diffSlice compares two socket lists as multisets. Items keep their order
+// within added and removed.
+func diffSlice[T comparable](prev, cur []T) (added, removed []T) {
+	count := make(map[T]int, len(prev))
+	for _, v := range prev {
+		count[v]++
+	}
+	for _, v := range cur {
+		if count[v] > 0 {
+			count[v]--
+			continue
+		}
+		added = append(added, v)
+	}
+	for _, v := range prev {
+		if count[v] > 0 {
+			count[v]--
+			removed = append(removed, v)
+		}
+	}
+	return added, removed
+}
+
+// applySlice drops removed from list, keeping the order of what is left, and
+// appends added.
+func applySlice[T comparable](list, added, removed []T) []T {
+	if len(removed) > 0 {
+		drop := make(map[T]int, len(removed))
+		for _, v := range removed {
+			drop[v]++
+		}
+		kept := list[:0]
+		for _, v := range list {
+			if drop[v] > 0 {
+				drop[v]--
+				continue
+			}
+			kept = append(kept, v)
+		}
+		list = kept
+	}
+	return append(list, added...)
+}
@@ -0,0 +1,198 @@
+package shared
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// deltaTestEntries builds a capture where processes start and exit, counters
+// move on a long-lived connection, sockets come and go and candidates appear,
+// change and disappear.
+func deltaTestEntries() []LogSnapshot {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	proc := func(pid int, name string, cpu time.Duration) *ProcessInfo {
+		return &ProcessInfo{Pid: pid, ParentPid: 1, Name: name, ExePath: "/usr/bin/" + name, CpuTime: cpu}
+	}
+
+	var out []LogSnapshot
+	for i := 0; i < 9; i++ {
+		at := start.Add(time.Duration(i) * 10 * time.Second)
+		snap := &Snapshot{
+			Timestamp: at,
+			Processes: map[int]*ProcessInfo{
+				1:   proc(1, "init", time.Second),
+				100: proc(100, "agent", time.Duration(i)*time.Second),
+			},
+			Listeners: []ListenerInfo{{Pid: 1, LocalAddress: "0.0.0.0", LocalPort: 22, State: "LISTEN"}},
+			Connections: []ConnectionInfo{{
+				Pid: 100, LocalAddress: "10.0.0.5", LocalPort: 50000,
+				RemoteAddress: "203.0.113.9", RemotePort: 443, State: "ESTABLISHED",
+				BytesSent: uint64(i) * 4096, BytesReceived: uint64(i) * 1024, SendBps: 409,
+			}},
+		}
+		// a short-lived helper with its own listener and UDP socket
+		if i >= 2 && i <= 4 {
+			snap.Processes[200] = proc(200, "helper", 0)
+			snap.Listeners = append(snap.Listeners, ListenerInfo{Pid: 200, LocalAddress: "127.0.0.1", LocalPort: 1080, State: "LISTEN"})
+			snap.UDPListeners = []UDPListenerInfo{{Pid: 200, LocalAddress: "0.0.0.0", LocalPort: 5353}}
+			snap.UDPConns = []UDPConnInfo{{Pid: 200, LocalAddress: "10.0.0.5", LocalPort: 40000, RemoteAddress: "10.0.0.53", RemotePort: 53}}
+		}
+		if i == 3 {
+			snap.Connections = append(snap.Connections, ConnectionInfo{
+				Pid: 200, LocalAddress: "127.0.0.1", LocalPort: 1080,
+				RemoteAddress: "127.0.0.1", RemotePort: 51000, State: "ESTABLISHED",
+			})
+		}
+
+		var cands []Candidate
+		if i >= 1 {
+			c := Candidate{
+				Proc:        snap.Processes[100],
+				Conns:       snap.Connections[:1],
+				Role:        "outbound-only",
+				Score:       20 + i,
+				Reasons:     []string{"outbound connection"},
+				OutTotal:    1,
+				OutExternal: 1,
+			}
+			if i >= 6 {
+				c.Role = "beacon"
+				c.Features = map[string]any{"outTotal": 1, "beacon": true}
+			}
+			cands = append(cands, c)
+		}
+		if i >= 3 && i <= 4 {
+			cands = append([]Candidate{{
+				Proc:      snap.Processes[200],
+				Listeners: snap.Listeners[1:],
+				Role:      "listener-only",
+				Score:     35,
+			}}, cands...)
+		}
+
+		out = append(out, LogSnapshot{CapturedAt: at, Snapshot: snap, Candidates: cands})
+	}
+	return out
+}
+
+// canonical renders an entry as JSON. A delta log rebuilds socket lists as
+// multisets, so they are compared in sorted order, and an empty list counts
+// as no list.
+func canonical(t *testing.T, e LogSnapshot) string {
+	t.Helper()
+	if e.Snapshot != nil {
+		s := copySnapshot(e.Snapshot)
+		s.Listeners = sortedOrNil(s.Listeners)
+		s.Connections = sortedOrNil(s.Connections)
+		s.UDPListeners = sortedOrNil(s.UDPListeners)
+		s.UDPConns = sortedOrNil(s.UDPConns)
+		e.Snapshot = s
+	}
+	if len(e.Candidates) == 0 {
+		e.Candidates = nil
+	}
+	out, err := json.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func sortedOrNil[T any](list []T) []T {
+	if len(list) == 0 {
+		return nil
+	}
+	list = slices.Clone(list)
+	slices.SortFunc(list, func(a, b T) int { return cmp.Compare(fmt.Sprint(a), fmt.Sprint(b)) })
+	return list
+}
+
+func TestDeltaLogRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.ndjson")
+	l, err := OpenJSONLogger(path, LogFormatDelta, LogRotation{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.SetKeyframeInterval(30 * time.Second)
+
+	entries := deltaTestEntries()
+	for _, e := range entries {
+		if err := l.WriteSnapshot(e.Snapshot, e.Candidates); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keyframes []int
+	for i, line := range strings.Split(strings.TrimSpace(string(raw)), "\n") {
+		if !strings.Contains(line, `"delta":`) {
+			keyframes = append(keyframes, i)
+		}
+	}
+	if fmt.Sprint(keyframes) != "[0 3 6]" {
+		t.Errorf("keyframes at lines %v, want [0 3 6]", keyframes)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	got, err := ReadLogSnapshots(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(entries) {
+		t.Fatalf("read %d entries, wrote %d", len(got), len(entries))
+	}
+	for i := range entries {
+		if g, w := canonical(t, got[i]), canonical(t, entries[i]); g != w {
+			t.Errorf("entry %d:\n got %s\nwant %s", i, g, w)
+		}
+	}
+}
+
+func TestDeltaLogSkipsLeadingDeltas(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.ndjson")
+	l, err := OpenJSONLogger(path, LogFormatDelta, LogRotation{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.SetKeyframeInterval(0)
+	entries := deltaTestEntries()
+	for _, e := range entries {
+		if err := l.WriteSnapshot(e.Snapshot, e.Candidates); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// drop the only keyframe, as a reader attaching mid-file would
+	_, rest, _ := strings.Cut(string(raw), "\n")
+	got, err := ReadLogSnapshots(strings.NewReader(rest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("rebuilt %d entries without a keyframe", len(got))
+	}
+}
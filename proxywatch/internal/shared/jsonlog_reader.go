@@ -0,0 +1,92 @@
+package shared
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ReadLogSnapshots parses a log written by JSONLogger, either a JSON array or
+// NDJSON (one entry per line). Delta logs are rebuilt into full entries;
+// deltas before the first keyframe are skipped. Captures cut short by a crash
+// lack the closing ']' or end mid-line; every complete entry before the cut is
+// still returned.
+func ReadLogSnapshots(r io.Reader) ([]LogSnapshot, error) {
+	br := bufio.NewReader(r)
+	first, err := firstNonSpace(br)
+	if err != nil {
+		return nil, fmt.Errorf("read log: %w", err)
+	}
+
+	dec := json.NewDecoder(br)
+	if first != '{' {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("read log: %w", err)
+		}
+		if d, ok := tok.(json.Delim); !ok || d != '[' {
+			return nil, fmt.Errorf("read log: expected '[' or '{', got %v", tok)
+		}
+	}
+
+	var out []LogSnapshot
+	for dec.More() {
+		var line struct {
+			LogSnapshot
+			Delta *LogDelta `json:"delta"`
+		}
+		if err := dec.Decode(&line); err != nil {
+			if cutShort(err) {
+				return out, nil
+			}
+			return out, fmt.Errorf("read log entry %d: %w", len(out), err)
+		}
+		entry := line.LogSnapshot
+		if line.Delta != nil {
+			if len(out) == 0 {
+				continue
+			}
+			if entry, err = applyDelta(out[len(out)-1], line.Delta); err != nil {
+				return out, fmt.Errorf("read log entry %d: %w", len(out), err)
+			}
+			entry.CapturedAt = line.CapturedAt
+		}
+		if entry.Snapshot == nil {
+			continue
+		}
+		if entry.Snapshot.Processes == nil {
+			entry.Snapshot.Processes = make(map[int]*ProcessInfo)
+		}
+		out = append(out, entry)
+	}
+
+	return out, nil
+}
+
+// cutShort reports whether a decode error means the input ended, between
+// entries or inside one, rather than that it is malformed. Inside an array
+// the decoder reports an end between entries as a syntax error.
+func cutShort(err error) bool {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) && syntaxErr.Error() == "unexpected end of JSON input" {
+		return true
+	}
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// firstNonSpace peeks at the first non-whitespace byte without consuming it.
+func firstNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return b, br.UnreadByte()
+	}
+}
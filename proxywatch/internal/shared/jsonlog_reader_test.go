@@ -0,0 +1,60 @@
+package shared
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// a pretty JSON capture killed at each point writeEntry can be interrupted
+func TestReadLogSnapshotsCutArray(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.json")
+	l, err := OpenJSONLogger(path, LogFormatJSON, LogRotation{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range deltaTestEntries()[:2] {
+		if err := l.WriteSnapshot(e.Snapshot, e.Candidates); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// abandoned without Close, as a killed process leaves it
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.closeFn()
+	full := string(raw)
+	second := strings.LastIndex(full, ",\n")
+
+	tests := []struct {
+		name string
+		data string
+		want int // -1: an error
+	}{
+		{"only the opening bracket", "[\n", 0},
+		{"missing the closing bracket", full, 2},
+		{"after the separator", full[:second+2], 1},
+		{"inside an entry", full[:second+40], 1},
+		{"closed", full + "]\n", 2},
+		{"malformed", full[:second+2] + "{\"captured_at\": nope}\n]\n", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadLogSnapshots(strings.NewReader(tt.data))
+			if tt.want < 0 {
+				if err == nil {
+					t.Fatal("malformed entry read without an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadLogSnapshots: %v", err)
+			}
+			if len(got) != tt.want {
+				t.Errorf("read %d entries, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
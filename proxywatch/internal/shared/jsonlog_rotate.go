@@ -0,0 +1,423 @@
+package shared
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogRotation controls rotation of a JSON snapshot log. A file is rotated
+// before the next entry once it holds MaxBytes or has been open for MaxAge;
+// either limit may be zero. Rotated files are renamed (gzipped with Compress)
+// next to the log, listed in <log>.manifest.json, and only the newest MaxFiles
+// are kept (0 keeps all).
+type LogRotation struct {
+	MaxBytes int64
+	MaxAge   time.Duration
+	MaxFiles int
+	Compress bool
+}
+
+func (r LogRotation) Enabled() bool {
+	return r.MaxBytes > 0 || r.MaxAge > 0
+}
+
+// LogManifest lists the rotated files of a log, oldest first.
+type LogManifest struct {
+	Log     string            `json:"log"`
+	Files   []LogManifestFile `json:"files"`
+	Current *LogManifestFile  `json:"current,omitempty"`
+}
+
+// LogManifestFile describes one log file. Name is relative to the manifest.
+// First and Last are the capture times of its first and last entries; files
+// left behind by a run that did not exit cleanly have no entry count and take
+// Last from their modification time, and are marked Truncated when their last
+// entry was cut short and dropped.
+type LogManifestFile struct {
+	Name       string    `json:"name"`
+	First      time.Time `json:"first"`
+	Last       time.Time `json:"last"`
+	Entries    int       `json:"entries"`
+	Bytes      int64     `json:"bytes"`
+	Compressed bool      `json:"compressed,omitempty"`
+	Truncated  bool      `json:"truncated,omitempty"`
+}
+
+// ReadLogManifest reads the manifest written next to a rotated log.
+func ReadLogManifest(logPath string) (*LogManifest, error) {
+	data, err := os.ReadFile(manifestPath(logPath))
+	if err != nil {
+		return nil, err
+	}
+	var m LogManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("log manifest %s: %w", manifestPath(logPath), err)
+	}
+	return &m, nil
+}
+
+func manifestPath(logPath string) string {
+	return logPath + ".manifest.json"
+}
+
+// rotatingFile is the writer behind a rotating JSONLogger. It only counts
+// bytes and moves files; JSONLogger decides where entries and arrays end.
+// Archives are gzipped in the background so a rotation never holds up a tick.
+type rotatingFile struct {
+	path      string
+	opts      LogRotation
+	jsonArray bool // entries form a JSON array that a crash leaves open
+	f         *os.File
+	cur       LogManifestFile
+	opened    time.Time
+
+	mu       sync.Mutex // guards manifest and gzipErr against compress
+	manifest LogManifest
+	gzipErr  error
+	gzipping sync.WaitGroup
+}
+
+func openRotatingFile(path string, opts LogRotation, jsonArray bool) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, opts: opts, jsonArray: jsonArray}
+
+	m, err := ReadLogManifest(path)
+	switch {
+	case err == nil:
+		r.manifest = *m
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, err
+	}
+	r.manifest.Log = filepath.Base(path)
+	c := r.manifest.Current
+	r.manifest.Current = nil
+
+	// archive what a previous run left behind instead of truncating it
+	if fi, err := os.Stat(path); err == nil && fi.Size() > 0 {
+		prev := LogManifestFile{Last: fi.ModTime().UTC()}
+		if c != nil && c.Bytes == fi.Size() {
+			// closed cleanly: the manifest is complete
+			prev = *c
+		} else {
+			if c != nil {
+				prev.First = c.First
+			}
+			complete, err := sealLeftover(path, r.jsonArray)
+			if err != nil {
+				return nil, err
+			}
+			prev.Truncated = !complete
+		}
+		if err := r.archive(prev); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := r.openActive(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	n, err := r.f.Write(p)
+	r.cur.Bytes += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) Close() error {
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	r.gzipping.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cur := r.cur
+	r.manifest.Current = &cur
+	if werr := r.writeManifest(); err == nil {
+		err = werr
+	}
+	if err == nil {
+		err = r.gzipErr
+	}
+	return err
+}
+
+// due reports whether the active file should be rotated before the next
+// entry. Empty files are never rotated.
+func (r *rotatingFile) due(now time.Time) bool {
+	if r.cur.Entries == 0 {
+		return false
+	}
+	if r.opts.MaxBytes > 0 && r.cur.Bytes >= r.opts.MaxBytes {
+		return true
+	}
+	return r.opts.MaxAge > 0 && now.Sub(r.opened) >= r.opts.MaxAge
+}
+
+func (r *rotatingFile) noteEntry(at time.Time) {
+	r.cur.Entries++
+	r.cur.Last = at
+	if r.cur.Entries == 1 {
+		r.cur.First = at
+		// record the start so an unclean exit still knows the file's range
+		cur := r.cur
+		r.mu.Lock()
+		r.manifest.Current = &cur
+		_ = r.writeManifest()
+		r.mu.Unlock()
+	}
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	r.f = nil
+	if err := r.archive(r.cur); err != nil {
+		return err
+	}
+	return r.openActive()
+}
+
+func (r *rotatingFile) openActive() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	r.f = f
+	r.cur = LogManifestFile{Name: filepath.Base(r.path)}
+	r.opened = time.Now()
+	return nil
+}
+
+// archive moves the active file aside, records it in the manifest and drops
+// the oldest archives beyond MaxFiles. With Compress the archive is gzipped by
+// compress, which updates its manifest entry once done.
+func (r *rotatingFile) archive(meta LogManifestFile) error {
+	dst := r.archiveName(meta)
+	if err := os.Rename(r.path, dst); err != nil {
+		return err
+	}
+	meta.Name = filepath.Base(dst)
+	if fi, err := os.Stat(dst); err == nil {
+		meta.Bytes = fi.Size()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.manifest.Files = append(r.manifest.Files, meta)
+
+	dir := filepath.Dir(r.path)
+	for r.opts.MaxFiles > 0 && len(r.manifest.Files) > r.opts.MaxFiles {
+		old := r.manifest.Files[0]
+		if err := os.Remove(filepath.Join(dir, old.Name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		r.manifest.Files = r.manifest.Files[1:]
+	}
+	if err := r.writeManifest(); err != nil {
+		return err
+	}
+
+	if r.opts.Compress {
+		r.gzipping.Add(1)
+		go r.compress(dst)
+	}
+	return nil
+}
+
+// compress gzips an archive and points its manifest entry at the result. The
+// uncompressed file stays until the manifest no longer names it, so a crash
+// at any point leaves the manifest describing a file that exists.
+func (r *rotatingFile) compress(src string) {
+	defer r.gzipping.Done()
+	dst := src + ".gz"
+	err := gzipFile(src, dst)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := slices.IndexFunc(r.manifest.Files, func(f LogManifestFile) bool {
+		return f.Name == filepath.Base(src)
+	})
+	if i < 0 {
+		// dropped by MaxFiles while compressing
+		os.Remove(dst)
+		os.Remove(src)
+		return
+	}
+	if err != nil {
+		r.gzipErr = fmt.Errorf("compress %s: %w", src, err)
+		return
+	}
+	f := &r.manifest.Files[i]
+	f.Name = filepath.Base(dst)
+	f.Compressed = true
+	if fi, err := os.Stat(dst); err == nil {
+		f.Bytes = fi.Size()
+	}
+	if err := r.writeManifest(); err != nil {
+		r.gzipErr = err
+		return
+	}
+	if err := os.Remove(src); err != nil {
+		r.gzipErr = err
+	}
+}
+
+// archiveName names a rotated file after the capture time of its first entry:
+// capture.json becomes capture-20260102T150405Z.json, gzipped later into
+// capture-20260102T150405Z.json.gz.
+func (r *rotatingFile) archiveName(meta LogManifestFile) string {
+	at := meta.First
+	if at.IsZero() {
+		at = meta.Last
+	}
+	if at.IsZero() {
+		at = time.Now()
+	}
+	ext := filepath.Ext(r.path)
+	stem := strings.TrimSuffix(r.path, ext) + "-" + at.UTC().Format("20060102T150405Z")
+
+	name := stem + ext
+	for i := 2; ; i++ {
+		if !exists(name) && !exists(name+".gz") {
+			return name
+		}
+		name = fmt.Sprintf("%s-%d%s", stem, i, ext)
+	}
+}
+
+// writeManifest must be called with r.mu held.
+func (r *rotatingFile) writeManifest() error {
+	data, err := json.MarshalIndent(r.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := manifestPath(r.path)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return !errors.Is(err, os.ErrNotExist)
+}
+
+// sealLeftover makes a file left by an unclean exit end cleanly and reports
+// whether its last entry was complete. NDJSON lines are written whole, so a
+// file ending in a newline is complete. A JSON array is cut back to its last
+// top-level entry ("  }" from MarshalIndent), or to the '[' when it has none,
+// and closed so the archive is valid JSON; it was complete when nothing but a
+// separator had to go.
+func sealLeftover(path string, jsonArray bool) (bool, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	buf := make([]byte, min(fi.Size(), 64))
+	if _, err := f.ReadAt(buf, fi.Size()-int64(len(buf))); err != nil {
+		return false, err
+	}
+	tail := string(buf)
+	if !jsonArray {
+		return strings.HasSuffix(tail, "\n"), nil
+	}
+	if strings.HasSuffix(strings.TrimRight(tail, " \t\r\n"), "\n]") {
+		return true, nil
+	}
+
+	end, err := lastEntryEnd(f, fi.Size())
+	if err != nil {
+		return false, err
+	}
+	if end < 0 {
+		// no entry made it: keep the opening bracket alone
+		head := make([]byte, 1)
+		if _, err := f.ReadAt(head, 0); err != nil || head[0] != '[' {
+			return false, err
+		}
+		end = 1
+	}
+	rest := make([]byte, fi.Size()-end)
+	if _, err := f.ReadAt(rest, end); err != nil {
+		return false, err
+	}
+	complete := strings.Trim(string(rest), " \t\r\n,") == ""
+	if err := f.Truncate(end); err != nil {
+		return false, err
+	}
+	if _, err := f.WriteAt([]byte("\n]\n"), end); err != nil {
+		return false, err
+	}
+	return complete, nil
+}
+
+// lastEntryEnd returns the offset just past the last top-level "\n  }" in the
+// first size bytes of f, or -1 when there is none. It reads backwards so a
+// long capture is not read whole; only the cut entry and what follows it are.
+func lastEntryEnd(f *os.File, size int64) (int64, error) {
+	const mark = "\n  }"
+	const chunk = 64 << 10
+	buf := make([]byte, chunk+len(mark)-1)
+	for hi := size; hi > 0; {
+		lo := max(hi-chunk, 0)
+		n := min(hi+int64(len(mark))-1, size) - lo
+		if _, err := f.ReadAt(buf[:n], lo); err != nil {
+			return -1, err
+		}
+		if i := strings.LastIndex(string(buf[:n]), mark); i >= 0 {
+			return lo + int64(i+len(mark)), nil
+		}
+		hi = lo
+	}
+	return -1, nil
+}
+
+func gzipFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	zw.Name = filepath.Base(src)
+	_, err = io.Copy(zw, in)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
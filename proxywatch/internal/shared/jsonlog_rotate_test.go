@@ -0,0 +1,179 @@
+package shared
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// crashedLog writes entries to a rotating JSON array log and abandons it the
+// way a killed process would: no closing ']' and a stale manifest.
+func crashedLog(t *testing.T, path string, entries []LogSnapshot) {
+	t.Helper()
+	l, err := OpenJSONLogger(path, LogFormatJSON, LogRotation{MaxBytes: 1 << 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if err := l.WriteSnapshot(e.Snapshot, e.Candidates); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.rot.f.Close()
+}
+
+func readArchive(t *testing.T, path string) []byte {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestRotatingLeftoverArrayIsTerminated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.json")
+	crashedLog(t, path, deltaTestEntries()[:3])
+
+	l, err := OpenJSONLogger(path, LogFormatJSON, LogRotation{MaxBytes: 1 << 30, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := ReadLogManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Files) != 1 {
+		t.Fatalf("manifest lists %d archives, want 1", len(m.Files))
+	}
+	arch := m.Files[0]
+	if !arch.Compressed || arch.Truncated {
+		t.Errorf("archive compressed=%v truncated=%v, want compressed and complete", arch.Compressed, arch.Truncated)
+	}
+	dir := filepath.Dir(path)
+	if _, err := os.Stat(filepath.Join(dir, arch.Name[:len(arch.Name)-len(".gz")])); !os.IsNotExist(err) {
+		t.Errorf("uncompressed archive still present: %v", err)
+	}
+
+	var entries []LogSnapshot
+	if err := json.Unmarshal(readArchive(t, filepath.Join(dir, arch.Name)), &entries); err != nil {
+		t.Fatalf("archive is not a JSON array: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("archive holds %d entries, want 3", len(entries))
+	}
+}
+
+func TestRotatingLeftoverCutShort(t *testing.T) {
+	tests := []struct {
+		name      string
+		cut       func(data []byte) []byte
+		entries   int
+		truncated bool
+	}{
+		// writeEntry writes the separator and the entry separately
+		{"after separator", func(data []byte) []byte { return append(data, ",\n"...) }, 3, false},
+		{"inside last entry", func(data []byte) []byte { return data[:len(data)-40] }, 2, true},
+		{"inside first entry", func(data []byte) []byte { return data[:20] }, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "capture.json")
+			crashedLog(t, path, deltaTestEntries()[:3])
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, tt.cut(data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			l, err := OpenJSONLogger(path, LogFormatJSON, LogRotation{MaxBytes: 1 << 30})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := l.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			m, err := ReadLogManifest(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(m.Files) != 1 || m.Files[0].Truncated != tt.truncated {
+				t.Fatalf("manifest files = %+v, want one archive with truncated=%v", m.Files, tt.truncated)
+			}
+			raw, err := os.ReadFile(filepath.Join(filepath.Dir(path), m.Files[0].Name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []LogSnapshot
+			if err := json.Unmarshal(raw, &got); err != nil {
+				t.Fatalf("archive is not a JSON array: %v", err)
+			}
+			if len(got) != tt.entries {
+				t.Errorf("archive holds %d entries, want %d", len(got), tt.entries)
+			}
+		})
+	}
+}
+
+func TestRotatingCompressesInBackground(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.ndjson")
+	l, err := OpenJSONLogger(path, LogFormatNDJSON, LogRotation{MaxBytes: 1, MaxFiles: 2, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := deltaTestEntries()
+	for _, e := range entries {
+		if err := l.WriteSnapshot(e.Snapshot, e.Candidates); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := ReadLogManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Files) != 2 {
+		t.Fatalf("manifest lists %d archives, want 2", len(m.Files))
+	}
+	dir := filepath.Dir(path)
+	names, err := filepath.Glob(filepath.Join(dir, "capture-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 {
+		t.Errorf("archives on disk: %v, want the 2 in the manifest", names)
+	}
+	for _, f := range m.Files {
+		if !f.Compressed {
+			t.Errorf("%s not compressed", f.Name)
+			continue
+		}
+		got, err := ReadLogSnapshots(bytes.NewReader(readArchive(t, filepath.Join(dir, f.Name))))
+		if err != nil || len(got) != 1 {
+			t.Errorf("%s: %d entries, err %v; want 1", f.Name, len(got), err)
+		}
+	}
+}
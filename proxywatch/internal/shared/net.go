@@ -14,4 +14,13 @@ type ConnectionInfo struct {
 	RemoteAddress string
 	RemotePort    int
 	State         string
+
+	// per-connection counters, populated only by collectors that can see
+	// them (sock_diag on Linux); rates are derived between snapshots
+	BytesSent     uint64
+	BytesReceived uint64
+	RTTMicros     uint32
+	Retransmits   uint32
+	SendBps       uint64
+	RecvBps       uint64
 }
@@ -0,0 +1,83 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrSourceExhausted is returned by finite sources (replays, datasets) once
+// every snapshot has been handed out.
+var ErrSourceExhausted = errors.New("source exhausted")
+
+type SourceCapabilities struct {
+	UDP           bool // UDP sockets are populated
+	UDPPeers      bool // connected UDP sockets carry their remote endpoint
+	IOCounters    bool // ProcessInfo IO counters are populated
+	PerConnection bool // individual TCP connections are attributed to PIDs
+	ConnCounters  bool // TCP connections carry byte, RTT and retransmit counters
+}
+
+// Source produces snapshots for the classifier.
+type Source interface {
+	Name() string
+	Capabilities() SourceCapabilities
+	Collect(ctx context.Context) (*Snapshot, error)
+}
+
+// BurstTuner is implemented by sources that resample the TCP table within one
+// collection; the settings apply from the next Collect.
+type BurstTuner interface {
+	SetBurstSettings(b BurstSettings)
+}
+
+// SourceFactory builds a source from the argument part of a "-source name:arg"
+// spec. arg is empty when no ':' was given.
+type SourceFactory func(arg string) (Source, error)
+
+var (
+	sourcesMu sync.RWMutex
+	sources   = make(map[string]SourceFactory)
+)
+
+func RegisterSource(name string, factory SourceFactory) {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+
+	if factory == nil {
+		panic("shared: RegisterSource factory is nil")
+	}
+	if _, dup := sources[name]; dup {
+		panic("shared: RegisterSource called twice for " + name)
+	}
+	sources[name] = factory
+}
+
+// OpenSource resolves a "name" or "name:arg" spec against the registry.
+func OpenSource(spec string) (Source, error) {
+	name, arg, _ := strings.Cut(spec, ":")
+
+	sourcesMu.RLock()
+	factory, ok := sources[name]
+	sourcesMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown source %q (available: %s)", name, strings.Join(SourceNames(), ", "))
+	}
+	return factory(arg)
+}
+
+func SourceNames() []string {
+	sourcesMu.RLock()
+	defer sourcesMu.RUnlock()
+
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,17 @@
+package shared
+
+import "time"
+
+// Suppression records the allowlist rule that matched a candidate.
+type Suppression struct {
+	Rule    string
+	Owner   string
+	Reason  string
+	Expires *time.Time
+}
+
+// Suppressor decides whether a classified candidate is allowlisted. now is
+// the classification time, so rule expiry behaves the same in replays.
+type Suppressor interface {
+	Match(c *Candidate, now time.Time) *Suppression
+}
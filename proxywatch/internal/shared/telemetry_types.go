@@ -8,6 +8,7 @@ type Snapshot struct {
 	Listeners    []ListenerInfo
 	Connections  []ConnectionInfo
 	UDPListeners []UDPListenerInfo
+	UDPConns     []UDPConnInfo
 }
 
 type ListenerKey struct {
@@ -27,3 +28,26 @@ const (
 
 	ProcessMetaCacheTTL = 60 * time.Second
 )
+
+// BurstSettings controls how many extra TCP table samples the live collector
+// takes per refresh to catch short-lived connections.
+type BurstSettings struct {
+	SamplesMax int
+	SamplesMid int
+	SamplesMin int
+	Sleep      time.Duration
+
+	IdleConnThreshold     int
+	ModerateConnThreshold int
+}
+
+func DefaultBurstSettings() BurstSettings {
+	return BurstSettings{
+		SamplesMax:            BurstSamplesMax,
+		SamplesMid:            BurstSamplesMid,
+		SamplesMin:            BurstSamplesMin,
+		Sleep:                 BurstSleep,
+		IdleConnThreshold:     BurstIdleConnThreshold,
+		ModerateConnThreshold: BurstModerateConnThreshold,
+	}
+}
@@ -5,3 +5,13 @@ type UDPListenerInfo struct {
 	LocalAddress string
 	LocalPort    int
 }
+
+// UDPConnInfo is a connected UDP socket, i.e. one bound to a single remote
+// peer. Only collected where the OS exposes the remote endpoint.
+type UDPConnInfo struct {
+	Pid           int
+	LocalAddress  string
+	LocalPort     int
+	RemoteAddress string
+	RemotePort    int
+}
@@ -0,0 +1,92 @@
+package shared
+
+import (
+	"fmt"
+	"net"
+	"sort"
+)
+
+// NetworkZones decides which addresses are internal and which internal ports
+// count as lateral movement. Each engine holds its own; a configuration reload
+// installs a new value rather than changing one in place.
+type NetworkZones struct {
+	InternalNets []*net.IPNet
+	LateralPorts map[int]bool
+}
+
+func NewNetworkZones(cidrs []string, lateralPorts []int) (*NetworkZones, error) {
+	z := &NetworkZones{LateralPorts: make(map[int]bool, len(lateralPorts))}
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", cidr)
+		}
+		z.InternalNets = append(z.InternalNets, network)
+	}
+	for _, p := range lateralPorts {
+		if p <= 0 || p > 65535 {
+			return nil, fmt.Errorf("invalid port %d", p)
+		}
+		z.LateralPorts[p] = true
+	}
+	return z, nil
+}
+
+// DefaultNetworkZones builds zones from InternalCIDRs and LateralPorts.
+func DefaultNetworkZones() *NetworkZones {
+	ports := make([]int, 0, len(LateralPorts))
+	for p := range LateralPorts {
+		ports = append(ports, p)
+	}
+	sort.Ints(ports)
+
+	z, err := NewNetworkZones(InternalCIDRs, ports)
+	if err != nil {
+		panic("shared: invalid default network zones: " + err.Error())
+	}
+	return z
+}
+
+func (z *NetworkZones) IsInternal(ip string) bool {
+	netIP := net.ParseIP(ip)
+	if netIP == nil {
+		return false
+	}
+	for _, network := range z.InternalNets {
+		if network.Contains(netIP) {
+			return true
+		}
+	}
+	return false
+}
+
+func (z *NetworkZones) IsLateral(port int) bool {
+	return z.LateralPorts[port]
+}
+
+func (z *NetworkZones) UDPScopeCounts(list []UDPListenerInfo) (internal, external, loopback int) {
+	for _, u := range list {
+		switch {
+		case IsLoopbackIP(u.LocalAddress):
+			loopback++
+		case z.IsInternal(u.LocalAddress):
+			internal++
+		default:
+			external++
+		}
+	}
+	return
+}
+
+func (z *NetworkZones) ScopeLabel(addr string) string {
+	switch {
+	case IsWildcardIP(addr):
+		return "any"
+	case IsLoopbackIP(addr):
+		return "loopback"
+	case z.IsInternal(addr):
+		return "internal"
+	default:
+		return "external"
+	}
+}
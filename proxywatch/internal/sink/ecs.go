@@ -0,0 +1,202 @@
+package sink
+
+import (
+	"net"
+	"time"
+
+	"proxywatch/internal/shared"
+)
+
+// Document datasets, used as event.dataset and to pick the HEC sourcetype.
+const (
+	DatasetFinding    = "proxywatch.finding"
+	DatasetConnection = "proxywatch.connection"
+)
+
+// ecsEventType maps an event kind to ECS event.type.
+var ecsEventType = map[string]string{
+	Appeared:    "start",
+	Changed:     "change",
+	Disappeared: "end",
+}
+
+// FindingDoc maps a candidate event to Elastic Common Schema. Fields without
+// an ECS home go under "proxywatch".
+func FindingDoc(e Event, hostname string) map[string]any {
+	c := &e.Candidate
+	doc := map[string]any{
+		"@timestamp": e.At.UTC().Format(time.RFC3339Nano),
+		"message":    Summary(e),
+		"event": map[string]any{
+			"kind":       "alert",
+			"category":   []string{"network", "process"},
+			"type":       []string{ecsEventType[e.Kind]},
+			"action":     e.Kind,
+			"module":     appName,
+			"dataset":    DatasetFinding,
+			"severity":   severity(e),
+			"risk_score": c.Score,
+		},
+		"rule": map[string]any{"name": c.Role},
+	}
+	if hostname != "" {
+		doc["host"] = map[string]any{"hostname": hostname}
+	}
+	if c.Proc != nil {
+		doc["process"] = ecsProcess(c.Proc)
+		if c.Proc.UserName != "" {
+			doc["user"] = map[string]any{"name": c.Proc.UserName}
+		}
+	}
+	if cc := c.ControlChannel; cc != nil {
+		doc["source"] = ecsEndpoint(cc.LocalAddress, cc.LocalPort)
+		doc["destination"] = ecsEndpoint(cc.RemoteAddress, cc.RemotePort)
+		doc["network"] = ecsNetwork("tcp", cc.RemoteAddress, "outbound")
+	}
+
+	pw := map[string]any{
+		"role":       c.Role,
+		"band":       Band(c.Score),
+		"score":      c.Score,
+		"confidence": c.Confidence,
+		"active":     c.ActiveProxying,
+		"signals":    c.Signals,
+		"reasons":    c.Reasons,
+		"targets":    Targets(c),
+	}
+	if e.PrevRole != "" {
+		pw["previous_role"] = e.PrevRole
+		pw["previous_band"] = e.PrevBand
+	}
+	if c.ControlChannel != nil {
+		pw["control_channel"] = ControlChannel(c)
+		pw["control_seconds"] = c.ControlDurationSeconds
+	}
+	doc["proxywatch"] = pw
+	return doc
+}
+
+type connKey struct {
+	udp           bool
+	pid           int
+	localAddress  string
+	localPort     int
+	remoteAddress string
+	remotePort    int
+}
+
+// ConnectionTracker reports each TCP connection and connected UDP socket once,
+// when it is first seen.
+type ConnectionTracker struct {
+	seen map[connKey]bool
+}
+
+func NewConnectionTracker() *ConnectionTracker {
+	return &ConnectionTracker{seen: make(map[connKey]bool)}
+}
+
+// Update returns ECS documents for the connections that are new in snap.
+func (t *ConnectionTracker) Update(snap *shared.Snapshot, hostname string) []map[string]any {
+	if snap == nil {
+		return nil
+	}
+	at := snap.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	listening := make(map[[2]int]bool, len(snap.Listeners))
+	for _, l := range snap.Listeners {
+		listening[[2]int{l.Pid, l.LocalPort}] = true
+	}
+
+	seen := make(map[connKey]bool, len(snap.Connections)+len(snap.UDPConns))
+	var docs []map[string]any
+	add := func(k connKey, state string) {
+		seen[k] = true
+		if t.seen[k] {
+			return
+		}
+		direction := "outbound"
+		if listening[[2]int{k.pid, k.localPort}] && !k.udp {
+			direction = "inbound"
+		}
+		if shared.IsLoopbackIP(k.remoteAddress) {
+			direction = "internal"
+		}
+		transport := "tcp"
+		if k.udp {
+			transport = "udp"
+		}
+		doc := map[string]any{
+			"@timestamp": at.UTC().Format(time.RFC3339Nano),
+			"event": map[string]any{
+				"kind":     "event",
+				"category": []string{"network"},
+				"type":     []string{"connection", "start"},
+				"module":   appName,
+				"dataset":  DatasetConnection,
+			},
+			"source":      ecsEndpoint(k.localAddress, k.localPort),
+			"destination": ecsEndpoint(k.remoteAddress, k.remotePort),
+			"network":     ecsNetwork(transport, k.remoteAddress, direction),
+		}
+		if hostname != "" {
+			doc["host"] = map[string]any{"hostname": hostname}
+		}
+		if p := snap.Processes[k.pid]; p != nil {
+			doc["process"] = ecsProcess(p)
+		} else {
+			doc["process"] = map[string]any{"pid": k.pid}
+		}
+		if state != "" {
+			doc["proxywatch"] = map[string]any{"state": state}
+		}
+		docs = append(docs, doc)
+	}
+
+	for _, c := range snap.Connections {
+		add(connKey{false, c.Pid, c.LocalAddress, c.LocalPort, c.RemoteAddress, c.RemotePort}, c.State)
+	}
+	for _, u := range snap.UDPConns {
+		add(connKey{true, u.Pid, u.LocalAddress, u.LocalPort, u.RemoteAddress, u.RemotePort}, "")
+	}
+	t.seen = seen
+	return docs
+}
+
+func ecsProcess(p *shared.ProcessInfo) map[string]any {
+	out := map[string]any{
+		"pid":  p.Pid,
+		"name": p.Name,
+	}
+	if p.ExePath != "" {
+		out["executable"] = p.ExePath
+	}
+	if p.ParentPid != 0 {
+		out["parent"] = map[string]any{"pid": p.ParentPid}
+	}
+	return out
+}
+
+func ecsEndpoint(addr string, port int) map[string]any {
+	return map[string]any{
+		"ip":   addr,
+		"port": port,
+	}
+}
+
+func ecsNetwork(transport, remote, direction string) map[string]any {
+	out := map[string]any{
+		"transport": transport,
+		"direction": direction,
+	}
+	if ip := net.ParseIP(remote); ip != nil {
+		if ip.To4() != nil {
+			out["type"] = "ipv4"
+		} else {
+			out["type"] = "ipv6"
+		}
+	}
+	return out
+}
@@ -0,0 +1,120 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// DefaultElasticIndex is a data stream name following the logs-*-* template,
+// so ECS mappings apply without setup.
+const DefaultElasticIndex = "logs-proxywatch-default"
+
+// NewElastic ships to an Elasticsearch _bulk endpoint, creating one document
+// per finding or connection in Index. Token is an API key; user:password in
+// the URL is sent as basic auth. Items the cluster rejects with 429 or 5xx
+// are retried, other rejections are dropped.
+func NewElastic(opts HTTPOptions) (*HTTPSink, error) {
+	url, user, err := endpoint(opts.URL, "/_bulk")
+	if err != nil {
+		return nil, err
+	}
+	client, err := httpClient(opts)
+	if err != nil {
+		return nil, err
+	}
+	index := opts.Index
+	if index == "" {
+		index = DefaultElasticIndex
+	}
+	action, err := json.Marshal(map[string]any{"create": map[string]any{"_index": index}})
+	if err != nil {
+		return nil, err
+	}
+
+	deliver := func(ctx context.Context, docs []doc) ([]doc, error) {
+		var body bytes.Buffer
+		for _, d := range docs {
+			body.Write(action)
+			body.WriteByte('\n')
+			body.Write(d.Body)
+			body.WriteByte('\n')
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &body)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		if opts.Token != "" {
+			req.Header.Set("Authorization", "ApiKey "+opts.Token)
+		} else if user != nil {
+			pass, _ := user.Password()
+			req.SetBasicAuth(user.Username(), pass)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return docs, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= 300 {
+			if retryable(resp.StatusCode) {
+				return docs, statusError(resp)
+			}
+			return nil, statusError(resp)
+		}
+		return bulkRetries(resp, docs)
+	}
+
+	return newHTTPSink("elastic", opts, deliver)
+}
+
+// bulkRetries reads a _bulk response and picks out the documents to resend.
+func bulkRetries(resp *http.Response, docs []doc) ([]doc, error) {
+	var result struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int `json:"status"`
+			Error  *struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		// a truncated body or a proxy's error page says nothing about
+		// which items were taken, so send them all again
+		return docs, fmt.Errorf("bulk response: %w", err)
+	}
+	if !result.Errors {
+		return nil, nil
+	}
+
+	var (
+		retry    []doc
+		rejected int
+		reason   string
+	)
+	for i, item := range result.Items {
+		if i >= len(docs) {
+			break
+		}
+		for _, r := range item {
+			if r.Status < 300 {
+				continue
+			}
+			if retryable(r.Status) {
+				retry = append(retry, docs[i])
+			} else {
+				rejected++
+			}
+			if reason == "" && r.Error != nil {
+				reason = r.Error.Type + ": " + r.Error.Reason
+			}
+		}
+	}
+	return retry, fmt.Errorf("bulk: %d retried, %d rejected (%s)", len(retry), rejected, reason)
+}
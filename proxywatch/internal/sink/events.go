@@ -0,0 +1,160 @@
+// Package sink forwards findings to external collectors. A Tracker turns the
+// candidate list of each refresh into events, and each sink formats and ships
+// them.
+package sink
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	"proxywatch/internal/shared"
+)
+
+// Event kinds.
+const (
+	Appeared    = "appeared"
+	Changed     = "changed"
+	Disappeared = "disappeared"
+)
+
+// maxTargets caps the remote targets carried in one event.
+const maxTargets = 20
+
+// Event is one change in what proxywatch reports about a process. For
+// Disappeared, Candidate is the last one seen.
+type Event struct {
+	Kind      string
+	At        time.Time
+	Candidate shared.Candidate
+	PrevRole  string
+	PrevBand  string
+}
+
+// Band buckets a score so small score movements do not produce events.
+func Band(score int) string {
+	switch {
+	case score >= 90:
+		return "critical"
+	case score >= 70:
+		return "high"
+	case score >= 40:
+		return "medium"
+	default:
+		return "low"
+	}
+}
+
+// Targets lists the distinct non-loopback remote endpoints of c, TCP first,
+// capped at maxTargets.
+func Targets(c *shared.Candidate) []string {
+	seen := make(map[string]bool)
+	var out []string
+	add := func(addr string, port int) {
+		if addr == "" || shared.IsLoopbackIP(addr) || len(out) >= maxTargets {
+			return
+		}
+		ep := net.JoinHostPort(addr, strconv.Itoa(port))
+		if !seen[ep] {
+			seen[ep] = true
+			out = append(out, ep)
+		}
+	}
+	for _, conn := range c.Conns {
+		add(conn.RemoteAddress, conn.RemotePort)
+	}
+	for _, u := range c.UDPConns {
+		add(u.RemoteAddress, u.RemotePort)
+	}
+	return out
+}
+
+// ControlChannel formats the candidate's control channel remote endpoint, or
+// returns "" when it has none.
+func ControlChannel(c *shared.Candidate) string {
+	if c.ControlChannel == nil {
+		return ""
+	}
+	return net.JoinHostPort(c.ControlChannel.RemoteAddress, strconv.Itoa(c.ControlChannel.RemotePort))
+}
+
+// Summary is a one-line human-readable description of e.
+func Summary(e Event) string {
+	c := &e.Candidate
+	name, pid := "?", 0
+	if c.Proc != nil {
+		name, pid = c.Proc.Name, c.Proc.Pid
+	}
+	switch e.Kind {
+	case Changed:
+		if e.PrevRole == c.Role {
+			return fmt.Sprintf("%s (pid %d) %s, score %d (%s -> %s)", name, pid, c.Role, c.Score, e.PrevBand, Band(c.Score))
+		}
+		return fmt.Sprintf("%s (pid %d) %s -> %s, score %d", name, pid, e.PrevRole, c.Role, c.Score)
+	case Disappeared:
+		return fmt.Sprintf("%s (pid %d) %s no longer reported", name, pid, c.Role)
+	}
+	return fmt.Sprintf("%s (pid %d) %s, score %d", name, pid, c.Role, c.Score)
+}
+
+type tracked struct {
+	name string
+	role string
+	band string
+	cand shared.Candidate
+}
+
+// Tracker remembers what was last reported per PID. Suppressed candidates are
+// ignored, so suppressing a process makes it disappear.
+type Tracker struct {
+	seen map[int]*tracked
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{seen: make(map[int]*tracked)}
+}
+
+// Update compares cands with the previous call and returns the events in
+// candidate order, followed by disappearances in PID order. A PID that now
+// belongs to a different process name disappears and appears again.
+func (t *Tracker) Update(at time.Time, cands []shared.Candidate) []Event {
+	var events []Event
+	present := make(map[int]bool, len(cands))
+
+	for _, c := range cands {
+		if c.Proc == nil || c.Suppressed != nil {
+			continue
+		}
+		pid := c.Proc.Pid
+		present[pid] = true
+		band := Band(c.Score)
+
+		prev := t.seen[pid]
+		if prev != nil && prev.name != c.Proc.Name {
+			events = append(events, Event{Kind: Disappeared, At: at, Candidate: prev.cand})
+			prev = nil
+		}
+		switch {
+		case prev == nil:
+			events = append(events, Event{Kind: Appeared, At: at, Candidate: c})
+		case prev.role != c.Role || prev.band != band:
+			events = append(events, Event{Kind: Changed, At: at, Candidate: c, PrevRole: prev.role, PrevBand: prev.band})
+		}
+		t.seen[pid] = &tracked{name: c.Proc.Name, role: c.Role, band: band, cand: c}
+	}
+
+	var gone []int
+	for pid := range t.seen {
+		if !present[pid] {
+			gone = append(gone, pid)
+		}
+	}
+	sort.Ints(gone)
+	for _, pid := range gone {
+		events = append(events, Event{Kind: Disappeared, At: at, Candidate: t.seen[pid].cand})
+		delete(t.seen, pid)
+	}
+	return events
+}
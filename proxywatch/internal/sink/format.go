@@ -0,0 +1,181 @@
+package sink
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Syslog message formats.
+const (
+	SyslogRFC5424 = "rfc5424"
+	SyslogCEF     = "cef"
+)
+
+const (
+	appName = "proxywatch"
+	// sdID uses the IANA example enterprise number; proxywatch has none
+	sdID = "proxywatch@32473"
+	// facilityLocal0 is the syslog facility every message is sent with
+	facilityLocal0 = 16
+	cefVersion     = "1.0"
+)
+
+// severity maps an event to a syslog severity: crit for critical scores down
+// to notice for low ones, and info for disappearances.
+func severity(e Event) int {
+	if e.Kind == Disappeared {
+		return 6
+	}
+	switch Band(e.Candidate.Score) {
+	case "critical":
+		return 2
+	case "high":
+		return 3
+	case "medium":
+		return 4
+	}
+	return 5
+}
+
+// header is an RFC 5424 header up to and including MSGID.
+func header(e Event, hostname, procID string) string {
+	if hostname == "" {
+		hostname = "-"
+	}
+	return fmt.Sprintf("<%d>1 %s %s %s %s %s",
+		facilityLocal0*8+severity(e),
+		e.At.UTC().Format("2006-01-02T15:04:05.000000Z"),
+		hostname, appName, procID, e.Kind)
+}
+
+// FormatRFC5424 renders e as an RFC 5424 message with the candidate in one
+// structured data element.
+func FormatRFC5424(e Event, hostname, procID string) string {
+	var sd strings.Builder
+	sd.WriteString("[" + sdID)
+	for _, f := range fields(e) {
+		if f.value == "" {
+			continue
+		}
+		sd.WriteString(" " + f.name + `="` + sdEscape(f.value) + `"`)
+	}
+	sd.WriteString("]")
+	return header(e, hostname, procID) + " " + sd.String() + " " + controlCharFilter.Replace(Summary(e))
+}
+
+// FormatCEF renders e as an ArcSight CEF record carried in an RFC 5424
+// message without structured data.
+func FormatCEF(e Event, hostname, procID string) string {
+	c := &e.Candidate
+	sev := min(max(c.Score/10, 0), 10)
+	if e.Kind == Disappeared {
+		sev = 1
+	}
+
+	ext := []string{
+		"rt=" + strconv.FormatInt(e.At.UnixMilli(), 10),
+		"act=" + e.Kind,
+	}
+	if hostname != "" {
+		ext = append(ext, "dvchost="+cefEscape(hostname))
+	}
+	if c.Proc != nil {
+		ext = append(ext, "spid="+strconv.Itoa(c.Proc.Pid), "sproc="+cefEscape(c.Proc.Name))
+		if c.Proc.ExePath != "" {
+			ext = append(ext, "filePath="+cefEscape(c.Proc.ExePath))
+		}
+		if c.Proc.UserName != "" {
+			ext = append(ext, "suser="+cefEscape(c.Proc.UserName))
+		}
+	}
+	if cc := c.ControlChannel; cc != nil {
+		ext = append(ext, "dst="+cefEscape(cc.RemoteAddress), "dpt="+strconv.Itoa(cc.RemotePort))
+	}
+	controlSecs := ""
+	if c.ControlChannel != nil {
+		controlSecs = strconv.Itoa(c.ControlDurationSeconds)
+	}
+	custom := []struct{ key, label, value string }{
+		{"cs1", "role", c.Role},
+		{"cs2", "previousRole", e.PrevRole},
+		{"cs3", "controlChannel", ControlChannel(c)},
+		{"cs4", "targets", strings.Join(Targets(c), ",")},
+		{"cs5", "band", Band(c.Score)},
+		{"cn1", "score", strconv.Itoa(c.Score)},
+		{"cn2", "confidence", strconv.Itoa(c.Confidence)},
+		{"cn3", "controlSeconds", controlSecs},
+	}
+	for _, f := range custom {
+		if f.value == "" {
+			continue
+		}
+		ext = append(ext, f.key+"Label="+f.label, f.key+"="+cefEscape(f.value))
+	}
+	ext = append(ext, "msg="+cefEscape(Summary(e)))
+
+	record := strings.Join([]string{
+		"CEF:0",
+		"ProxyWatch",
+		appName,
+		cefVersion,
+		cefHeaderEscape(c.Role),
+		cefHeaderEscape(c.Role + " " + e.Kind),
+		strconv.Itoa(sev),
+		strings.Join(ext, " "),
+	}, "|")
+	return header(e, hostname, procID) + " - " + record
+}
+
+type field struct {
+	name  string
+	value string
+}
+
+// fields are the structured data parameters of an event.
+func fields(e Event) []field {
+	c := &e.Candidate
+	out := []field{{"event", e.Kind}}
+	if c.Proc != nil {
+		out = append(out,
+			field{"pid", strconv.Itoa(c.Proc.Pid)},
+			field{"name", c.Proc.Name},
+			field{"exe", c.Proc.ExePath},
+			field{"user", c.Proc.UserName},
+		)
+	}
+	out = append(out,
+		field{"role", c.Role},
+		field{"prevRole", e.PrevRole},
+		field{"score", strconv.Itoa(c.Score)},
+		field{"band", Band(c.Score)},
+		field{"prevBand", e.PrevBand},
+		field{"confidence", strconv.Itoa(c.Confidence)},
+		field{"active", strconv.FormatBool(c.ActiveProxying)},
+		field{"control", ControlChannel(c)},
+	)
+	if c.ControlChannel != nil {
+		out = append(out, field{"controlSecs", strconv.Itoa(c.ControlDurationSeconds)})
+	}
+	out = append(out, field{"targets", strings.Join(Targets(c), ",")})
+	return out
+}
+
+var (
+	sdEscaper         = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+	cefEscaper        = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+	cefHeaderEscaper  = strings.NewReplacer(`\`, `\\`, `|`, `\|`)
+	controlCharFilter = strings.NewReplacer("\n", " ", "\r", " ")
+)
+
+func sdEscape(s string) string {
+	return sdEscaper.Replace(controlCharFilter.Replace(s))
+}
+
+func cefEscape(s string) string {
+	return cefEscaper.Replace(s)
+}
+
+func cefHeaderEscape(s string) string {
+	return cefHeaderEscaper.Replace(controlCharFilter.Replace(s))
+}
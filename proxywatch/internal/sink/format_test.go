@@ -0,0 +1,271 @@
+package sink
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"proxywatch/internal/shared"
+)
+
+const (
+	hostileExe  = "C:\\Temp\\a\"b]c=d|e\nf.exe"
+	hostileUser = "DOM\\ev\"il]=|x\r"
+	hostileName = "a|b=c]\"\\"
+)
+
+func hostileEvent() Event {
+	return Event{
+		Kind: Changed,
+		At:   time.Date(2026, 1, 2, 3, 4, 5, 6000, time.UTC),
+		Candidate: shared.Candidate{
+			Proc: &shared.ProcessInfo{
+				Pid:      4242,
+				Name:     hostileName,
+				ExePath:  hostileExe,
+				UserName: hostileUser,
+			},
+			Role:       "reverse-proxy",
+			Score:      93,
+			Confidence: 88,
+			ControlChannel: &shared.ConnectionInfo{
+				RemoteAddress: "203.0.113.9",
+				RemotePort:    8443,
+			},
+			ControlDurationSeconds: 120,
+		},
+		PrevRole: "outbound-only",
+		PrevBand: "low",
+	}
+}
+
+// parseSD reads the SD-PARAMs of the first structured data element in an
+// RFC 5424 message, undoing the escapes of RFC 5424 section 6.3.3.
+func parseSD(t *testing.T, msg string) (map[string]string, string) {
+	t.Helper()
+	start := strings.Index(msg, "["+sdID)
+	if start < 0 {
+		t.Fatalf("no %s element in %q", sdID, msg)
+	}
+	s := msg[start+len(sdID)+1:]
+	params := make(map[string]string)
+	for {
+		switch {
+		case strings.HasPrefix(s, "]"):
+			return params, s[1:]
+		case strings.HasPrefix(s, " "):
+			s = s[1:]
+		default:
+			t.Fatalf("unexpected structured data at %q", s)
+		}
+		eq := strings.Index(s, `="`)
+		if eq < 0 {
+			t.Fatalf("no value at %q", s)
+		}
+		name := s[:eq]
+		s = s[eq+2:]
+		var val strings.Builder
+		for {
+			if s == "" {
+				t.Fatalf("unterminated value for %s", name)
+			}
+			c := s[0]
+			s = s[1:]
+			if c == '\\' && s != "" && strings.IndexByte(`"\]`, s[0]) >= 0 {
+				val.WriteByte(s[0])
+				s = s[1:]
+				continue
+			}
+			if c == '"' {
+				break
+			}
+			if c == ']' {
+				t.Fatalf("unescaped ] in value of %s", name)
+			}
+			val.WriteByte(c)
+		}
+		params[name] = val.String()
+	}
+}
+
+func TestFormatRFC5424Escaping(t *testing.T) {
+	msg := FormatRFC5424(hostileEvent(), "host1", "77")
+	if strings.ContainsAny(msg, "\r\n") {
+		t.Fatalf("message contains a line break: %q", msg)
+	}
+	if !strings.HasPrefix(msg, "<130>1 2026-01-02T03:04:05.000006Z host1 proxywatch 77 changed [") {
+		t.Errorf("header = %q", msg)
+	}
+
+	params, rest := parseSD(t, msg)
+	want := map[string]string{
+		"event":       "changed",
+		"pid":         "4242",
+		"name":        hostileName,
+		"exe":         strings.ReplaceAll(hostileExe, "\n", " "),
+		"user":        strings.ReplaceAll(hostileUser, "\r", " "),
+		"role":        "reverse-proxy",
+		"prevRole":    "outbound-only",
+		"score":       "93",
+		"band":        "critical",
+		"prevBand":    "low",
+		"confidence":  "88",
+		"active":      "false",
+		"control":     "203.0.113.9:8443",
+		"controlSecs": "120",
+	}
+	for k, v := range want {
+		if params[k] != v {
+			t.Errorf("%s = %q, want %q", k, params[k], v)
+		}
+	}
+	if !strings.HasPrefix(rest, " "+hostileName+" (pid 4242)") {
+		t.Errorf("MSG = %q", rest)
+	}
+}
+
+// splitCEF splits a CEF record into its seven header fields and the
+// extension, honouring escaped pipes.
+func splitCEF(t *testing.T, record string) ([]string, string) {
+	t.Helper()
+	var fields []string
+	var cur strings.Builder
+	for i := 0; i < len(record); i++ {
+		c := record[i]
+		if len(fields) == 7 {
+			return fields, record[i:]
+		}
+		switch {
+		case c == '\\' && i+1 < len(record):
+			i++
+			cur.WriteByte(record[i])
+		case c == '|':
+			fields = append(fields, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	t.Fatalf("CEF record has %d header fields: %q", len(fields), record)
+	return nil, ""
+}
+
+// parseCEFExtension reads key=value pairs; a value runs up to the space
+// before the next unescaped '='-terminated key.
+func parseCEFExtension(t *testing.T, ext string) map[string]string {
+	t.Helper()
+	var eqs []int
+	for i := 0; i < len(ext); i++ {
+		switch ext[i] {
+		case '\\':
+			i++
+		case '=':
+			eqs = append(eqs, i)
+		}
+	}
+	unescape := strings.NewReplacer(`\\`, `\`, `\=`, `=`, `\n`, "\n", `\r`, "\r")
+	out := make(map[string]string)
+	for n, eq := range eqs {
+		keyStart := strings.LastIndexByte(ext[:eq], ' ') + 1
+		end := len(ext)
+		if n+1 < len(eqs) {
+			end = strings.LastIndexByte(ext[:eqs[n+1]], ' ')
+		}
+		key := ext[keyStart:eq]
+		if end < eq || strings.ContainsAny(key, `\|`) {
+			t.Fatalf("bad extension key %q in %q", key, ext)
+		}
+		out[key] = unescape.Replace(ext[eq+1 : end])
+	}
+	return out
+}
+
+func TestFormatCEFEscaping(t *testing.T) {
+	msg := FormatCEF(hostileEvent(), "host1", "77")
+	if strings.ContainsAny(msg, "\r\n") {
+		t.Fatalf("message contains a line break: %q", msg)
+	}
+	prefix := "<130>1 2026-01-02T03:04:05.000006Z host1 proxywatch 77 changed - "
+	if !strings.HasPrefix(msg, prefix) {
+		t.Fatalf("header = %q", msg)
+	}
+
+	header, ext := splitCEF(t, strings.TrimPrefix(msg, prefix))
+	wantHeader := []string{"CEF:0", "ProxyWatch", "proxywatch", cefVersion, "reverse-proxy", "reverse-proxy changed", "9"}
+	if fmt.Sprint(header) != fmt.Sprint(wantHeader) {
+		t.Errorf("header = %q, want %q", header, wantHeader)
+	}
+
+	got := parseCEFExtension(t, ext)
+	want := map[string]string{
+		"act":      "changed",
+		"dvchost":  "host1",
+		"spid":     "4242",
+		"sproc":    hostileName,
+		"filePath": hostileExe,
+		"suser":    hostileUser,
+		"dst":      "203.0.113.9",
+		"dpt":      "8443",
+		"cs1":      "reverse-proxy",
+		"cs2":      "outbound-only",
+		"cs5":      "critical",
+		"cn1":      "93",
+		"cn3":      "120",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+}
+
+func TestCEFHeaderEscaping(t *testing.T) {
+	e := hostileEvent()
+	e.Candidate.Role = "odd|role\\x\ny"
+	msg := FormatCEF(e, "", "-")
+	_, record, _ := strings.Cut(msg, " - ")
+	header, _ := splitCEF(t, record)
+	if header[4] != "odd|role\\x y" {
+		t.Errorf("signature id = %q", header[4])
+	}
+	if header[6] != "9" {
+		t.Errorf("severity = %q", header[6])
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	tests := []struct {
+		kind   string
+		score  int
+		pri    string
+		cefSev string
+		band   string
+	}{
+		{Appeared, 95, "<130>", "|9|", "critical"},
+		{Appeared, 90, "<130>", "|9|", "critical"},
+		{Appeared, 89, "<131>", "|8|", "high"},
+		{Appeared, 70, "<131>", "|7|", "high"},
+		{Changed, 69, "<132>", "|6|", "medium"},
+		{Appeared, 40, "<132>", "|4|", "medium"},
+		{Appeared, 39, "<133>", "|3|", "low"},
+		{Appeared, -20, "<133>", "|0|", "low"},
+		{Appeared, 250, "<130>", "|10|", "critical"},
+		{Disappeared, 95, "<134>", "|1|", "critical"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%d", tt.kind, tt.score), func(t *testing.T) {
+			e := Event{Kind: tt.kind, At: time.Unix(0, 0), Candidate: shared.Candidate{Role: "beacon", Score: tt.score}}
+			if got := Band(tt.score); got != tt.band {
+				t.Errorf("Band = %s, want %s", got, tt.band)
+			}
+			if msg := FormatRFC5424(e, "h", "1"); !strings.HasPrefix(msg, tt.pri) {
+				t.Errorf("RFC 5424 PRI = %q, want %s", msg[:5], tt.pri)
+			}
+			msg := FormatCEF(e, "h", "1")
+			if !strings.HasPrefix(msg, tt.pri) || !strings.Contains(msg, "|beacon "+tt.kind+tt.cefSev) {
+				t.Errorf("CEF = %q, want PRI %s and severity %s", msg, tt.pri, tt.cefSev)
+			}
+		})
+	}
+}
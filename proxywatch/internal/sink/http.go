@@ -0,0 +1,380 @@
+package sink
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"proxywatch/internal/shared"
+)
+
+// HTTPOptions configures an HTTP collector sink. Connections also ships every
+// new TCP connection and connected UDP socket, not just findings. With
+// QueueDir set, batches the collector did not take are kept on disk (in a
+// subdirectory per sink) and sent once it is back, also after a restart.
+type HTTPOptions struct {
+	URL         string
+	Token       string
+	Index       string
+	Connections bool
+	QueueDir    string
+	BatchSize   int
+	FlushEvery  time.Duration
+	CAFile      string
+}
+
+const (
+	defaultBatchSize  = 500
+	defaultFlushEvery = 5 * time.Second
+	// maxPending bounds the documents held in memory; beyond it they spill
+	// to the disk queue, or the oldest are dropped without one
+	maxPending  = 20000
+	httpTimeout = 15 * time.Second
+	minBackoff  = time.Second
+	maxBackoff  = time.Minute
+)
+
+// deliverFunc sends one batch and returns the documents to try again later.
+// An error with nothing to retry means the collector rejected them for good.
+type deliverFunc func(ctx context.Context, docs []doc) ([]doc, error)
+
+// HTTPSink batches findings (and optionally connection records) and ships
+// them from a background goroutine, backing off exponentially while the
+// collector fails.
+type HTTPSink struct {
+	name        string
+	hostname    string
+	connections bool
+	batchSize   int
+	flushEvery  time.Duration
+	deliver     deliverFunc
+	queue       *diskQueue
+	tracker     *Tracker
+	conns       *ConnectionTracker
+
+	mu      sync.Mutex
+	pending []doc
+	lastErr error
+	dropped int
+
+	kick      chan struct{}
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newHTTPSink(name string, opts HTTPOptions, deliver deliverFunc) (*HTTPSink, error) {
+	s := &HTTPSink{
+		name:        name,
+		connections: opts.Connections,
+		batchSize:   opts.BatchSize,
+		flushEvery:  opts.FlushEvery,
+		deliver:     deliver,
+		tracker:     NewTracker(),
+		conns:       NewConnectionTracker(),
+		kick:        make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	if s.batchSize <= 0 {
+		s.batchSize = defaultBatchSize
+	}
+	if s.flushEvery <= 0 {
+		s.flushEvery = defaultFlushEvery
+	}
+	s.hostname, _ = os.Hostname()
+	if opts.QueueDir != "" {
+		q, err := openDiskQueue(filepath.Join(opts.QueueDir, name))
+		if err != nil {
+			return nil, fmt.Errorf("%s: queue: %w", name, err)
+		}
+		s.queue = q
+	}
+
+	go s.run()
+	return s, nil
+}
+
+// httpClient returns a client for opts, trusting CAFile instead of the
+// system roots when it is set.
+func httpClient(opts HTTPOptions) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", opts.CAFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	return &http.Client{Transport: transport, Timeout: httpTimeout}, nil
+}
+
+// endpoint parses raw and fills in path when raw has none. Credentials in the
+// URL are split off for basic auth.
+func endpoint(raw, path string) (string, *url.Userinfo, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", nil, fmt.Errorf("%q: want an http:// or https:// URL", raw)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = path
+	}
+	user := u.User
+	u.User = nil
+	return u.String(), user, nil
+}
+
+// retryable reports whether a collector status is worth retrying. Auth
+// failures are kept too: they need a config fix, not new data.
+func retryable(status int) bool {
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+	return status >= 500
+}
+
+func statusError(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if len(msg) == 0 {
+		return errors.New(resp.Status)
+	}
+	return fmt.Errorf("%s: %s", resp.Status, msg)
+}
+
+func newDoc(at time.Time, dataset string, body map[string]any) (doc, error) {
+	raw, err := json.Marshal(body)
+	return doc{Time: at, Dataset: dataset, Body: raw}, err
+}
+
+// Observe queues the documents for this refresh and reports the last
+// delivery failure, if any, since the previous call.
+func (s *HTTPSink) Observe(snap *shared.Snapshot, cands []shared.Candidate) error {
+	at := time.Now()
+	if snap != nil && !snap.Timestamp.IsZero() {
+		at = snap.Timestamp
+	}
+
+	var docs []doc
+	for _, e := range s.tracker.Update(at, cands) {
+		d, err := newDoc(at, DatasetFinding, FindingDoc(e, s.hostname))
+		if err != nil {
+			return fmt.Errorf("%s: %w", s.name, err)
+		}
+		docs = append(docs, d)
+	}
+	if s.connections {
+		for _, body := range s.conns.Update(snap, s.hostname) {
+			d, err := newDoc(at, DatasetConnection, body)
+			if err != nil {
+				return fmt.Errorf("%s: %w", s.name, err)
+			}
+			docs = append(docs, d)
+		}
+	}
+
+	s.mu.Lock()
+	s.pending = append(s.pending, docs...)
+	if over := len(s.pending) - maxPending; over > 0 {
+		if s.queue != nil && s.spill(s.pending) == nil {
+			s.pending = nil
+		} else {
+			s.pending = s.pending[over:]
+			s.dropped += over
+		}
+	}
+	full := len(s.pending) >= s.batchSize
+	err, dropped := s.lastErr, s.dropped
+	s.lastErr, s.dropped = nil, 0
+	s.mu.Unlock()
+
+	if full {
+		select {
+		case s.kick <- struct{}{}:
+		default:
+		}
+	}
+
+	switch {
+	case err != nil && dropped > 0:
+		return fmt.Errorf("%s: %w (%d documents dropped)", s.name, err, dropped)
+	case err != nil:
+		return fmt.Errorf("%s: %w", s.name, err)
+	case dropped > 0:
+		return fmt.Errorf("%s: %d documents dropped, collector too slow", s.name, dropped)
+	}
+	return nil
+}
+
+// Close makes one last attempt to send what is pending, queues the rest on
+// disk when a queue is configured, and stops the sender.
+func (s *HTTPSink) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		<-s.done
+	})
+	return nil
+}
+
+func (s *HTTPSink) run() {
+	defer close(s.done)
+
+	timer := time.NewTimer(s.flushEvery)
+	defer timer.Stop()
+	var backoff time.Duration
+	for {
+		select {
+		case <-s.stop:
+			s.final()
+			return
+		case <-s.kick:
+			if backoff > 0 {
+				// still waiting out a failure
+				continue
+			}
+		case <-timer.C:
+		}
+
+		if s.flush() {
+			backoff = 0
+			timer.Reset(s.flushEvery)
+			continue
+		}
+		backoff = min(max(backoff*2, minBackoff), maxBackoff)
+		timer.Reset(backoff)
+	}
+}
+
+// flush sends queued segments, oldest first, then pending batches until both
+// are empty. It returns false when the collector failed and should be left
+// alone for a while.
+func (s *HTTPSink) flush() bool {
+	if s.queue != nil {
+		for {
+			name, docs, err := s.queue.oldest()
+			if err != nil {
+				s.setErr(err)
+				break
+			}
+			if name == "" {
+				break
+			}
+			var retry []doc
+			if len(docs) > 0 {
+				if retry, _ = s.send(docs); len(retry) == len(docs) {
+					return false
+				}
+			}
+			if err := s.queue.remove(name); err != nil {
+				s.setErr(err)
+				break
+			}
+			if len(retry) > 0 {
+				s.requeue(retry)
+				return false
+			}
+		}
+	}
+
+	for {
+		batch := s.take()
+		if len(batch) == 0 {
+			return true
+		}
+		if retry, _ := s.send(batch); len(retry) > 0 {
+			s.requeue(retry)
+			return false
+		}
+	}
+}
+
+// final gives pending documents one more try on shutdown.
+func (s *HTTPSink) final() {
+	for {
+		batch := s.take()
+		if len(batch) == 0 {
+			return
+		}
+		retry, _ := s.send(batch)
+		if len(retry) == 0 {
+			continue
+		}
+		if s.queue != nil {
+			s.mu.Lock()
+			rest := append(retry, s.pending...)
+			s.pending = nil
+			s.mu.Unlock()
+			_ = s.spill(rest)
+		}
+		return
+	}
+}
+
+func (s *HTTPSink) send(docs []doc) ([]doc, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), httpTimeout)
+	defer cancel()
+	retry, err := s.deliver(ctx, docs)
+	if err != nil {
+		s.setErr(err)
+	}
+	return retry, err
+}
+
+func (s *HTTPSink) take() []doc {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := min(s.batchSize, len(s.pending))
+	batch := append([]doc(nil), s.pending[:n]...)
+	s.pending = s.pending[n:]
+	return batch
+}
+
+// requeue keeps documents the collector did not take: on disk when there is
+// a queue, otherwise back at the front of the pending list.
+func (s *HTTPSink) requeue(docs []doc) {
+	if s.queue != nil && s.spill(docs) == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = append(docs, s.pending...)
+	if over := len(s.pending) - maxPending; over > 0 {
+		s.pending = s.pending[over:]
+		s.dropped += over
+	}
+}
+
+// spill writes docs to the disk queue in batch-sized segments.
+func (s *HTTPSink) spill(docs []doc) error {
+	for len(docs) > 0 {
+		n := min(s.batchSize, len(docs))
+		if err := s.queue.push(docs[:n]); err != nil {
+			return err
+		}
+		docs = docs[n:]
+	}
+	return nil
+}
+
+func (s *HTTPSink) setErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastErr = err
+}
@@ -0,0 +1,328 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"proxywatch/internal/shared"
+)
+
+func testCands(pids ...int) []shared.Candidate {
+	var cands []shared.Candidate
+	for _, pid := range pids {
+		cands = append(cands, shared.Candidate{
+			Proc:  &shared.ProcessInfo{Pid: pid, Name: "agent.exe"},
+			Role:  "reverse-control",
+			Score: 80,
+		})
+	}
+	return cands
+}
+
+func testDocs(t *testing.T, n int) []doc {
+	t.Helper()
+	var docs []doc
+	for i := 0; i < n; i++ {
+		d, err := newDoc(time.Unix(1700000000, 0), DatasetFinding, map[string]any{"n": i})
+		if err != nil {
+			t.Fatal(err)
+		}
+		docs = append(docs, d)
+	}
+	return docs
+}
+
+// collector is a stand-in server that records request bodies and answers
+// with whatever respond returns.
+type collector struct {
+	mu      sync.Mutex
+	bodies  []string
+	headers []http.Header
+	respond func(n int) (int, string)
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	c.mu.Lock()
+	c.bodies = append(c.bodies, string(body))
+	c.headers = append(c.headers, r.Header.Clone())
+	n := len(c.bodies)
+	c.mu.Unlock()
+
+	status, reply := http.StatusOK, `{"text":"Success","code":0}`
+	if c.respond != nil {
+		status, reply = c.respond(n)
+	}
+	w.WriteHeader(status)
+	io.WriteString(w, reply)
+}
+
+func (c *collector) requests() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.bodies)
+}
+
+func (c *collector) body(i int) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.bodies[i]
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSplunkHEC(t *testing.T) {
+	col := &collector{}
+	srv := httptest.NewServer(col)
+	defer srv.Close()
+
+	s, err := NewSplunkHEC(HTTPOptions{URL: srv.URL, Token: "secret", Index: "sec", FlushEvery: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Observe(&shared.Snapshot{}, testCands(100, 101)); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	if col.requests() != 1 {
+		t.Fatalf("got %d requests, want 1", col.requests())
+	}
+	if got := col.headers[0].Get("Authorization"); got != "Splunk secret" {
+		t.Errorf("Authorization = %q", got)
+	}
+
+	type hecEvent struct {
+		Sourcetype string         `json:"sourcetype"`
+		Index      string         `json:"index"`
+		Event      map[string]any `json:"event"`
+	}
+	dec := json.NewDecoder(strings.NewReader(col.body(0)))
+	var events []hecEvent
+	for dec.More() {
+		var ev hecEvent
+		if err := dec.Decode(&ev); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, ev)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	for _, ev := range events {
+		if ev.Sourcetype != "proxywatch:finding" || ev.Index != "sec" {
+			t.Errorf("sourcetype %q index %q", ev.Sourcetype, ev.Index)
+		}
+		rule, _ := ev.Event["rule"].(map[string]any)
+		if rule["name"] != "reverse-control" {
+			t.Errorf("rule.name = %v", rule["name"])
+		}
+	}
+}
+
+func TestSplunkHECNeedsToken(t *testing.T) {
+	if _, err := NewSplunkHEC(HTTPOptions{URL: "http://127.0.0.1:8088"}); err == nil {
+		t.Fatal("NewSplunkHEC without a token succeeded")
+	}
+}
+
+func TestElasticBulkPartialFailure(t *testing.T) {
+	col := &collector{respond: func(int) (int, string) {
+		return http.StatusOK, `{"errors":true,"items":[
+			{"create":{"status":201}},
+			{"create":{"status":429,"error":{"type":"es_rejected_execution_exception","reason":"queue full"}}},
+			{"create":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"bad field"}}}]}`
+	}}
+	srv := httptest.NewServer(col)
+	defer srv.Close()
+
+	s, err := NewElastic(HTTPOptions{URL: srv.URL, Token: "key", FlushEvery: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	docs := testDocs(t, 3)
+	retry, err := s.deliver(context.Background(), docs)
+	if err == nil {
+		t.Fatal("partial failure reported no error")
+	}
+	if len(retry) != 1 || string(retry[0].Body) != string(docs[1].Body) {
+		t.Fatalf("retry = %v, want only the 429 item", retry)
+	}
+
+	if got := col.headers[0].Get("Authorization"); got != "ApiKey key" {
+		t.Errorf("Authorization = %q", got)
+	}
+	lines := strings.Split(strings.TrimSpace(col.body(0)), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("bulk body has %d lines, want 6", len(lines))
+	}
+	if !strings.Contains(lines[0], `"create"`) || !strings.Contains(lines[0], DefaultElasticIndex) {
+		t.Errorf("action line = %s", lines[0])
+	}
+}
+
+func TestElasticBulkUndecodableResponse(t *testing.T) {
+	col := &collector{respond: func(int) (int, string) {
+		return http.StatusOK, `<html>gateway</html>`
+	}}
+	srv := httptest.NewServer(col)
+	defer srv.Close()
+
+	s, err := NewElastic(HTTPOptions{URL: srv.URL, FlushEvery: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	docs := testDocs(t, 2)
+	retry, err := s.deliver(context.Background(), docs)
+	if err == nil || len(retry) != len(docs) {
+		t.Fatalf("retry %d of %d docs, err %v; want all retried", len(retry), len(docs), err)
+	}
+}
+
+func TestHTTPSinkBacksOffAndRecovers(t *testing.T) {
+	var failedAt, recoveredAt atomic.Int64
+	col := &collector{}
+	col.respond = func(n int) (int, string) {
+		if n == 1 {
+			failedAt.Store(time.Now().UnixNano())
+			return http.StatusServiceUnavailable, "busy"
+		}
+		recoveredAt.Store(time.Now().UnixNano())
+		return http.StatusOK, `{"text":"Success","code":0}`
+	}
+	srv := httptest.NewServer(col)
+	defer srv.Close()
+
+	s, err := NewSplunkHEC(HTTPOptions{URL: srv.URL, Token: "secret", FlushEvery: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	cands := testCands(100)
+	if err := s.Observe(&shared.Snapshot{}, cands); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the retry", func() bool { return col.requests() >= 2 })
+
+	if gap := time.Duration(recoveredAt.Load() - failedAt.Load()); gap < minBackoff {
+		t.Errorf("retried after %v, want at least %v", gap, minBackoff)
+	}
+	if col.body(0) != col.body(1) {
+		t.Error("retry sent a different batch")
+	}
+	err = s.Observe(&shared.Snapshot{}, cands)
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("Observe after a failure = %v, want the 503", err)
+	}
+	if err := s.Observe(&shared.Snapshot{}, cands); err != nil {
+		t.Errorf("Observe after recovery = %v", err)
+	}
+}
+
+func TestDiskQueueReopen(t *testing.T) {
+	dir := t.TempDir()
+	q, err := openDiskQueue(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := testDocs(t, 3)
+	if err := q.push(docs[:2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.push(docs[2:]); err != nil {
+		t.Fatal(err)
+	}
+
+	q, err = openDiskQueue(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.push(testDocs(t, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []doc
+	var names []string
+	for {
+		name, batch, err := q.oldest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name == "" {
+			break
+		}
+		names = append(names, name)
+		got = append(got, batch...)
+		if err := q.remove(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(names) != 3 {
+		t.Fatalf("got segments %v, want 3", names)
+	}
+	if len(got) != 4 {
+		t.Fatalf("got %d docs, want 4", len(got))
+	}
+	for i, d := range docs {
+		if string(got[i].Body) != string(d.Body) || !got[i].Time.Equal(d.Time) || got[i].Dataset != d.Dataset {
+			t.Errorf("doc %d = %+v, want %+v", i, got[i], d)
+		}
+	}
+}
+
+func TestHTTPSinkReplaysQueueAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	down := httptest.NewServer(&collector{respond: func(int) (int, string) {
+		return http.StatusBadGateway, "down"
+	}})
+	s, err := NewSplunkHEC(HTTPOptions{URL: down.URL, Token: "secret", QueueDir: dir, FlushEvery: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Observe(&shared.Snapshot{}, testCands(100, 101, 102)); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	down.Close()
+
+	col := &collector{}
+	up := httptest.NewServer(col)
+	defer up.Close()
+	s, err = NewSplunkHEC(HTTPOptions{URL: up.URL, Token: "secret", QueueDir: dir, FlushEvery: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	waitFor(t, "the queued batch", func() bool { return col.requests() >= 1 })
+	if n := strings.Count(col.body(0), `"sourcetype":"proxywatch:finding"`); n != 3 {
+		t.Errorf("replayed %d events, want 3", n)
+	}
+	waitFor(t, "the queue to drain", func() bool {
+		name, _, err := s.queue.oldest()
+		return err == nil && name == ""
+	})
+}
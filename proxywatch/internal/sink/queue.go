@@ -0,0 +1,144 @@
+package sink
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxSegments bounds the disk queue; the oldest segments are dropped first.
+const maxSegments = 2000
+
+// doc is one document waiting to be shipped.
+type doc struct {
+	Time    time.Time       `json:"time"`
+	Dataset string          `json:"dataset"`
+	Body    json.RawMessage `json:"body"`
+}
+
+// diskQueue keeps undelivered batches as numbered NDJSON segment files, so
+// they survive a collector outage and a restart. Segments are written
+// atomically and sent oldest first.
+type diskQueue struct {
+	mu   sync.Mutex
+	dir  string
+	next uint64
+}
+
+const segmentExt = ".ndjson"
+
+func openDiskQueue(dir string) (*diskQueue, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	q := &diskQueue{dir: dir, next: 1}
+	names, err := q.segments()
+	if err != nil {
+		return nil, err
+	}
+	if len(names) > 0 {
+		last, _ := strconv.ParseUint(strings.TrimSuffix(names[len(names)-1], segmentExt), 10, 64)
+		q.next = last + 1
+	}
+	return q, nil
+}
+
+// segments lists the segment files, oldest first.
+func (q *diskQueue) segments() ([]string, error) {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		if _, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64); err == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// push writes docs as a new segment and drops the oldest beyond maxSegments.
+func (q *diskQueue) push(docs []doc) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var b strings.Builder
+	for _, d := range docs {
+		line, err := json.Marshal(d)
+		if err != nil {
+			return err
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+
+	// zero-padded so names sort in push order
+	name := fmt.Sprintf("%020d%s", q.next, segmentExt)
+	q.next++
+	path := filepath.Join(q.dir, name)
+	if err := os.WriteFile(path+".tmp", []byte(b.String()), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+
+	names, err := q.segments()
+	if err != nil {
+		return err
+	}
+	for len(names) > maxSegments {
+		_ = os.Remove(filepath.Join(q.dir, names[0]))
+		names = names[1:]
+	}
+	return nil
+}
+
+// oldest returns the oldest segment, or "" when the queue is empty. Lines
+// that do not parse are skipped.
+func (q *diskQueue) oldest() (string, []doc, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	names, err := q.segments()
+	if err != nil || len(names) == 0 {
+		return "", nil, err
+	}
+	f, err := os.Open(filepath.Join(q.dir, names[0]))
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+
+	var docs []doc
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		var d doc
+		if json.Unmarshal(sc.Bytes(), &d) == nil {
+			docs = append(docs, d)
+		}
+	}
+	return names[0], docs, sc.Err()
+}
+
+func (q *diskQueue) remove(name string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return os.Remove(filepath.Join(q.dir, name))
+}
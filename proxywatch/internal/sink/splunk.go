@@ -0,0 +1,77 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// hecPath is the Splunk HTTP Event Collector endpoint used when the URL has
+// no path of its own.
+const hecPath = "/services/collector/event"
+
+// NewSplunkHEC ships to a Splunk HTTP Event Collector. Each document becomes
+// one HEC event with sourcetype proxywatch:finding or proxywatch:connection;
+// Index overrides the token's default index.
+func NewSplunkHEC(opts HTTPOptions) (*HTTPSink, error) {
+	if opts.Token == "" {
+		return nil, errors.New("hec: a token is required")
+	}
+	url, _, err := endpoint(opts.URL, hecPath)
+	if err != nil {
+		return nil, err
+	}
+	client, err := httpClient(opts)
+	if err != nil {
+		return nil, err
+	}
+	hostname, _ := os.Hostname()
+
+	deliver := func(ctx context.Context, docs []doc) ([]doc, error) {
+		var body bytes.Buffer
+		enc := json.NewEncoder(&body)
+		for _, d := range docs {
+			ev := map[string]any{
+				"time":       float64(d.Time.UnixMilli()) / 1000,
+				"source":     appName,
+				"sourcetype": "proxywatch:" + strings.TrimPrefix(d.Dataset, "proxywatch."),
+				"event":      d.Body,
+			}
+			if hostname != "" {
+				ev["host"] = hostname
+			}
+			if opts.Index != "" {
+				ev["index"] = opts.Index
+			}
+			if err := enc.Encode(ev); err != nil {
+				return nil, err
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &body)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Splunk "+opts.Token)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := client.Do(req)
+		if err != nil {
+			return docs, err
+		}
+		defer resp.Body.Close()
+
+		switch {
+		case resp.StatusCode < 300:
+			return nil, nil
+		case retryable(resp.StatusCode):
+			return docs, statusError(resp)
+		}
+		return nil, statusError(resp)
+	}
+
+	return newHTTPSink("hec", opts, deliver)
+}
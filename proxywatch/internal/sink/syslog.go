@@ -0,0 +1,259 @@
+package sink
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"proxywatch/internal/shared"
+)
+
+const (
+	syslogTimeout = 3 * time.Second
+	// syslogRetry is how long events are dropped after the collector could
+	// not be reached, so a dead collector does not stall every refresh
+	syslogRetry = 30 * time.Second
+	// syslogBuffer is how many messages wait for the sender before new
+	// ones are dropped
+	syslogBuffer = 1000
+	// syslogDrain bounds how long Close waits for queued messages
+	syslogDrain = 5 * time.Second
+)
+
+// SyslogOptions configures a Syslog sink. URL is udp://host:port,
+// tcp://host:port or tls://host:port (default ports 514, 601 and 6514).
+// CAFile replaces the system roots for tls.
+type SyslogOptions struct {
+	URL    string
+	Format string
+	CAFile string
+}
+
+// Syslog sends one message per event to a syslog collector. Messages go one
+// per datagram over UDP and octet-counted (RFC 6587) over TCP and TLS, from a
+// background goroutine so a slow collector never holds up a refresh.
+type Syslog struct {
+	network  string
+	addr     string
+	tls      *tls.Config
+	format   string
+	hostname string
+	procID   string
+	tracker  *Tracker
+
+	msgs      chan string
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu      sync.Mutex
+	lastErr error
+	dropped int
+
+	// owned by the sender
+	conn    net.Conn
+	retryAt time.Time
+}
+
+func NewSyslog(opts SyslogOptions) (*Syslog, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil {
+		return nil, fmt.Errorf("syslog: %w", err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("syslog: %q has no host (want udp://, tcp:// or tls://host:port)", opts.URL)
+	}
+
+	s := &Syslog{
+		format:  opts.Format,
+		procID:  strconv.Itoa(os.Getpid()),
+		tracker: NewTracker(),
+	}
+	if s.format == "" {
+		s.format = SyslogRFC5424
+	}
+	if s.format != SyslogRFC5424 && s.format != SyslogCEF {
+		return nil, fmt.Errorf("syslog: unknown format %q (want %s or %s)", s.format, SyslogRFC5424, SyslogCEF)
+	}
+	s.hostname, _ = os.Hostname()
+
+	port := u.Port()
+	switch u.Scheme {
+	case "udp":
+		s.network, port = "udp", defaultPort(port, "514")
+	case "tcp":
+		s.network, port = "tcp", defaultPort(port, "601")
+	case "tls":
+		s.network, port = "tcp", defaultPort(port, "6514")
+		s.tls = &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}
+		if opts.CAFile != "" {
+			pem, err := os.ReadFile(opts.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("syslog: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("syslog: no certificates in %s", opts.CAFile)
+			}
+			s.tls.RootCAs = pool
+		}
+	default:
+		return nil, fmt.Errorf("syslog: unknown scheme %q (want udp, tcp or tls)", u.Scheme)
+	}
+	s.addr = net.JoinHostPort(u.Hostname(), port)
+
+	s.msgs = make(chan string, syslogBuffer)
+	s.done = make(chan struct{})
+	go s.run()
+	return s, nil
+}
+
+func defaultPort(port, def string) string {
+	if port == "" {
+		return def
+	}
+	return port
+}
+
+// Observe queues the events for this refresh. Events that could not be
+// delivered since the previous call, or did not fit the queue, are dropped;
+// the error reports them.
+func (s *Syslog) Observe(snap *shared.Snapshot, cands []shared.Candidate) error {
+	at := time.Now()
+	if snap != nil && !snap.Timestamp.IsZero() {
+		at = snap.Timestamp
+	}
+
+	overflow := 0
+	for _, e := range s.tracker.Update(at, cands) {
+		var msg string
+		if s.format == SyslogCEF {
+			msg = FormatCEF(e, s.hostname, s.procID)
+		} else {
+			msg = FormatRFC5424(e, s.hostname, s.procID)
+		}
+		select {
+		case s.msgs <- msg:
+		default:
+			overflow++
+		}
+	}
+
+	s.mu.Lock()
+	err, dropped := s.lastErr, s.dropped+overflow
+	s.lastErr, s.dropped = nil, 0
+	s.mu.Unlock()
+
+	switch {
+	case err != nil:
+		return fmt.Errorf("syslog %s: %w (%d events dropped)", s.addr, err, dropped)
+	case dropped > 0:
+		return fmt.Errorf("syslog %s: %d events dropped, collector too slow", s.addr, dropped)
+	}
+	return nil
+}
+
+// Close sends what is queued, waiting at most syslogDrain, and closes the
+// connection.
+func (s *Syslog) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.msgs)
+		select {
+		case <-s.done:
+		case <-time.After(syslogDrain):
+			err = fmt.Errorf("syslog %s: gave up on %d queued events", s.addr, len(s.msgs))
+		}
+	})
+	return err
+}
+
+func (s *Syslog) run() {
+	defer close(s.done)
+	for msg := range s.msgs {
+		if err := s.send(msg); err != nil {
+			s.mu.Lock()
+			s.lastErr = err
+			s.dropped++
+			s.mu.Unlock()
+		}
+	}
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+var errSyslogDown = errors.New("collector unreachable")
+
+func (s *Syslog) send(msg string) error {
+	frame := []byte(msg)
+	if s.network == "tcp" {
+		frame = []byte(strconv.Itoa(len(msg)) + " " + msg)
+	}
+
+	if err := s.connect(); err != nil {
+		return err
+	}
+	if err := s.write(frame); err == nil {
+		return nil
+	}
+
+	// a stream the collector closed only fails on write, so redial once
+	s.conn.Close()
+	s.conn = nil
+	if err := s.connect(); err != nil {
+		return err
+	}
+	if err := s.write(frame); err != nil {
+		s.fail()
+		return err
+	}
+	return nil
+}
+
+func (s *Syslog) write(frame []byte) error {
+	_ = s.conn.SetWriteDeadline(time.Now().Add(syslogTimeout))
+	_, err := s.conn.Write(frame)
+	return err
+}
+
+func (s *Syslog) connect() error {
+	if s.conn != nil {
+		return nil
+	}
+	if time.Now().Before(s.retryAt) {
+		return errSyslogDown
+	}
+
+	dialer := &net.Dialer{Timeout: syslogTimeout}
+	var (
+		conn net.Conn
+		err  error
+	)
+	if s.tls != nil {
+		conn, err = tls.DialWithDialer(dialer, s.network, s.addr, s.tls)
+	} else {
+		conn, err = dialer.Dial(s.network, s.addr)
+	}
+	if err != nil {
+		s.retryAt = time.Now().Add(syslogRetry)
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+func (s *Syslog) fail() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+	s.retryAt = time.Now().Add(syslogRetry)
+}
@@ -0,0 +1,113 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"proxywatch/internal/shared"
+)
+
+// DatasetSource serves snapshots imported from a JSON file holding either a
+// single Snapshot object or an array of them. Each Collect returns the next
+// snapshot; ErrSourceExhausted follows the last one.
+type DatasetSource struct {
+	mu    sync.Mutex
+	path  string
+	snaps []*shared.Snapshot
+	next  int
+}
+
+func init() {
+	shared.RegisterSource("dataset", func(arg string) (shared.Source, error) {
+		if arg == "" {
+			return nil, errors.New("dataset source requires a path (dataset:<file>)")
+		}
+		return OpenDataset(arg)
+	})
+}
+
+func OpenDataset(path string) (*DatasetSource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var snaps []*shared.Snapshot
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &snaps); err != nil {
+			return nil, fmt.Errorf("dataset %s: %w", path, err)
+		}
+	} else {
+		var snap shared.Snapshot
+		if err := json.Unmarshal(trimmed, &snap); err != nil {
+			return nil, fmt.Errorf("dataset %s: %w", path, err)
+		}
+		snaps = append(snaps, &snap)
+	}
+
+	for i, s := range snaps {
+		if s == nil {
+			return nil, fmt.Errorf("dataset %s: entry %d is null", path, i)
+		}
+		if s.Processes == nil {
+			s.Processes = make(map[int]*shared.ProcessInfo)
+		}
+	}
+
+	return &DatasetSource{path: path, snaps: snaps}, nil
+}
+
+func (d *DatasetSource) Name() string { return "dataset" }
+
+func (d *DatasetSource) Capabilities() shared.SourceCapabilities {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var caps shared.SourceCapabilities
+	for _, s := range d.snaps {
+		if len(s.UDPListeners) > 0 || len(s.UDPConns) > 0 {
+			caps.UDP = true
+		}
+		if len(s.UDPConns) > 0 {
+			caps.UDPPeers = true
+		}
+		if len(s.Connections) > 0 {
+			caps.PerConnection = true
+		}
+		for _, cn := range s.Connections {
+			if cn.BytesSent > 0 || cn.BytesReceived > 0 {
+				caps.ConnCounters = true
+				break
+			}
+		}
+		for _, p := range s.Processes {
+			if p != nil && (p.IOReadBytes > 0 || p.IOWriteBytes > 0) {
+				caps.IOCounters = true
+				break
+			}
+		}
+	}
+	return caps
+}
+
+func (d *DatasetSource) Collect(ctx context.Context) (*shared.Snapshot, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.next >= len(d.snaps) {
+		return nil, shared.ErrSourceExhausted
+	}
+	snap := d.snaps[d.next]
+	d.next++
+	return snap, nil
+}
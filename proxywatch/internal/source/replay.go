@@ -0,0 +1,122 @@
+package source
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"proxywatch/internal/shared"
+)
+
+// ReplaySource feeds a recorded JSON log back as if it were live. With Speed
+// 1 snapshots are released at their original CapturedAt spacing, 2 plays
+// twice as fast, and 0 releases them as fast as they are requested.
+type ReplaySource struct {
+	Speed float64
+
+	mu       sync.Mutex
+	entries  []shared.LogSnapshot
+	next     int
+	lastEmit time.Time
+}
+
+func init() {
+	shared.RegisterSource("replay", func(arg string) (shared.Source, error) {
+		if arg == "" {
+			return nil, errors.New("replay source requires a path (replay:<file>)")
+		}
+		return OpenReplay(arg, 1)
+	})
+}
+
+func OpenReplay(path string, speed float64) (*ReplaySource, error) {
+	if speed < 0 {
+		return nil, fmt.Errorf("replay speed must be >= 0, got %g", speed)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// rotated captures are gzipped
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("replay %s: %w", path, err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	entries, err := shared.ReadLogSnapshots(r)
+	if err != nil {
+		return nil, fmt.Errorf("replay %s: %w", path, err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("replay %s: no snapshots recorded", path)
+	}
+
+	return &ReplaySource{Speed: speed, entries: entries}, nil
+}
+
+func (r *ReplaySource) Name() string { return "replay" }
+
+func (r *ReplaySource) Capabilities() shared.SourceCapabilities {
+	// recordings carry whatever the capturing collector produced
+	return shared.SourceCapabilities{
+		UDP:           true,
+		UDPPeers:      true,
+		IOCounters:    true,
+		PerConnection: true,
+		ConnCounters:  true,
+	}
+}
+
+// Len reports how many snapshots the recording holds.
+func (r *ReplaySource) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.entries)
+}
+
+func (r *ReplaySource) Collect(ctx context.Context) (*shared.Snapshot, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.next >= len(r.entries) {
+		return nil, shared.ErrSourceExhausted
+	}
+
+	entry := r.entries[r.next]
+	if r.next > 0 && r.Speed > 0 {
+		gap := entry.CapturedAt.Sub(r.entries[r.next-1].CapturedAt)
+		wait := time.Duration(float64(gap)/r.Speed) - time.Since(r.lastEmit)
+		if wait > 0 {
+			t := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return nil, ctx.Err()
+			case <-t.C:
+			}
+		}
+	}
+
+	r.next++
+	r.lastEmit = time.Now()
+
+	snap := entry.Snapshot
+	if snap.Timestamp.IsZero() {
+		snap.Timestamp = entry.CapturedAt
+	}
+	return snap, nil
+}
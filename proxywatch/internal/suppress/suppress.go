@@ -0,0 +1,250 @@
+// Package suppress implements the allowlist that hides known-good candidates
+// (VPN clients, backup agents, ...) without dropping them from the output.
+package suppress
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"proxywatch/internal/shared"
+)
+
+// Rule matches when every matcher it sets matches. Text matchers are
+// case-insensitive globs. Remote matchers require every remote endpoint of the
+// process to match, and listener_ports every listener, so a suppressed process
+// resurfaces as soon as it talks to or listens somewhere new.
+type Rule struct {
+	ID      string     `json:"id"`
+	Owner   string     `json:"owner"`
+	Reason  string     `json:"reason"`
+	Expires *time.Time `json:"expires,omitempty"`
+
+	ExePath       string   `json:"exe_path,omitempty"`
+	ProcessName   string   `json:"process_name,omitempty"`
+	Company       string   `json:"company,omitempty"`
+	User          string   `json:"user,omitempty"`
+	Role          string   `json:"role,omitempty"`
+	RemoteCIDRs   []string `json:"remote_cidrs,omitempty"`
+	RemotePorts   []int    `json:"remote_ports,omitempty"`
+	ListenerPorts []int    `json:"listener_ports,omitempty"`
+
+	remoteNets []*net.IPNet
+}
+
+type file struct {
+	Suppressions []Rule `json:"suppressions"`
+}
+
+// List is an ordered, immutable set of rules; the first match wins.
+type List struct {
+	Source string
+	rules  []Rule
+}
+
+func Load(p string) (*List, error) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data, p)
+}
+
+func Parse(data []byte, source string) (*List, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	var f file
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("suppressions %s: %w", source, err)
+	}
+
+	l := &List{Source: source}
+	seen := make(map[string]bool, len(f.Suppressions))
+	for i := range f.Suppressions {
+		r := f.Suppressions[i]
+		if err := r.compile(); err != nil {
+			label := r.ID
+			if label == "" {
+				label = fmt.Sprintf("#%d", i+1)
+			}
+			return nil, fmt.Errorf("suppressions %s: rule %s: %w", source, label, err)
+		}
+		if seen[r.ID] {
+			return nil, fmt.Errorf("suppressions %s: rule %s: duplicate id", source, r.ID)
+		}
+		seen[r.ID] = true
+		l.rules = append(l.rules, r)
+	}
+	return l, nil
+}
+
+func (r *Rule) compile() error {
+	switch {
+	case r.ID == "":
+		return fmt.Errorf("missing id")
+	case r.Owner == "":
+		return fmt.Errorf("missing owner")
+	case r.Reason == "":
+		return fmt.Errorf("missing reason")
+	}
+
+	matchers := 0
+	for key, pat := range map[string]string{
+		"exe_path":     r.ExePath,
+		"process_name": r.ProcessName,
+		"company":      r.Company,
+		"user":         r.User,
+		"role":         r.Role,
+	} {
+		if pat == "" {
+			continue
+		}
+		matchers++
+		if !shared.ValidGlob(pat) {
+			return fmt.Errorf("%s: invalid pattern %q", key, pat)
+		}
+	}
+
+	for _, cidr := range r.RemoteCIDRs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("remote_cidrs: invalid CIDR %q", cidr)
+		}
+		r.remoteNets = append(r.remoteNets, network)
+	}
+	for _, ports := range [][]int{r.RemotePorts, r.ListenerPorts} {
+		for _, p := range ports {
+			if p <= 0 || p > 65535 {
+				return fmt.Errorf("invalid port %d", p)
+			}
+		}
+	}
+	if len(r.RemoteCIDRs) > 0 || len(r.RemotePorts) > 0 {
+		matchers++
+	}
+	if len(r.ListenerPorts) > 0 {
+		matchers++
+	}
+
+	if matchers == 0 {
+		return fmt.Errorf("no matchers set")
+	}
+	return nil
+}
+
+// Rules returns the rules in evaluation order.
+func (l *List) Rules() []Rule {
+	return append([]Rule(nil), l.rules...)
+}
+
+func (l *List) Match(c *shared.Candidate, now time.Time) *shared.Suppression {
+	for i := range l.rules {
+		r := &l.rules[i]
+		if r.Expires != nil && !now.Before(*r.Expires) {
+			continue
+		}
+		if !r.matches(c) {
+			continue
+		}
+		return &shared.Suppression{
+			Rule:    r.ID,
+			Owner:   r.Owner,
+			Reason:  r.Reason,
+			Expires: r.Expires,
+		}
+	}
+	return nil
+}
+
+func (r *Rule) matches(c *shared.Candidate) bool {
+	p := c.Proc
+	if p == nil {
+		return false
+	}
+	if !shared.MatchGlob(r.ExePath, p.ExePath) ||
+		!shared.MatchGlob(r.ProcessName, p.Name) ||
+		!shared.MatchGlob(r.Company, p.Company) ||
+		!shared.MatchGlob(r.User, p.UserName) ||
+		!shared.MatchGlob(r.Role, c.Role) {
+		return false
+	}
+	if (len(r.remoteNets) > 0 || len(r.RemotePorts) > 0) && !r.remotesMatch(c) {
+		return false
+	}
+	if len(r.ListenerPorts) > 0 && !r.listenersMatch(c) {
+		return false
+	}
+	return true
+}
+
+func (r *Rule) remotesMatch(c *shared.Candidate) bool {
+	seen := 0
+	check := func(addr string, port int) bool {
+		if addr == "" || shared.IsWildcardIP(addr) || shared.IsLoopbackIP(addr) {
+			return true
+		}
+		seen++
+		return r.remoteAllowed(addr, port)
+	}
+
+	for _, cn := range c.Conns {
+		if !check(cn.RemoteAddress, cn.RemotePort) {
+			return false
+		}
+	}
+	for _, u := range c.UDPConns {
+		if !check(u.RemoteAddress, u.RemotePort) {
+			return false
+		}
+	}
+	return seen > 0
+}
+
+func (r *Rule) remoteAllowed(addr string, port int) bool {
+	if len(r.RemotePorts) > 0 && !containsPort(r.RemotePorts, port) {
+		return false
+	}
+	if len(r.remoteNets) == 0 {
+		return true
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range r.remoteNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) listenersMatch(c *shared.Candidate) bool {
+	seen := 0
+	for _, l := range c.Listeners {
+		seen++
+		if !containsPort(r.ListenerPorts, l.LocalPort) {
+			return false
+		}
+	}
+	for _, u := range c.UDPListeners {
+		seen++
+		if !containsPort(r.ListenerPorts, u.LocalPort) {
+			return false
+		}
+	}
+	return seen > 0
+}
+
+func containsPort(ports []int, p int) bool {
+	for _, x := range ports {
+		if x == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,22 @@
+//go:build linux
+// +build linux
+
+package telemetry
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// KillProcess terminates the process with the given PID.
+func KillProcess(pid int) error {
+	if pid <= 0 {
+		return fmt.Errorf("invalid pid: %d", pid)
+	}
+
+	if err := syscall.Kill(pid, syscall.SIGKILL); err != nil {
+		return fmt.Errorf("terminate process: %w", err)
+	}
+
+	return nil
+}
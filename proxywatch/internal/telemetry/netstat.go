@@ -11,6 +11,16 @@ import (
 	"proxywatch/internal/shared"
 )
 
+// socketOwners is empty on Windows: the IP helper tables carry the owning
+// PID themselves.
+type socketOwners struct{}
+
+func loadSocketOwners() socketOwners { return socketOwners{} }
+
+func getTCPTable(socketOwners) ([]shared.ListenerInfo, []shared.ConnectionInfo, error) {
+	return GetTCPTable()
+}
+
 func GetTCPTable() ([]shared.ListenerInfo, []shared.ConnectionInfo, error) {
 	l4, c4, err := getTCPTableForFamily(shared.AF_INET)
 	if err != nil {
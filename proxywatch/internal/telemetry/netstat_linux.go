@@ -0,0 +1,102 @@
+//go:build linux
+// +build linux
+
+package telemetry
+
+import (
+	"fmt"
+
+	"proxywatch/internal/shared"
+)
+
+// socketOwners maps socket inodes to PIDs. Collect builds it once and
+// shares it between the TCP and UDP tables and every burst resample.
+type socketOwners map[uint64]int
+
+func loadSocketOwners() socketOwners {
+	return socketInodeOwners()
+}
+
+func GetTCPTable() ([]shared.ListenerInfo, []shared.ConnectionInfo, error) {
+	return getTCPTable(loadSocketOwners())
+}
+
+func getTCPTable(owners socketOwners) ([]shared.ListenerInfo, []shared.ConnectionInfo, error) {
+	l4, c4, err := getTCPTableForFamily("tcp", owners)
+	if err != nil {
+		return nil, nil, err
+	}
+	l6, c6, err := getTCPTableForFamily("tcp6", owners)
+	if err != nil {
+		return l4, c4, nil
+	}
+	return append(l4, l6...), append(c4, c6...), nil
+}
+
+func getTCPTableForFamily(name string, owners socketOwners) ([]shared.ListenerInfo, []shared.ConnectionInfo, error) {
+	rows, err := readProcNet(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read /proc/net/%s: %w", name, err)
+	}
+
+	var listeners []shared.ListenerInfo
+	var conns []shared.ConnectionInfo
+
+	for _, r := range rows {
+		// TIME_WAIT sockets have no owning inode; keep them under PID 0 like
+		// the Windows table does.
+		pid := owners[r.Inode]
+		state := linuxTCPStateToString(r.State)
+
+		if state == "LISTENING" {
+			listeners = append(listeners, shared.ListenerInfo{
+				Pid:          pid,
+				LocalAddress: r.LocalAddr,
+				LocalPort:    r.LocalPort,
+				State:        state,
+			})
+		} else {
+			conns = append(conns, shared.ConnectionInfo{
+				Pid:           pid,
+				LocalAddress:  r.LocalAddr,
+				LocalPort:     r.LocalPort,
+				RemoteAddress: r.RemoteAddr,
+				RemotePort:    r.RemotePort,
+				State:         state,
+			})
+		}
+	}
+
+	return listeners, conns, nil
+}
+
+// linuxTCPStateToString maps include/net/tcp_states.h values onto the state
+// names used by the Windows collector so the classifier sees one vocabulary.
+func linuxTCPStateToString(s uint64) string {
+	switch s {
+	case 0x01:
+		return "ESTABLISHED"
+	case 0x02:
+		return "SYN_SENT"
+	case 0x03:
+		return "SYN_RECEIVED"
+	case 0x04:
+		return "FIN_WAIT_1"
+	case 0x05:
+		return "FIN_WAIT_2"
+	case 0x06:
+		return "TIME_WAIT"
+	case 0x07:
+		return "CLOSED"
+	case 0x08:
+		return "CLOSE_WAIT"
+	case 0x09:
+		return "LAST_ACK"
+	case 0x0A:
+		return "LISTENING"
+	case 0x0B:
+		return "CLOSING"
+	default:
+		return "UNKNOWN"
+	}
+}
@@ -0,0 +1,212 @@
+//go:build linux
+// +build linux
+
+package telemetry
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"proxywatch/internal/shared"
+)
+
+// clockTicks is USER_HZ, which is 100 on every mainstream Linux ABI.
+const clockTicks = 100
+
+func GetProcessInfoMap() (map[int]*shared.ProcessInfo, error) {
+	pids := listPIDs()
+	if len(pids) == 0 {
+		return nil, fmt.Errorf("no processes found under %s", procRoot)
+	}
+
+	procs := make(map[int]*shared.ProcessInfo, len(pids))
+
+	now := time.Now().UTC()
+	for _, pid := range pids {
+		dir := filepath.Join(procRoot, strconv.Itoa(pid))
+
+		pi := &shared.ProcessInfo{Pid: pid}
+		if !fillStat(dir, pi) {
+			// process exited between listing and reading
+			continue
+		}
+
+		meta, metaOK := getCachedMeta(pid, now)
+		if metaOK {
+			applyCachedMeta(pi, meta)
+		}
+
+		fillMemoryLinux(dir, pi)
+		fillIOCountersLinux(dir, pi)
+		if !metaOK {
+			fillUserLinux(dir, pi)
+			fillExePathLinux(dir, pi)
+			cacheMeta(pid, pi, now)
+		}
+
+		procs[pid] = pi
+	}
+
+	return procs, nil
+}
+
+/* --- helpers --- */
+
+func fillStat(dir string, pi *shared.ProcessInfo) bool {
+	data, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return false
+	}
+
+	// comm may contain spaces and parentheses, so split on the last ')'.
+	open := bytes.IndexByte(data, '(')
+	end := bytes.LastIndexByte(data, ')')
+	if open < 0 || end < open {
+		return false
+	}
+	pi.Name = string(data[open+1 : end])
+
+	fields := strings.Fields(string(data[end+1:]))
+	if len(fields) < 13 {
+		return true
+	}
+
+	pi.Status = linuxProcState(fields[0])
+	if ppid, err := strconv.Atoi(fields[1]); err == nil {
+		pi.ParentPid = ppid
+	}
+	if sid, err := strconv.ParseUint(fields[3], 10, 32); err == nil {
+		pi.SessionID = uint32(sid)
+	}
+
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	pi.CpuTime = time.Duration(utime+stime) * time.Second / clockTicks
+
+	return true
+}
+
+func fillMemoryLinux(dir string, pi *shared.ProcessInfo) {
+	data, err := os.ReadFile(filepath.Join(dir, "statm"))
+	if err != nil {
+		return
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		return
+	}
+	pages, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return
+	}
+	pi.MemUsage = pages * uint64(os.Getpagesize())
+}
+
+func fillIOCountersLinux(dir string, pi *shared.ProcessInfo) {
+	f, err := os.Open(filepath.Join(dir, "io"))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	// rchar/wchar include socket traffic, which matches the semantics of
+	// the Windows transfer counters better than read_bytes/write_bytes.
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		key, val, ok := strings.Cut(sc.Text(), ":")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseUint(strings.TrimSpace(val), 10, 64)
+		if err != nil {
+			continue
+		}
+		switch key {
+		case "rchar":
+			pi.IOReadBytes = n
+		case "wchar":
+			pi.IOWriteBytes = n
+		}
+	}
+}
+
+func fillUserLinux(dir string, pi *shared.ProcessInfo) {
+	f, err := os.Open(filepath.Join(dir, "status"))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := sc.Text()
+		if !strings.HasPrefix(line, "Uid:") {
+			continue
+		}
+		fields := strings.Fields(line[len("Uid:"):])
+		if len(fields) == 0 {
+			return
+		}
+		uid := fields[0]
+		if u, err := user.LookupId(uid); err == nil {
+			pi.UserName = u.Username
+		} else {
+			pi.UserName = uid
+		}
+		return
+	}
+}
+
+func fillExePathLinux(dir string, pi *shared.ProcessInfo) {
+	if pi.ExePath != "" {
+		return
+	}
+	path, err := os.Readlink(filepath.Join(dir, "exe"))
+	if err != nil {
+		return
+	}
+	pi.ExePath = strings.TrimSuffix(path, " (deleted)")
+}
+
+func getCachedMeta(pid int, now time.Time) (shared.ProcessMeta, bool) {
+	return shared.ProcMetaCache.Get(pid, now)
+}
+
+func applyCachedMeta(pi *shared.ProcessInfo, meta shared.ProcessMeta) {
+	pi.UserName = meta.UserName
+	pi.ExePath = meta.ExePath
+}
+
+func cacheMeta(pid int, pi *shared.ProcessInfo, now time.Time) {
+	shared.ProcMetaCache.Set(pid, shared.ProcessMeta{
+		UserName:  pi.UserName,
+		ExePath:   pi.ExePath,
+		FetchedAt: now,
+	})
+}
+
+func linuxProcState(s string) string {
+	switch s {
+	case "R":
+		return "Running"
+	case "S":
+		return "Sleeping"
+	case "D":
+		return "Waiting"
+	case "Z":
+		return "Zombie"
+	case "T", "t":
+		return "Stopped"
+	case "I":
+		return "Idle"
+	default:
+		return s
+	}
+}
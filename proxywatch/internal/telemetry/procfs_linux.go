@@ -0,0 +1,159 @@
+//go:build linux
+// +build linux
+
+package telemetry
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const procRoot = "/proc"
+
+// procNetEntry is one parsed row of /proc/net/{tcp,tcp6,udp,udp6}.
+type procNetEntry struct {
+	LocalAddr  string
+	LocalPort  int
+	RemoteAddr string
+	RemotePort int
+	State      uint64
+	Inode      uint64
+}
+
+func readProcNet(name string) ([]procNetEntry, error) {
+	f, err := os.Open(filepath.Join(procRoot, "net", name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var out []procNetEntry
+	sc := bufio.NewScanner(f)
+	first := true
+	for sc.Scan() {
+		if first {
+			first = false
+			continue
+		}
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 10 {
+			continue
+		}
+
+		lip, lp, err := parseProcNetAddr(fields[1])
+		if err != nil {
+			continue
+		}
+		rip, rp, err := parseProcNetAddr(fields[2])
+		if err != nil {
+			continue
+		}
+		state, err := strconv.ParseUint(fields[3], 16, 8)
+		if err != nil {
+			continue
+		}
+		inode, err := strconv.ParseUint(fields[9], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		out = append(out, procNetEntry{
+			LocalAddr:  lip,
+			LocalPort:  lp,
+			RemoteAddr: rip,
+			RemotePort: rp,
+			State:      state,
+			Inode:      inode,
+		})
+	}
+	return out, sc.Err()
+}
+
+// parseProcNetAddr decodes "0100007F:1F90" style addresses. The kernel prints
+// each 32-bit word of the address in host (little-endian) byte order.
+func parseProcNetAddr(s string) (string, int, error) {
+	host, port, ok := strings.Cut(s, ":")
+	if !ok {
+		return "", 0, fmt.Errorf("malformed address %q", s)
+	}
+
+	raw, err := hex.DecodeString(host)
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return "", 0, fmt.Errorf("malformed address %q", s)
+	}
+	for i := 0; i+4 <= len(raw); i += 4 {
+		raw[i], raw[i+1], raw[i+2], raw[i+3] = raw[i+3], raw[i+2], raw[i+1], raw[i]
+	}
+
+	p, err := strconv.ParseUint(port, 16, 16)
+	if err != nil {
+		return "", 0, fmt.Errorf("malformed port %q", s)
+	}
+
+	ip := net.IP(raw)
+	if v4 := ip.To4(); v4 != nil && len(raw) == net.IPv6len && isV4Mapped(raw) {
+		ip = v4
+	}
+	return ip.String(), int(p), nil
+}
+
+func isV4Mapped(raw []byte) bool {
+	for i := 0; i < 10; i++ {
+		if raw[i] != 0 {
+			return false
+		}
+	}
+	return raw[10] == 0xff && raw[11] == 0xff
+}
+
+// socketInodeOwners maps socket inodes to the PID holding them by walking
+// /proc/<pid>/fd. Processes we cannot inspect are silently skipped.
+func socketInodeOwners() map[uint64]int {
+	owners := make(map[uint64]int)
+	for _, pid := range listPIDs() {
+		fdDir := filepath.Join(procRoot, strconv.Itoa(pid), "fd")
+		entries, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			link, err := os.Readlink(filepath.Join(fdDir, e.Name()))
+			if err != nil || !strings.HasPrefix(link, "socket:[") {
+				continue
+			}
+			inode, err := strconv.ParseUint(strings.TrimSuffix(link[len("socket:["):], "]"), 10, 64)
+			if err != nil {
+				continue
+			}
+			if _, ok := owners[inode]; !ok {
+				owners[inode] = pid
+			}
+		}
+	}
+	return owners
+}
+
+func listPIDs() []int {
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return nil
+	}
+	pids := make([]int, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || pid <= 0 {
+			continue
+		}
+		pids = append(pids, pid)
+	}
+	return pids
+}
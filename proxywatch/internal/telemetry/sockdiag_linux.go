@@ -0,0 +1,180 @@
+//go:build linux
+// +build linux
+
+package telemetry
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+
+	"proxywatch/internal/shared"
+)
+
+// Layouts from linux/inet_diag.h and linux/tcp.h.
+const (
+	sizeofInetDiagSockID = 48
+	sizeofInetDiagReqV2  = 8 + sizeofInetDiagSockID
+	sizeofInetDiagMsg    = 4 + sizeofInetDiagSockID + 20
+
+	inetDiagInfo = 2
+
+	tcpInfoRTTOffset           = 68
+	tcpInfoTotalRetransOffset  = 100
+	tcpInfoBytesAckedOffset    = 120
+	tcpInfoBytesReceivedOffset = 128
+	tcpInfoBytesSentOffset     = 200
+)
+
+type diagKey struct {
+	LocalAddr  string
+	LocalPort  int
+	RemoteAddr string
+	RemotePort int
+}
+
+type diagStats struct {
+	BytesSent     uint64
+	BytesReceived uint64
+	RTTMicros     uint32
+	Retransmits   uint32
+}
+
+// AttachSockDiag annotates every TCP connection in snap with the byte,
+// RTT and retransmit counters the kernel keeps in tcp_info.
+func AttachSockDiag(snap *shared.Snapshot) error {
+	stats := make(map[diagKey]diagStats)
+	for _, family := range []uint8{unix.AF_INET, unix.AF_INET6} {
+		if err := dumpTCPInfo(family, stats); err != nil {
+			return fmt.Errorf("sock_diag: %w", err)
+		}
+	}
+
+	for i := range snap.Connections {
+		cn := &snap.Connections[i]
+		st, ok := stats[diagKey{cn.LocalAddress, cn.LocalPort, cn.RemoteAddress, cn.RemotePort}]
+		if !ok {
+			continue
+		}
+		cn.BytesSent = st.BytesSent
+		cn.BytesReceived = st.BytesReceived
+		cn.RTTMicros = st.RTTMicros
+		cn.Retransmits = st.Retransmits
+	}
+	return nil
+}
+
+func dumpTCPInfo(family uint8, out map[diagKey]diagStats) error {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, unix.NETLINK_SOCK_DIAG)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	req := make([]byte, unix.NLMSG_HDRLEN+sizeofInetDiagReqV2)
+	ne := binary.NativeEndian
+	ne.PutUint32(req[0:], uint32(len(req)))
+	ne.PutUint16(req[4:], unix.SOCK_DIAG_BY_FAMILY)
+	ne.PutUint16(req[6:], unix.NLM_F_REQUEST|unix.NLM_F_DUMP)
+	body := req[unix.NLMSG_HDRLEN:]
+	body[0] = family
+	body[1] = unix.IPPROTO_TCP
+	body[2] = 1 << (inetDiagInfo - 1)
+	ne.PutUint32(body[4:], 0xffffffff) // all states
+
+	if err := unix.Sendto(fd, req, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return err
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			switch m.Header.Type {
+			case unix.NLMSG_DONE:
+				return nil
+			case unix.NLMSG_ERROR:
+				if len(m.Data) >= 4 {
+					if code := int32(ne.Uint32(m.Data)); code != 0 {
+						return syscall.Errno(-code)
+					}
+				}
+				return nil
+			}
+			parseDiagMsg(m.Data, out)
+		}
+	}
+}
+
+func parseDiagMsg(data []byte, out map[diagKey]diagStats) {
+	if len(data) < sizeofInetDiagMsg {
+		return
+	}
+
+	family := data[0]
+	id := data[4 : 4+sizeofInetDiagSockID]
+	key := diagKey{
+		LocalPort:  int(binary.BigEndian.Uint16(id[0:])),
+		RemotePort: int(binary.BigEndian.Uint16(id[2:])),
+		LocalAddr:  diagAddr(family, id[4:20]),
+		RemoteAddr: diagAddr(family, id[20:36]),
+	}
+
+	var st diagStats
+	st.Retransmits = uint32(data[3])
+
+	ne := binary.NativeEndian
+	attrs := data[sizeofInetDiagMsg:]
+	for len(attrs) >= unix.SizeofRtAttr {
+		alen := int(ne.Uint16(attrs[0:]))
+		atype := ne.Uint16(attrs[2:])
+		if alen < unix.SizeofRtAttr || alen > len(attrs) {
+			break
+		}
+		if atype == inetDiagInfo {
+			info := attrs[unix.SizeofRtAttr:alen]
+			if len(info) >= tcpInfoTotalRetransOffset+4 {
+				st.RTTMicros = ne.Uint32(info[tcpInfoRTTOffset:])
+				st.Retransmits = ne.Uint32(info[tcpInfoTotalRetransOffset:])
+			}
+			if len(info) >= tcpInfoBytesReceivedOffset+8 {
+				st.BytesSent = ne.Uint64(info[tcpInfoBytesAckedOffset:])
+				st.BytesReceived = ne.Uint64(info[tcpInfoBytesReceivedOffset:])
+			}
+			// bytes_sent (4.19+) also counts unacknowledged data
+			if len(info) >= tcpInfoBytesSentOffset+8 {
+				if sent := ne.Uint64(info[tcpInfoBytesSentOffset:]); sent > st.BytesSent {
+					st.BytesSent = sent
+				}
+			}
+		}
+		next := (alen + unix.RTA_ALIGNTO - 1) &^ (unix.RTA_ALIGNTO - 1)
+		if next > len(attrs) {
+			break
+		}
+		attrs = attrs[next:]
+	}
+
+	out[key] = st
+}
+
+func diagAddr(family uint8, raw []byte) string {
+	if family == unix.AF_INET {
+		return net.IP(raw[:4]).String()
+	}
+	ip := net.IP(append([]byte(nil), raw[:16]...))
+	if v4 := ip.To4(); v4 != nil {
+		return v4.String()
+	}
+	return ip.String()
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package telemetry
+
+import (
+	"errors"
+
+	"proxywatch/internal/shared"
+)
+
+func AttachSockDiag(snap *shared.Snapshot) error {
+	return errors.New("sock_diag is only available on Linux")
+}
@@ -0,0 +1,81 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"sync/atomic"
+
+	"proxywatch/internal/shared"
+)
+
+// LiveSource collects snapshots from the running host. With SockDiag set,
+// TCP connections are annotated with kernel counters (Linux only). If the
+// kernel refuses (no CAP_NET_ADMIN, no inet_diag), that is reported once and
+// snapshots carry no counters.
+type LiveSource struct {
+	SockDiag bool
+
+	diagFailed atomic.Bool
+	burst      atomic.Pointer[shared.BurstSettings]
+}
+
+func init() {
+	shared.RegisterSource("live", func(arg string) (shared.Source, error) {
+		switch arg {
+		case "":
+			return &LiveSource{}, nil
+		case "sockdiag":
+			return &LiveSource{SockDiag: true}, nil
+		default:
+			return nil, fmt.Errorf("live source: unknown option %q (want sockdiag)", arg)
+		}
+	})
+}
+
+func (*LiveSource) Name() string { return "live" }
+
+// SetBurstSettings replaces the TCP table resampling used by the next Collect.
+func (l *LiveSource) SetBurstSettings(b shared.BurstSettings) {
+	l.burst.Store(&b)
+}
+
+func (l *LiveSource) burstSettings() shared.BurstSettings {
+	if b := l.burst.Load(); b != nil {
+		return *b
+	}
+	return shared.DefaultBurstSettings()
+}
+
+func (l *LiveSource) Capabilities() shared.SourceCapabilities {
+	switch runtime.GOOS {
+	case "windows", "linux":
+		return shared.SourceCapabilities{
+			UDP:           true,
+			UDPPeers:      runtime.GOOS == "linux",
+			IOCounters:    true,
+			PerConnection: true,
+			ConnCounters:  l.SockDiag && runtime.GOOS == "linux" && !l.diagFailed.Load(),
+		}
+	default:
+		return shared.SourceCapabilities{}
+	}
+}
+
+func (l *LiveSource) Collect(ctx context.Context) (*shared.Snapshot, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	snap, err := Collect(l.burstSettings())
+	if err != nil {
+		return nil, err
+	}
+	if l.SockDiag && !l.diagFailed.Load() {
+		if err := AttachSockDiag(snap); err != nil {
+			l.diagFailed.Store(true)
+			fmt.Fprintf(os.Stderr, "warning: %v; continuing without connection counters\n", err)
+		}
+	}
+	return snap, nil
+}
@@ -1,5 +1,5 @@
-//go:build windows
-// +build windows
+//go:build windows || linux
+// +build windows linux
 
 package telemetry
 
@@ -10,15 +10,18 @@ import (
 	"proxywatch/internal/shared"
 )
 
-func Collect() (*shared.Snapshot, error) {
-	listeners, conns, err := GetTCPTable()
+// Collect takes one snapshot of the host, resampling the TCP table as b
+// directs.
+func Collect(b shared.BurstSettings) (*shared.Snapshot, error) {
+	owners := loadSocketOwners()
+	listeners, conns, err := getTCPTable(owners)
 	if err != nil {
 		return nil, fmt.Errorf("netstat: %w", err)
 	}
 
-	samples := burstSampleCount(len(listeners), len(conns))
+	samples := burstSampleCount(b, len(listeners), len(conns))
 	if samples > 1 {
-		listeners, conns = burstCapture(listeners, conns, samples)
+		listeners, conns = burstCapture(owners, listeners, conns, samples, b.Sleep)
 	}
 
 	procs, err := GetProcessInfoMap()
@@ -26,7 +29,7 @@ func Collect() (*shared.Snapshot, error) {
 		return nil, fmt.Errorf("process: %w", err)
 	}
 
-	udpListeners, _ := GetUDPTable()
+	udpListeners, udpConns, _ := getUDPSockets(owners)
 
 	return &shared.Snapshot{
 		Timestamp:    time.Now().UTC(),
@@ -34,13 +37,16 @@ func Collect() (*shared.Snapshot, error) {
 		Listeners:    listeners,
 		Connections:  conns,
 		UDPListeners: udpListeners,
+		UDPConns:     udpConns,
 	}, nil
 }
 
 func burstCapture(
+	owners socketOwners,
 	baseListeners []shared.ListenerInfo,
 	baseConns []shared.ConnectionInfo,
 	samples int,
+	sleep time.Duration,
 ) ([]shared.ListenerInfo, []shared.ConnectionInfo) {
 
 	listenerMap := make(map[shared.ListenerKey]shared.ListenerInfo, len(baseListeners))
@@ -50,8 +56,8 @@ func burstCapture(
 	mergeConns(connMap, baseConns)
 
 	for i := 1; i < samples; i++ {
-		time.Sleep(shared.BurstSleep)
-		listeners, conns, err := GetTCPTable()
+		time.Sleep(sleep)
+		listeners, conns, err := getTCPTable(owners)
 		if err != nil {
 			continue
 		}
@@ -72,15 +78,15 @@ func burstCapture(
 	return outListeners, outConns
 }
 
-func burstSampleCount(listenerCount, connCount int) int {
+func burstSampleCount(b shared.BurstSettings, listenerCount, connCount int) int {
 	total := listenerCount + connCount
 	switch {
-	case total <= shared.BurstIdleConnThreshold:
-		return shared.BurstSamplesMin
-	case total <= shared.BurstModerateConnThreshold:
-		return shared.BurstSamplesMid
+	case total <= b.IdleConnThreshold:
+		return b.SamplesMin
+	case total <= b.ModerateConnThreshold:
+		return b.SamplesMid
 	default:
-		return shared.BurstSamplesMax
+		return b.SamplesMax
 	}
 }
 
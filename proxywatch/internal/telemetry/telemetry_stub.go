@@ -1,5 +1,5 @@
-//go:build !windows
-// +build !windows
+//go:build !windows && !linux
+// +build !windows,!linux
 
 package telemetry
 
@@ -9,10 +9,10 @@ import (
 	"proxywatch/internal/shared"
 )
 
-func Collect() (*shared.Snapshot, error) {
-	return nil, errors.New("telemetry collection is only supported on Windows")
+func Collect(shared.BurstSettings) (*shared.Snapshot, error) {
+	return nil, errors.New("telemetry collection is only supported on Windows and Linux")
 }
 
 func KillProcess(pid int) error {
-	return errors.New("process termination is only supported on Windows")
+	return errors.New("process termination is only supported on Windows and Linux")
 }
@@ -11,6 +11,18 @@ import (
 	"proxywatch/internal/shared"
 )
 
+// GetUDPSockets returns bound UDP endpoints. GetExtendedUdpTable carries no
+// remote endpoint, so connected sockets cannot be told apart on Windows and
+// everything is reported as a listener.
+func GetUDPSockets() ([]shared.UDPListenerInfo, []shared.UDPConnInfo, error) {
+	listeners, err := GetUDPTable()
+	return listeners, nil, err
+}
+
+func getUDPSockets(socketOwners) ([]shared.UDPListenerInfo, []shared.UDPConnInfo, error) {
+	return GetUDPSockets()
+}
+
 func GetUDPTable() ([]shared.UDPListenerInfo, error) {
 	l4, err := getUDPTableForFamily(shared.AF_INET)
 	if err != nil {
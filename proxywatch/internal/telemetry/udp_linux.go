@@ -0,0 +1,62 @@
+//go:build linux
+// +build linux
+
+package telemetry
+
+import (
+	"fmt"
+
+	"proxywatch/internal/shared"
+)
+
+// GetUDPSockets splits /proc/net/udp{,6} into unconnected sockets (reported
+// as listeners) and connected sockets, which carry their remote peer.
+func GetUDPSockets() ([]shared.UDPListenerInfo, []shared.UDPConnInfo, error) {
+	return getUDPSockets(loadSocketOwners())
+}
+
+func getUDPSockets(owners socketOwners) ([]shared.UDPListenerInfo, []shared.UDPConnInfo, error) {
+	l4, c4, err := getUDPTableForFamily("udp", owners)
+	if err != nil {
+		return nil, nil, err
+	}
+	l6, c6, err := getUDPTableForFamily("udp6", owners)
+	if err != nil {
+		return l4, c4, nil
+	}
+	return append(l4, l6...), append(c4, c6...), nil
+}
+
+func GetUDPTable() ([]shared.UDPListenerInfo, error) {
+	listeners, _, err := GetUDPSockets()
+	return listeners, err
+}
+
+func getUDPTableForFamily(name string, owners socketOwners) ([]shared.UDPListenerInfo, []shared.UDPConnInfo, error) {
+	rows, err := readProcNet(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read /proc/net/%s: %w", name, err)
+	}
+
+	var listeners []shared.UDPListenerInfo
+	var conns []shared.UDPConnInfo
+	for _, r := range rows {
+		pid := owners[r.Inode]
+		if r.RemotePort == 0 && shared.IsWildcardIP(r.RemoteAddr) {
+			listeners = append(listeners, shared.UDPListenerInfo{
+				Pid:          pid,
+				LocalAddress: r.LocalAddr,
+				LocalPort:    r.LocalPort,
+			})
+			continue
+		}
+		conns = append(conns, shared.UDPConnInfo{
+			Pid:           pid,
+			LocalAddress:  r.LocalAddr,
+			LocalPort:     r.LocalPort,
+			RemoteAddress: r.RemoteAddr,
+			RemotePort:    r.RemotePort,
+		})
+	}
+	return listeners, conns, nil
+}
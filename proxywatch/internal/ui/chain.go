@@ -0,0 +1,87 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"proxywatch/internal/shared"
+)
+
+func DrawChain(app *shared.AppState) {
+	s := app.Screen
+	s.Clear()
+
+	w, h := s.Size()
+	nowUTC := time.Now().UTC()
+
+	PutString(s, 0, 0,
+		TruncateToWidth(fmt.Sprintf("UTC: %s", nowUTC.Format("2006-01-02 15:04:05")), w),
+	)
+
+	var cand *shared.Candidate
+	for i := range app.Candidates {
+		if app.Candidates[i].Proc.Pid == app.InspectPID {
+			cand = &app.Candidates[i]
+			break
+		}
+	}
+
+	if cand == nil || cand.Chain == nil {
+		PutString(s, 0, 2, "No loopback chain for this process. Press ESC.")
+		PutString(s, 0, h-1, "ESC return | q quit")
+		return
+	}
+
+	chain := cand.Chain
+	y := 2
+	title := fmt.Sprintf(" Loopback chain %s ", chain.ID)
+	sep := strings.Repeat("─", MinInt(len(title), w))
+
+	PutString(s, 0, y, sep)
+	y++
+	PutString(s, 0, y, TruncateToWidth(title, w))
+	y++
+	PutString(s, 0, y, sep)
+	y += 2
+
+	for i, hop := range chain.Hops {
+		if y >= h-3 {
+			break
+		}
+		if i > 0 {
+			PutString(s, 6, y, fmt.Sprintf("│ 127.0.0.1:%d", hop.Port))
+			y++
+		}
+
+		marker := ""
+		if i == chain.Position {
+			marker = "  <- inspected"
+		}
+		role := ""
+		if idx := FindIndexByPID(app.Candidates, hop.Pid); idx >= 0 {
+			role = "  " + app.Candidates[idx].Role
+		}
+		line := fmt.Sprintf("[%d] %s (PID %d)%s%s", i+1, hop.Name, hop.Pid, role, marker)
+		PutString(s, 2, y, TruncateToWidth(line, w-2))
+		y++
+	}
+
+	if len(chain.Egress) == 0 {
+		PutString(s, 6, y, "└ no egress from last hop")
+	} else {
+		for i, ep := range chain.Egress {
+			if y >= h-2 {
+				break
+			}
+			prefix := "├"
+			if i == len(chain.Egress)-1 {
+				prefix = "└"
+			}
+			PutString(s, 6, y, TruncateToWidth(fmt.Sprintf("%s egress %s", prefix, ep), w-6))
+			y++
+		}
+	}
+
+	PutString(s, 0, h-1, "ESC return | q quit")
+}
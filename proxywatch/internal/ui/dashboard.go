@@ -18,14 +18,24 @@ func DrawDashboard(app *shared.AppState) {
 		TruncateToWidth(fmt.Sprintf("UTC: %s", nowUTC.Format("2006-01-02 15:04:05")), w),
 	)
 
-	PutString(s, 0, 2,
-		TruncateToWidth("Use UP/DOWN arrows | ENTER inspect | q quit", w),
-	)
+	help := "Use UP/DOWN arrows | ENTER inspect | s suppressed | q quit"
+	if app.Reloader != nil {
+		help = "Use UP/DOWN arrows | ENTER inspect | s suppressed | r reload config | q quit"
+	}
+	PutString(s, 0, 2, TruncateToWidth(help, w))
 
 	if app.LastError != "" {
 		PutString(s, 0, 3, TruncateToWidth("Status: "+app.LastError, w))
 	}
 
+	if app.SuppressedCount > 0 {
+		state := "hidden"
+		if app.ShowSuppressed {
+			state = "shown, marked ~"
+		}
+		PutString(s, 0, 4, TruncateToWidth(fmt.Sprintf("Suppressed: %d (%s)", app.SuppressedCount, state), w))
+	}
+
 	y := 5
 	if len(app.Candidates) == 0 {
 		PutString(s, 0, y, "no candidates matching filters")
@@ -49,8 +59,15 @@ func DrawDashboard(app *shared.AppState) {
 			arrow = ">"
 		}
 
-		name := shared.TrimName(c.Proc.Name, 22)
-		udpInt, udpExt, udpLo := shared.UDPScopeCounts(c.UDPListeners)
+		name := c.Proc.Name
+		if c.Suppressed != nil {
+			name = "~" + name
+		}
+		if len(c.IOC) > 0 {
+			name = "!" + name
+		}
+		name = shared.TrimName(name, 22)
+		udpInt, udpExt, udpLo := app.Zones.UDPScopeCounts(c.UDPListeners)
 		intExt := fmt.Sprintf("%d/%d/%d",
 			c.OutInternal+udpInt,
 			c.OutExternal+udpExt,
@@ -46,6 +46,34 @@ func DrawInspector(app *shared.AppState) {
 	PutString(s, 0, y, fmt.Sprintf("Role:  %s", cand.Role))
 	y++
 	PutString(s, 0, y, fmt.Sprintf("Active: %v", cand.ActiveProxying))
+	y++
+	PutString(s, 0, y, fmt.Sprintf("Score: %d  Confidence: %d  (press w for why)", cand.Score, cand.Confidence))
+	y++
+	if cand.Chain != nil {
+		PutString(s, 0, y,
+			TruncateToWidth(
+				fmt.Sprintf("Chain:  %s (hop %d/%d, press c)", cand.Chain.ID, cand.Chain.Position+1, len(cand.Chain.Hops)),
+				w,
+			),
+		)
+	}
+	if sup := cand.Suppressed; sup != nil {
+		y++
+		line := fmt.Sprintf("Suppressed: %s (owner %s) - %s", sup.Rule, sup.Owner, sup.Reason)
+		if sup.Expires != nil {
+			line += ", expires " + sup.Expires.UTC().Format("2006-01-02 15:04")
+		}
+		PutString(s, 0, y, TruncateToWidth(line, w))
+	}
+	for _, hit := range cand.IOC {
+		y++
+		PutString(s, 0, y,
+			TruncateToWidth(
+				fmt.Sprintf("IOC:   %s %s (%s, %s %s)", hit.List, hit.Indicator, hit.Kind, hit.Where, hit.Observed),
+				w,
+			),
+		)
+	}
 	y += 2
 
 	user := cand.Proc.UserName
@@ -97,9 +125,9 @@ func DrawInspector(app *shared.AppState) {
 	tcpOutbound := cand.OutTotal
 	tcpListeners := len(cand.Listeners)
 	udpListeners := len(cand.UDPListeners)
-	udpInbound := 0
-	udpOutbound := 0
-	udpEstablished := 0
+	udpInbound := cand.UDPInbound
+	udpOutbound := cand.UDPOutbound
+	udpEstablished := len(cand.UDPConns)
 
 	PutString(s, 2, y, fmt.Sprintf("%-5s %-8s %-11s %-9s", "Proto", "In/Out", "Established", "Listeners"))
 	y++
@@ -109,6 +137,14 @@ func DrawInspector(app *shared.AppState) {
 	y++
 	PutString(s, 2, y, fmt.Sprintf("%-5s %-8s %-11d %-9d", "UDP", fmt.Sprintf("%d/%d", udpInbound, udpOutbound), udpEstablished, udpListeners))
 	y++
+	if cand.UDPPeer != nil {
+		PutString(s, 2, y,
+			TruncateToWidth(
+				fmt.Sprintf("UDP peer: %s:%d held %ds", cand.UDPPeer.RemoteAddress, cand.UDPPeer.RemotePort, cand.UDPPeerSeconds),
+				w-2,
+			),
+		)
+	}
 	y++
 	y++
 	PutString(s, 2, y,
@@ -131,12 +167,90 @@ func DrawInspector(app *shared.AppState) {
 		),
 	)
 	y++
+	if bc := cand.BusiestConn; bc != nil {
+		PutString(s, 2, y,
+			TruncateToWidth(
+				fmt.Sprintf(
+					"Busiest:  %s:%d -> %s:%d  tx %s rx %s  rtt %.1fms  retrans %d",
+					bc.LocalAddress, bc.LocalPort, bc.RemoteAddress, bc.RemotePort,
+					FormatBytesPerSec(bc.SendBps), FormatBytesPerSec(bc.RecvBps),
+					float64(bc.RTTMicros)/1000, bc.Retransmits,
+				),
+				w-2,
+			),
+		)
+		y++
+	}
+	if sc := cand.Scan; sc != nil {
+		PutString(s, 2, y, TruncateToWidth("Scan:     "+sc.Summary, w-2))
+		y++
+	}
+	if b := cand.Beacon; b != nil {
+		PutString(s, 2, y,
+			TruncateToWidth(
+				fmt.Sprintf("Beacon:   %s:%d every %.1fs ±%.1fs (%d intervals)",
+					b.RemoteAddr, b.RemotePort, b.MeanSeconds, b.JitterSeconds, b.Count),
+				w-2,
+			),
+		)
+		y++
+	}
 	y++
 
-	if (len(cand.Conns) > 0 || len(cand.UDPListeners) > 0) && y < h-3 {
-		PutString(s, 2, y, "Proto Local                 Remote                State        Scope")
+	if len(cand.Timeline) > 0 && y < h-3 {
+		PutString(s, 2, y, TruncateToWidth("Timeline: "+rolePath(cand.Timeline, w-12), w-2))
 		y++
-		PutString(s, 2, y, "----- --------------------  --------------------  -----------  -------")
+		// most recent changes first
+		for i := len(cand.Timeline) - 1; i >= 0 && i >= len(cand.Timeline)-timelineRows && y < h-3; i-- {
+			t := cand.Timeline[i]
+			state := "idle"
+			if t.Active {
+				state = "active"
+			}
+			line := fmt.Sprintf("%s  %-22s score %-4d %s", t.At.UTC().Format("15:04:05"), t.Role, t.Score, state)
+			PutString(s, 4, y, TruncateToWidth(line, w-4))
+			y++
+		}
+		y++
+	}
+
+	if len(cand.Flows) > 0 && y < h-3 {
+		PutString(s, 2, y, "Flows (client -> proxy -> target)")
+		y++
+		for _, f := range cand.Flows {
+			if y >= h-3 {
+				break
+			}
+			line := fmt.Sprintf("%s:%d -> %s:%d -> %s:%d  (%s, %+.0fs)",
+				f.ClientAddr, f.ClientPort,
+				f.ProxyAddr, f.ProxyPort,
+				f.TargetAddr, f.TargetPort,
+				f.Via, f.GapSeconds,
+			)
+			PutString(s, 4, y, TruncateToWidth(line, w-4))
+			y++
+		}
+		y++
+	}
+
+	hasCounters := false
+	for _, cn := range cand.Conns {
+		if cn.BytesSent > 0 || cn.BytesReceived > 0 {
+			hasCounters = true
+			break
+		}
+	}
+
+	if (len(cand.Conns) > 0 || len(cand.UDPListeners) > 0 || len(cand.UDPConns) > 0) && y < h-3 {
+		if hasCounters {
+			PutString(s, 2, y, "Proto Local                 Remote                State        Scope    Tx/Rx rate")
+			y++
+			PutString(s, 2, y, "----- --------------------  --------------------  -----------  -------  ----------")
+		} else {
+			PutString(s, 2, y, "Proto Local                 Remote                State        Scope")
+			y++
+			PutString(s, 2, y, "----- --------------------  --------------------  -----------  -------")
+		}
 		y++
 
 		seen := make(map[string]struct{})
@@ -151,7 +265,7 @@ func DrawInspector(app *shared.AppState) {
 				!shared.IsWildcardIP(cn.RemoteAddress) &&
 				!shared.IsLoopbackIP(cn.RemoteAddress) {
 
-				if shared.IsInternalIP(cn.RemoteAddress) {
+				if app.Zones.IsInternal(cn.RemoteAddress) {
 					scope = "internal"
 				} else {
 					scope = "external"
@@ -167,6 +281,35 @@ func DrawInspector(app *shared.AppState) {
 			seen[key] = struct{}{}
 
 			line := fmt.Sprintf("%-5s %-20s %-20s %-11s %-7s", "TCP", l, r, cn.State, scope)
+			if hasCounters {
+				line += fmt.Sprintf("  %s / %s", FormatBytesPerSec(cn.SendBps), FormatBytesPerSec(cn.RecvBps))
+			}
+			PutString(s, 2, y, TruncateToWidth(line, w-2))
+			y++
+		}
+
+		for _, uc := range cand.UDPConns {
+			if y >= h-2 {
+				break
+			}
+
+			scope := ""
+			if !shared.IsLoopbackIP(uc.RemoteAddress) {
+				if app.Zones.IsInternal(uc.RemoteAddress) {
+					scope = "internal"
+				} else {
+					scope = "external"
+				}
+			}
+
+			l := fmt.Sprintf("%s:%d", uc.LocalAddress, uc.LocalPort)
+			r := fmt.Sprintf("%s:%d", uc.RemoteAddress, uc.RemotePort)
+			key := fmt.Sprintf("udp|%s|%s|%s", l, r, scope)
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			line := fmt.Sprintf("%-5s %-20s %-20s %-11s %-7s", "UDP", l, r, "CONNECTED", scope)
 			PutString(s, 2, y, TruncateToWidth(line, w-2))
 			y++
 		}
@@ -178,7 +321,7 @@ func DrawInspector(app *shared.AppState) {
 
 			l := fmt.Sprintf("%s:%d", ul.LocalAddress, ul.LocalPort)
 			r := "*:*"
-			scope := shared.ScopeLabelForLocalAddress(ul.LocalAddress)
+			scope := app.Zones.ScopeLabel(ul.LocalAddress)
 			key := fmt.Sprintf("udp|%s|%s|%s", l, r, scope)
 			if _, ok := seen[key]; ok {
 				continue
@@ -204,5 +347,28 @@ func DrawInspector(app *shared.AppState) {
 		PutString(s, 0, h-2, TruncateToWidth(msg, w))
 	}
 
-	PutString(s, 0, h-1, "ESC return | k kill | q quit")
+	PutString(s, 0, h-1, "ESC return | k kill | c chain | w why | q quit")
+}
+
+// timelineRows is how many recent transitions the inspector lists under the
+// role path.
+const timelineRows = 4
+
+// rolePath renders the role changes in tl as "role hh:mm -> role hh:mm",
+// dropping the oldest roles until it fits in width.
+func rolePath(tl []shared.Transition, width int) string {
+	var steps []string
+	for i, t := range tl {
+		if i > 0 && tl[i-1].Role == t.Role {
+			continue
+		}
+		steps = append(steps, t.Role+" "+t.At.UTC().Format("15:04"))
+	}
+
+	path := strings.Join(steps, " -> ")
+	for len(steps) > 1 && len(path) > width {
+		steps = steps[1:]
+		path = "... -> " + strings.Join(steps, " -> ")
+	}
+	return path
 }
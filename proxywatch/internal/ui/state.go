@@ -43,6 +43,13 @@ func MinInt(a, b int) int {
 	return b
 }
 
+func MaxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
 func FormatBytes(n uint64) string {
 	const unit = 1024
 	if n < unit {
@@ -27,6 +27,9 @@ func Run(app *shared.AppState, scanner shared.Scanner) error {
 	if app.ConfirmKillTimeout <= 0 {
 		app.ConfirmKillTimeout = 3 * time.Second
 	}
+	if app.Zones == nil {
+		app.Zones = shared.DefaultNetworkZones()
+	}
 	app.SelectedIdx = -1
 	app.Mode = shared.ModeDashboard
 
@@ -40,12 +43,10 @@ func Run(app *shared.AppState, scanner shared.Scanner) error {
 	}()
 
 	type refreshResult struct {
-		candidates          []shared.Candidate
-		lastError           string
-		lastUpdate          time.Time
-		selectedPID         int
-		selectedIdx         int
-		selectionPIDAtStart int
+		classified []shared.Candidate
+		lastError  string
+		lastUpdate time.Time
+		sourceDone bool
 	}
 
 	refreshCh := make(chan refreshResult, 1)
@@ -55,22 +56,34 @@ func Run(app *shared.AppState, scanner shared.Scanner) error {
 			return
 		}
 		refreshInFlight = true
-		selectionPIDAtStart := app.SelectedPID
+		tmp := *app
+		tmp.Screen = nil
 		go func() {
-			tmp := *app
-			tmp.Screen = nil
 			scanner.Refresh(&tmp)
 			refreshCh <- refreshResult{
-				candidates:          tmp.Candidates,
-				lastError:           tmp.LastError,
-				lastUpdate:          tmp.LastUpdate,
-				selectedPID:         tmp.SelectedPID,
-				selectedIdx:         tmp.SelectedIdx,
-				selectionPIDAtStart: selectionPIDAtStart,
+				classified: tmp.Classified,
+				lastError:  tmp.LastError,
+				lastUpdate: tmp.LastUpdate,
+				sourceDone: tmp.SourceDone,
 			}
 		}()
 	}
 
+	reload := func() {
+		if app.Reloader == nil {
+			return
+		}
+		if err := app.Reloader.Reload(); err != nil {
+			app.LastError = "Reload failed: " + err.Error()
+		} else {
+			app.LastError = "Configuration reloaded"
+		}
+	}
+
+	if app.SourcePaced && !app.SourceDone {
+		startRefresh()
+	}
+
 	tick := time.NewTicker(app.RefreshInt)
 	defer tick.Stop()
 
@@ -84,6 +97,10 @@ func Run(app *shared.AppState, scanner shared.Scanner) error {
 			DrawDashboard(app)
 		case shared.ModeInspect:
 			DrawInspector(app)
+		case shared.ModeChain:
+			DrawChain(app)
+		case shared.ModeWhy:
+			DrawWhy(app)
 		}
 		s.Show()
 
@@ -119,6 +136,14 @@ func Run(app *shared.AppState, scanner shared.Scanner) error {
 						}
 					}
 
+					if tev.Rune() == 'r' {
+						reload()
+					}
+					if tev.Rune() == 's' {
+						// re-filter the last tick; a refresh would advance a replay
+						app.ShowSuppressed = !app.ShowSuppressed
+						app.SetCandidates(app.Classified)
+					}
 					if tev.Rune() == 'q' {
 						return nil
 					}
@@ -137,6 +162,16 @@ func Run(app *shared.AppState, scanner shared.Scanner) error {
 						app.ConfirmKillPID = 0
 						return nil
 					}
+					if tev.Rune() == 'c' {
+						app.ConfirmKillPID = 0
+						app.Mode = shared.ModeChain
+						break
+					}
+					if tev.Rune() == 'w' {
+						app.ConfirmKillPID = 0
+						app.Mode = shared.ModeWhy
+						break
+					}
 					if tev.Rune() == 'k' || tev.Rune() == 'K' || tev.Rune() == 'y' || tev.Rune() == 'Y' {
 						pid := app.InspectPID
 						if app.ConfirmKill {
@@ -164,36 +199,33 @@ func Run(app *shared.AppState, scanner shared.Scanner) error {
 						}
 						app.ConfirmKillPID = 0
 					}
+
+				case shared.ModeChain, shared.ModeWhy:
+					if tev.Key() == tcell.KeyEscape {
+						app.Mode = shared.ModeInspect
+					}
+					if tev.Rune() == 'q' {
+						return nil
+					}
 				}
 			}
 
+		case <-app.ReloadRequests:
+			reload()
 		case <-tick.C:
 			startRefresh()
 		case res := <-refreshCh:
 			refreshInFlight = false
-			app.Candidates = res.candidates
+			// filtered and selected here, not in the refresh, so keys pressed
+			// while it ran (selection, 's') are honoured
+			app.SetCandidates(res.classified)
 			app.LastError = res.lastError
 			app.LastUpdate = res.lastUpdate
+			app.SourceDone = res.sourceDone
 
-			if len(app.Candidates) == 0 {
-				app.SelectedIdx = -1
-				app.SelectedPID = 0
-				break
-			}
-
-			if app.SelectedPID != res.selectionPIDAtStart {
-				idx := FindIndexByPID(app.Candidates, app.SelectedPID)
-				if idx >= 0 {
-					app.SelectedIdx = idx
-				} else {
-					app.SelectedIdx = 0
-					app.SelectedPID = app.Candidates[0].Proc.Pid
-				}
-				break
+			if app.SourcePaced && !app.SourceDone {
+				startRefresh()
 			}
-
-			app.SelectedPID = res.selectedPID
-			app.SelectedIdx = res.selectedIdx
 		}
 	}
 }
@@ -0,0 +1,87 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"proxywatch/internal/shared"
+)
+
+// DrawWhy shows how the inspected candidate's score was assembled.
+func DrawWhy(app *shared.AppState) {
+	s := app.Screen
+	s.Clear()
+
+	w, h := s.Size()
+	nowUTC := time.Now().UTC()
+
+	PutString(s, 0, 0,
+		TruncateToWidth(fmt.Sprintf("UTC: %s", nowUTC.Format("2006-01-02 15:04:05")), w),
+	)
+
+	idx := FindIndexByPID(app.Candidates, app.InspectPID)
+	if idx < 0 {
+		PutString(s, 0, 2, "Process no longer present. Press ESC.")
+		PutString(s, 0, h-1, "ESC return | q quit")
+		return
+	}
+	cand := &app.Candidates[idx]
+
+	y := 2
+	title := fmt.Sprintf(" Why %s (PID %d) is %s ", cand.Proc.Name, cand.Proc.Pid, cand.Role)
+	sep := strings.Repeat("─", MinInt(len(title), w))
+
+	PutString(s, 0, y, sep)
+	y++
+	PutString(s, 0, y, TruncateToWidth(title, w))
+	y++
+	PutString(s, 0, y, sep)
+	y += 2
+
+	PutString(s, 2, y, fmt.Sprintf("%6s  %-28s %-24s %s", "Delta", "Rule", "Feature", "Value"))
+	y++
+	PutString(s, 2, y, fmt.Sprintf("%6s  %-28s %-24s %s", "-----", "----", "-------", "-----"))
+	y++
+
+	total := 0
+	for _, sc := range cand.Contributions {
+		total += sc.Delta
+		if y >= h-4 {
+			continue
+		}
+		line := fmt.Sprintf("%+6d  %-28s %-24s %v", sc.Delta, sc.Rule, sc.Feature, sc.Value)
+		PutString(s, 2, y, TruncateToWidth(line, w-2))
+		y++
+	}
+	PutString(s, 2, y, fmt.Sprintf("%6s", "------"))
+	y++
+	PutString(s, 2, y, fmt.Sprintf("%6d  score (confidence %d)", total, cand.Confidence))
+	y += 2
+
+	if len(cand.Features) > 0 && y < h-3 {
+		names := make([]string, 0, len(cand.Features))
+		for n := range cand.Features {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+
+		PutString(s, 2, y, "Features")
+		y++
+
+		// pack name=value pairs into as many columns as fit
+		const colW = 38
+		cols := MaxInt(1, (w-4)/colW)
+		for i := 0; i < len(names) && y < h-2; i += cols {
+			var b strings.Builder
+			for j := i; j < i+cols && j < len(names); j++ {
+				fmt.Fprintf(&b, "%-*s", colW, fmt.Sprintf("%s=%v", names[j], cand.Features[names[j]]))
+			}
+			PutString(s, 4, y, TruncateToWidth(b.String(), w-4))
+			y++
+		}
+	}
+
+	PutString(s, 0, h-1, "ESC return | q quit")
+}